@@ -0,0 +1,33 @@
+#!/bin/sh
+set -o xtrace
+
+# Starts the KMIP test server of drivers-evergreen-tools in the background, for
+# encrypted workloads run against clusters without access to a cloud KMS, e.g.
+# in a Kind cluster. Source this script so that the FLE_KMIP_* variables the
+# workload executor reads are exported to the calling shell.
+
+# User configurable-options
+export KMIP_PORT="${KMIP_PORT:-5698}"
+
+# Setup variables
+export DRIVERS_TOOLS="${DRIVERS_TOOLS:-$(dirname $(pwd))/drivers-tools}"
+if [ "Windows_NT" = "$OS" ]; then
+   export DRIVERS_TOOLS=$(cygpath -m $DRIVERS_TOOLS)
+fi
+
+# Clone drivers-evergreen-tools, unless run-mongodb.sh already did
+if [ ! -d "$DRIVERS_TOOLS" ]; then
+  git clone --recursive git://github.com/mongodb-labs/drivers-evergreen-tools.git $DRIVERS_TOOLS
+fi
+
+# Install PyKMIP in its own virtualenv, since it pins its dependencies
+python3 -m venv $DRIVERS_TOOLS/kmipenv
+$DRIVERS_TOOLS/kmipenv/bin/pip install PyKMIP
+
+# Run the server, which serves the certificate in x509gen/server.pem
+(cd $DRIVERS_TOOLS/.evergreen/csfle && \
+  $DRIVERS_TOOLS/kmipenv/bin/python kms_kmip_server.py --port $KMIP_PORT > kmip-server.log 2>&1 &)
+
+export FLE_KMIP_ENDPOINT="localhost:$KMIP_PORT"
+export FLE_KMIP_CA_FILE="$DRIVERS_TOOLS/.evergreen/x509gen/ca.pem"
+export FLE_KMIP_CERT_FILE="$DRIVERS_TOOLS/.evergreen/x509gen/client.pem"
@@ -31,6 +31,7 @@ from astrolabe.utils import (
     require_requests_ipv4, get_logs,
     create_click_option, get_cluster_name, get_test_name_from_spec_file,
     ClickLogHandler)
+from astrolabe.report import generate_html_report
 from astrolabe.validator import validator_factory
 
 
@@ -53,6 +54,15 @@ POLLINGFREQUENCY_OPTION = create_click_option(
 EXECUTORSTARTUPTIME_OPTION = create_click_option(
     CONFIGOPTS.ASTROLABE_EXECUTOR_STARTUP_TIME)
 
+EXECUTORREADYTIMEOUT_OPTION = create_click_option(
+    CONFIGOPTS.ASTROLABE_EXECUTOR_READY_TIMEOUT)
+
+EXECUTORMAXRESTARTS_OPTION = create_click_option(
+    CONFIGOPTS.ASTROLABE_EXECUTOR_MAX_RESTARTS)
+
+ONLINEARCHIVEURI_OPTION = create_click_option(
+    CONFIGOPTS.ATLAS_ONLINE_ARCHIVE_URI)
+
 CLUSTERNAMESALT_OPTION = create_click_option(CONFIGOPTS.CLUSTER_NAME_SALT)
 
 ATLASCLUSTERNAME_OPTION = click.option(
@@ -409,11 +419,15 @@ def spec_tests():
 @NODELETE_FLAG
 @NOCREATE_FLAG
 @EXECUTORSTARTUPTIME_OPTION
+@EXECUTORREADYTIMEOUT_OPTION
+@EXECUTORMAXRESTARTS_OPTION
+@ONLINEARCHIVEURI_OPTION
 @click.pass_context
 def run_single_test(ctx, spec_test_file, workload_executor,
                     db_username, db_password, org_name, project_name,
                     cluster_name_salt, polling_timeout, polling_frequency,
-                    xunit_output, no_delete, no_create, startup_time):
+                    xunit_output, no_delete, no_create, startup_time,
+                    ready_timeout, max_restarts, online_archive_uri):
     """
     Runs one APM test.
     This is the main entry point for running APM tests in headless environments.
@@ -439,7 +453,10 @@ def run_single_test(ctx, spec_test_file, workload_executor,
                               xunit_output=xunit_output,
                               persist_clusters=no_delete,
                               no_create=no_create,
-                              workload_startup_time=startup_time)
+                              workload_startup_time=startup_time,
+                              workload_ready_timeout=ready_timeout,
+                              workload_max_restarts=max_restarts,
+                              online_archive_uri=online_archive_uri)
 
     # Step-2: run the tests.
     failed = runner.run()
@@ -524,11 +541,15 @@ def delete_test_cluster(ctx, spec_test_file, org_name, project_name,
 @XUNITOUTPUT_OPTION
 @NODELETE_FLAG
 @EXECUTORSTARTUPTIME_OPTION
+@EXECUTORREADYTIMEOUT_OPTION
+@EXECUTORMAXRESTARTS_OPTION
+@ONLINEARCHIVEURI_OPTION
 @click.pass_context
 def run_headless(ctx, spec_tests_directory, workload_executor, db_username,
                  db_password, org_name, project_name, cluster_name_salt,
                  polling_timeout, polling_frequency, xunit_output, no_delete,
-                 startup_time):
+                 startup_time, ready_timeout, max_restarts,
+                 online_archive_uri):
     """
     Run multiple APM tests in serial.
     This command runs all tests found in the SPEC_TESTS_DIRECTORY sequentially
@@ -552,7 +573,10 @@ def run_headless(ctx, spec_tests_directory, workload_executor, db_username,
                              configuration=config,
                              xunit_output=xunit_output,
                              persist_clusters=no_delete,
-                             workload_startup_time=startup_time)
+                             workload_startup_time=startup_time,
+                             workload_ready_timeout=ready_timeout,
+                             workload_max_restarts=max_restarts,
+                             online_archive_uri=online_archive_uri)
 
     # Step-2: run the tests.
     failed = runner.run()
@@ -589,6 +613,37 @@ def stats(ctx):
     cmd.aggregate_statistics()
 
 
+@spec_tests.command('report')
+@click.option('--results', type=click.Path(dir_okay=False),
+              default='results.json', show_default=True,
+              help='Path to the results.json written by the workload executor.')
+@click.option('--events', type=click.Path(dir_okay=False),
+              default='events.json', show_default=True,
+              help='Path to the events.json written by the workload executor.')
+@click.option('--phases', type=click.Path(dir_okay=False),
+              default='phases.json', show_default=True,
+              help='Path to the phases.json written by astrolabe.')
+@click.option('--topology', type=click.Path(dir_okay=False),
+              default='topology-timeline.json', show_default=True,
+              help='Path to the topology-timeline.json written by the '
+                   'workload executor.')
+@click.option('-o', '--output', type=click.Path(dir_okay=False),
+              default='report.html', show_default=True,
+              help='Path of the HTML report to generate.')
+@click.option('--title', type=click.STRING, default='Workload Report',
+              show_default=True, help='Title of the HTML report.')
+def generate_report(results, events, phases, topology, output, title):
+    """
+    Generates a standalone HTML report of a test run.
+    The report contains the throughput, errors, primary changes and server
+    states observed by the workload executor with the maintenance phases
+    shaded in.
+    """
+    generate_html_report(
+        results_path=results, events_path=events, phases_path=phases,
+        topology_path=topology, output_path=output, title=title)
+
+
 if __name__ == '__main__':
     require_requests_ipv4()
     cli()
@@ -100,7 +100,29 @@ CONFIGURATION_OPTIONS = JSONObject({
         'help': 'Time (in s) to wait for the executor to start running.',
         'cliopt': '--startup-time',
         'envvar': 'ASTROLABE_EXECUTOR_STARTUP_TIME',
-        'default': 1.0}
+        'default': 1.0},
+    'ASTROLABE_EXECUTOR_READY_TIMEOUT': {
+        'type': click.FLOAT,
+        'help': ('Time (in s) to wait for the executor to report that the '
+                 'workload is ready. 0 disables the wait.'),
+        'cliopt': '--ready-timeout',
+        'envvar': 'ASTROLABE_EXECUTOR_READY_TIMEOUT',
+        'default': 0.0},
+    'ASTROLABE_EXECUTOR_MAX_RESTARTS': {
+        'type': click.INT,
+        'help': ('Number of times to relaunch the executor if it crashes, '
+                 'resuming its checkpointed counters. 0 disables restarts.'),
+        'cliopt': '--max-executor-restarts',
+        'envvar': 'ASTROLABE_EXECUTOR_MAX_RESTARTS',
+        'default': 0},
+    'ATLAS_ONLINE_ARCHIVE_URI': {
+        'help': ('Connection string of the federated database instance of '
+                 'the online archive of the cluster, for tests that '
+                 'configure onlineArchive. The database credentials are '
+                 'added to it.'),
+        'cliopt': '--online-archive-uri',
+        'envvar': 'ATLAS_ONLINE_ARCHIVE_URI',
+        'default': None}
 })
 
 
@@ -0,0 +1,54 @@
+# Copyright 2020-present MongoDB, Inc.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+# http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+import logging
+
+
+LOGGER = logging.getLogger(__name__)
+
+
+def _read_metrics(metrics_path):
+    try:
+        with open(metrics_path, 'r') as fp:
+            return json.load(fp)
+    except (OSError, ValueError) as exc:
+        LOGGER.warning("Could not read metrics for latency assertions: "
+                       "{}".format(exc))
+        return None
+
+
+def check_latency_assertions(assertions, metrics_path):
+    """Check the latencyAssertions of a scenario against the metrics.json
+    written by the workload executor. Returns a message for every assertion
+    that does not hold. Assertions are not checked if the workload executor
+    did not write metrics.json."""
+    if not assertions:
+        return []
+    metrics = _read_metrics(metrics_path)
+    if metrics is None:
+        return []
+
+    messages = []
+    latency = metrics.get('latencyMS', {})
+    for key, actual, description in (
+            ('maxP99LatencyMS', latency.get('p99', 0), 'p99 latency'),
+            ('maxLatencyMS', latency.get('max', 0), 'maximum latency'),
+            ('maxStallMS', (metrics.get('longestStall') or {}).get(
+                'durationMS', 0), 'longest stall')):
+        limit = assertions.get(key)
+        if limit is not None and actual > limit:
+            messages.append("{} was {:.0f} ms, expected at most {} ms".format(
+                description, actual, limit))
+    return messages
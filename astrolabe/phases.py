@@ -0,0 +1,125 @@
+# Copyright 2020-present MongoDB, Inc.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+# http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import json
+import logging
+
+
+LOGGER = logging.getLogger(__name__)
+
+
+# Names of the phases that span the whole run, in order.
+BEFORE_MAINTENANCE = 'beforeMaintenance'
+DURING_MAINTENANCE = 'duringMaintenance'
+AFTER_MAINTENANCE = 'afterMaintenance'
+
+
+def maintenance_phases(phases, workload_start, workload_end):
+    """Return the beforeMaintenance, duringMaintenance and afterMaintenance
+    phases of a run in which the workload ran from ``workload_start`` to
+    ``workload_end`` and the operations of the scenario ran in ``phases``.
+    duringMaintenance is empty if the scenario ran no operation."""
+    if phases:
+        first, last = phases[0]['start'], phases[-1]['end']
+    else:
+        first = last = workload_end
+    return [
+        {'name': BEFORE_MAINTENANCE, 'start': workload_start, 'end': first},
+        {'name': DURING_MAINTENANCE, 'start': first, 'end': last},
+        {'name': AFTER_MAINTENANCE, 'start': last, 'end': workload_end},
+    ]
+
+
+def _read_events(events_path):
+    try:
+        with open(events_path, 'r') as fp:
+            return json.load(fp)
+    except (OSError, ValueError) as exc:
+        LOGGER.warning("Could not read events for phase assertions: "
+                       "{}".format(exc))
+        return {}
+
+
+def _windows(phases, name):
+    return [(p['start'], p['end']) for p in phases if p['name'] == name]
+
+
+def _count_within(records, windows):
+    return sum(1 for record in records
+               if any(start <= record.get('time', -1) <= end
+                      for start, end in windows))
+
+
+def check_phase_assertions(assertions, phases, events_path):
+    """Check the phaseAssertions of a scenario against the errors and
+    failures the workload executor wrote to events.json. ``phases`` are the
+    phases of the operations of the scenario followed by those returned by
+    maintenance_phases. An assertion naming an operation applies to every
+    phase of that operation. Returns a message for every assertion that does
+    not hold."""
+    events = _read_events(events_path)
+    messages = []
+    for assertion in assertions:
+        name = assertion['phase']
+        windows = _windows(phases, name)
+        if not windows:
+            messages.append("phase {!r} did not occur".format(name))
+            continue
+
+        for kind, key in (('errors', 'maxErrors'),
+                          ('failures', 'maxFailures')):
+            limit = assertion.get(key, 0)
+            count = _count_within(events.get(kind, []), windows)
+            if count > limit:
+                messages.append(
+                    "{} {} during phase {!r}, expected at most {}".format(
+                        count, kind, name, limit))
+    return messages
+
+
+def _num_dropped(stats, kind):
+    # results.json nests droppedErrors and droppedFailures under metrics
+    # from version 2 on
+    key = 'dropped' + kind.capitalize()
+    return stats.get(key) or stats.get('metrics', {}).get(key, 0)
+
+
+def allowed_by_phase_assertions(assertions, phases, events_path, stats):
+    """Return the numbers of errors and failures that occurred during phases
+    whose assertions set excludeFromTotals and allow them, i.e. whose
+    maxErrors or maxFailures is positive. These are expected, e.g. while a
+    cluster is being restored, and do not count against the numErrors and
+    numFailures of ``stats``, the contents of results.json. Nothing is
+    allowed if the workload executor dropped records of that kind from
+    events.json, since the records left cannot be attributed to phases."""
+    events = None
+    allowed = {'errors': 0, 'failures': 0}
+    for kind, key in (('errors', 'maxErrors'), ('failures', 'maxFailures')):
+        windows = []
+        for assertion in assertions:
+            if (assertion.get('excludeFromTotals', False) and
+                    assertion.get(key, 0) > 0):
+                windows.extend(_windows(phases, assertion['phase']))
+        if not windows:
+            continue
+        dropped = _num_dropped(stats, kind)
+        if dropped:
+            LOGGER.warning("Not excluding the {} of phases from the totals: "
+                           "the workload executor dropped {} of their "
+                           "records".format(kind, dropped))
+            continue
+        if events is None:
+            events = _read_events(events_path)
+        allowed[kind] = _count_within(events.get(kind, []), windows)
+    return allowed['errors'], allowed['failures']
@@ -0,0 +1,297 @@
+# Copyright 2020-present MongoDB, Inc.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+# http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+"""Generation of standalone HTML reports from workload executor output."""
+
+import datetime
+import html
+import json
+import logging
+import os
+import time
+
+
+LOGGER = logging.getLogger(__name__)
+
+# Dimensions (in px) of the timeline charts.
+CHART_WIDTH = 1000
+CHART_HEIGHT = 200
+
+# Maximum number of buckets used to plot throughput.
+MAX_BUCKETS = 300
+
+# Colors used to shade maintenance phases, cycled in order.
+PHASE_COLORS = ['#fde9c9', '#d9ecfb', '#e3f5dc', '#f3dcf5', '#f5e1dc']
+
+# Command names that can only be executed by a primary (or by mongos on
+# behalf of a primary). A change in the address that serves these commands
+# is reported as a primary change.
+WRITE_COMMANDS = frozenset([
+    'insert', 'update', 'delete', 'findAndModify', 'commitTransaction',
+    'abortTransaction'])
+
+# Colors of the server states in the topology chart. States not listed here
+# are drawn in grey.
+STATE_COLORS = {
+    'RSPrimary': '#2ca02c',
+    'RSSecondary': '#1f77b4',
+    'Mongos': '#17becf',
+    'Standalone': '#9467bd',
+    'LoadBalancer': '#bcbd22',
+    'Unknown': '#d62728',
+}
+
+# Height (in px) of the row of each server in the topology chart.
+SERVER_ROW_HEIGHT = 24
+
+TEMPLATE = """<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{title}</title>
+<style>
+body {{ font-family: sans-serif; margin: 2em; color: #222; }}
+table {{ border-collapse: collapse; margin-bottom: 2em; }}
+td, th {{ border: 1px solid #ccc; padding: 4px 10px; text-align: left; }}
+svg {{ border: 1px solid #ccc; margin-bottom: 0.5em; }}
+.legend span {{ display: inline-block; padding: 2px 8px; margin-right: 6px; }}
+</style>
+</head>
+<body>
+<h1>{title}</h1>
+<h2>Summary</h2>
+{summary}
+<h2>Throughput (successful commands per second)</h2>
+{throughput}
+<h2>Errors and failures</h2>
+{errors}
+<div class="legend">{legend}</div>
+<h2>Primary changes</h2>
+{primaries}
+<h2>Topology</h2>
+{topology}
+</body>
+</html>
+"""
+
+
+def _load_json(path, default):
+    if path is None or not os.path.exists(path):
+        LOGGER.debug("Report input {!r} not found".format(path))
+        return default
+    with open(path, 'r') as fp:
+        return json.load(fp)
+
+
+def _format_time(timestamp):
+    return datetime.datetime.utcfromtimestamp(timestamp).strftime(
+        '%Y-%m-%d %H:%M:%S UTC')
+
+
+class _Timeline:
+    """Maps timestamps (in seconds) onto the horizontal axis of a chart."""
+    def __init__(self, start, end):
+        self.start = start
+        self.end = max(end, start + 1)
+
+    @property
+    def duration(self):
+        return self.end - self.start
+
+    def x(self, timestamp):
+        offset = (timestamp - self.start) / self.duration
+        return round(min(max(offset, 0), 1) * CHART_WIDTH, 2)
+
+
+def _svg(timeline, phases, body):
+    shading = []
+    for i, phase in enumerate(phases):
+        x1 = timeline.x(phase['start'])
+        x2 = timeline.x(phase['end'])
+        shading.append(
+            '<rect x="{}" y="0" width="{}" height="{}" fill="{}">'
+            '<title>{}</title></rect>'.format(
+                x1, max(x2 - x1, 1), CHART_HEIGHT,
+                PHASE_COLORS[i % len(PHASE_COLORS)],
+                html.escape(phase['name'])))
+    return '<svg width="{0}" height="{1}" viewBox="0 0 {0} {1}">{2}{3}</svg>'.format(
+        CHART_WIDTH, CHART_HEIGHT, ''.join(shading), ''.join(body))
+
+
+def _render_throughput(timeline, phases, events):
+    num_buckets = min(MAX_BUCKETS, max(int(timeline.duration), 1))
+    bucket_size = timeline.duration / num_buckets
+    buckets = [0] * num_buckets
+    for event in events:
+        if event.get('name') != 'CommandSucceededEvent':
+            continue
+        index = int((event['observedAt'] - timeline.start) / bucket_size)
+        if 0 <= index < num_buckets:
+            buckets[index] += 1
+
+    peak = max(buckets) or 1
+    points = []
+    for index, count in enumerate(buckets):
+        x = round((index + 0.5) * CHART_WIDTH / num_buckets, 2)
+        y = round(CHART_HEIGHT - count / peak * (CHART_HEIGHT - 10), 2)
+        points.append('{},{}'.format(x, y))
+    body = ['<polyline fill="none" stroke="#1f77b4" stroke-width="1.5" '
+            'points="{}"/>'.format(' '.join(points))]
+    return _svg(timeline, phases, body) + '<p>Peak: {:.1f} commands/sec</p>'.format(
+        peak / bucket_size)
+
+
+def _render_errors(timeline, phases, errors, failures):
+    body = []
+    for records, color, y in ((errors, '#d62728', CHART_HEIGHT / 3),
+                              (failures, '#ff7f0e', 2 * CHART_HEIGHT / 3)):
+        for record in records:
+            if 'time' not in record:
+                continue
+            x = timeline.x(record['time'])
+            title = str(record.get('error', ''))
+            if record.get('label'):
+                title = '[{}] {}'.format(record['label'], title)
+            body.append(
+                '<line x1="{0}" y1="{1}" x2="{0}" y2="{2}" stroke="{3}">'
+                '<title>{4}</title></line>'.format(
+                    x, y - 20, y + 20, color, html.escape(title)))
+    return _svg(timeline, phases, body) + (
+        '<p><span style="color: #d62728">&#9646; errors</span> '
+        '<span style="color: #ff7f0e">&#9646; failures</span></p>')
+
+
+def _find_primary_changes(events):
+    changes = []
+    current = None
+    for event in events:
+        if event.get('name') != 'CommandSucceededEvent':
+            continue
+        if event.get('commandName') not in WRITE_COMMANDS:
+            continue
+        address = event.get('address')
+        if address is None or address == current:
+            continue
+        changes.append((event['observedAt'], current, address))
+        current = address
+    return changes
+
+
+def _render_primaries(changes):
+    if not changes:
+        return '<p>No write commands with server addresses were recorded.</p>'
+    rows = ['<tr><th>Time</th><th>Previous</th><th>New</th></tr>']
+    for observed_at, previous, address in changes:
+        rows.append('<tr><td>{}</td><td>{}</td><td>{}</td></tr>'.format(
+            _format_time(observed_at), html.escape(previous or '-'),
+            html.escape(address)))
+    return '<table>{}</table>'.format(''.join(rows))
+
+
+def _render_topology(timeline, servers):
+    if not servers:
+        return '<p>No topology timeline was recorded.</p>'
+    height = SERVER_ROW_HEIGHT * len(servers)
+    body = []
+    for row, server in enumerate(servers):
+        y = row * SERVER_ROW_HEIGHT
+        transitions = server.get('transitions', [])
+        for i, transition in enumerate(transitions):
+            state = transition['state']
+            if state == 'Removed':
+                continue
+            end = (transitions[i + 1]['time'] if i + 1 < len(transitions)
+                   else timeline.end)
+            x1 = timeline.x(transition['time'])
+            x2 = timeline.x(end)
+            body.append(
+                '<rect x="{}" y="{}" width="{}" height="{}" fill="{}">'
+                '<title>{}: {}</title></rect>'.format(
+                    x1, y + 2, max(x2 - x1, 1), SERVER_ROW_HEIGHT - 4,
+                    STATE_COLORS.get(state, '#999999'),
+                    html.escape(server['address']), html.escape(state)))
+        body.append('<text x="4" y="{}" font-size="12">{}</text>'.format(
+            y + SERVER_ROW_HEIGHT - 8, html.escape(server['address'])))
+    legend = ' '.join(
+        '<span style="color: {}">&#9646; {}</span>'.format(color, state)
+        for state, color in sorted(STATE_COLORS.items()))
+    return ('<svg width="{0}" height="{1}" viewBox="0 0 {0} {1}">{2}</svg>'
+            '<p>{3}</p>'.format(CHART_WIDTH, height, ''.join(body), legend))
+
+
+def _render_summary(results, timeline, phases):
+    rows = []
+    for key in sorted(results):
+        value = results[key]
+        if isinstance(value, (dict, list)):
+            value = json.dumps(value)
+        rows.append('<tr><th>{}</th><td>{}</td></tr>'.format(
+            html.escape(key), html.escape(str(value))))
+    rows.append('<tr><th>Start</th><td>{}</td></tr>'.format(
+        _format_time(timeline.start)))
+    rows.append('<tr><th>Duration</th><td>{:.1f} sec</td></tr>'.format(
+        timeline.duration))
+    for phase in phases:
+        rows.append('<tr><th>Phase: {}</th><td>{:.1f} - {:.1f} sec</td></tr>'.format(
+            html.escape(phase['name']), phase['start'] - timeline.start,
+            phase['end'] - timeline.start))
+    return '<table>{}</table>'.format(''.join(rows))
+
+
+def generate_html_report(*, results_path, events_path, phases_path,
+                         output_path, topology_path=None,
+                         title='Workload Report'):
+    """Generate a standalone HTML report from the results.json, events.json,
+    phases.json and topology-timeline.json files written during a test run.
+    Missing input files are treated as empty. Returns the path of the
+    generated report."""
+    results = _load_json(results_path, {})
+    events_data = _load_json(events_path, {})
+    phases = _load_json(phases_path, {}).get('phases', [])
+    servers = _load_json(topology_path, {}).get('servers', [])
+
+    events = sorted(
+        [e for e in events_data.get('events', []) if 'observedAt' in e],
+        key=lambda e: e['observedAt'])
+    errors = events_data.get('errors', [])
+    failures = events_data.get('failures', [])
+
+    timestamps = [e['observedAt'] for e in events]
+    timestamps.extend(r['time'] for r in errors + failures if 'time' in r)
+    for phase in phases:
+        timestamps.extend([phase['start'], phase['end']])
+    for server in servers:
+        timestamps.extend(t['time'] for t in server.get('transitions', []))
+    if not timestamps:
+        timestamps = [time.time()]
+    timeline = _Timeline(min(timestamps), max(timestamps))
+
+    legend = ''.join(
+        '<span style="background: {}">{}</span>'.format(
+            PHASE_COLORS[i % len(PHASE_COLORS)], html.escape(phase['name']))
+        for i, phase in enumerate(phases))
+
+    content = TEMPLATE.format(
+        title=html.escape(title),
+        summary=_render_summary(results, timeline, phases),
+        throughput=_render_throughput(timeline, phases, events),
+        errors=_render_errors(timeline, phases, errors, failures),
+        legend=legend,
+        primaries=_render_primaries(_find_primary_changes(events)),
+        topology=_render_topology(timeline, servers))
+
+    with open(output_path, 'w') as fp:
+        fp.write(content)
+    LOGGER.info("Wrote HTML report to {!r}".format(output_path))
+    return output_path
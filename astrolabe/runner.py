@@ -13,7 +13,8 @@
 # limitations under the License.
 
 import logging, datetime, time as _time, gzip
-import os, io, re
+import json, os, io, re
+import hashlib, ssl
 from time import sleep
 from urllib.parse import urlencode
 
@@ -29,7 +30,12 @@ from astrolabe.commands import (
     ensure_connect_from_anywhere)
 from astrolabe.exceptions import PollingTimeoutError
 from astrolabe.exceptions import AstrolabeTestCaseError
+from astrolabe.phases import (
+    AFTER_MAINTENANCE, DURING_MAINTENANCE, allowed_by_phase_assertions,
+    check_phase_assertions, maintenance_phases)
+from astrolabe.metrics import check_latency_assertions
 from astrolabe.poller import BooleanCallablePoller
+from astrolabe.report import generate_html_report
 from astrolabe.utils import (
     assert_subset, get_cluster_name, get_test_name_from_spec_file,
     DriverWorkloadSubprocessRunner, SingleTestXUnitLogger,
@@ -51,6 +57,9 @@ class AtlasTestCase:
         self.spec = specification
         self.config = configuration
         self.failed = False
+        # Whether an operation changed the maintenance window of the project,
+        # which is shared by every test and is reset when the test finishes.
+        self.maintenance_window_changed = False
 
         # Initialize attribute used for memoization of connection string.
         self.__connection_string = None
@@ -74,12 +83,15 @@ class AtlasTestCase:
     def get_connection_string(self):
         if self.__connection_string is None:
             cluster = self.cluster_url.get().data
-            uri = re.sub(r'://',
-                '://%s:%s@' % (self.config.database_username, self.config.database_password),
+            self.__connection_string = self.add_credentials(
                 cluster.srvAddress)
-            self.__connection_string = uri
         return self.__connection_string
 
+    def add_credentials(self, uri):
+        return re.sub(r'://',
+            '://%s:%s@' % (self.config.database_username, self.config.database_password),
+            uri)
+
     def __repr__(self):
         return "<AtlasTestCase: {}>".format(self.id)
 
@@ -142,13 +154,26 @@ class AtlasTestCase:
             self.client.groups[self.project.id].\
                 clusters[self.cluster_name].processArgs.patch(**process_args)
 
-    def run(self, persist_cluster=False, startup_time=1):
+    def run(self, persist_cluster=False, startup_time=1, ready_timeout=0,
+            max_restarts=0, online_archive_uri=None):
         LOGGER.info("Running test {!r} on cluster {!r}".format(
             self.id, self.cluster_name))
 
         # Step-1: sanity-check the cluster configuration.
         self.verify_cluster_configuration_matches(self.spec.initialConfiguration)
 
+        archive_connection_string = None
+        if self.spec.get('onlineArchive'):
+            # The archive is created first, since its connection string is
+            # only known once it exists.
+            self.ensure_online_archive(self.spec.onlineArchive)
+            if not online_archive_uri:
+                raise AstrolabeTestCaseError(
+                    "Test {!r} configures an online archive, but no online "
+                    "archive connection string was given".format(self.id))
+            archive_connection_string = self.add_credentials(
+                online_archive_uri)
+
         # Start the test timer.
         timer = Timer()
         timer.start()
@@ -158,13 +183,41 @@ class AtlasTestCase:
             workload_executor=self.config.workload_executor,
             connection_string=self.get_connection_string(),
             driver_workload=self.spec.driverWorkload,
-            startup_time=startup_time)
+            startup_time=startup_time,
+            ready_timeout=ready_timeout,
+            max_restarts=max_restarts,
+            synthetic_load=self.spec.get('syntheticLoad'),
+            online_archive_uri=archive_connection_string,
+            test_name=self.id)
+        workload_start = _time.time()
+
+        # Record the start and end time of every operation so that the
+        # maintenance phases can be correlated with the executor output.
+        phases = []
+        self.workload_runner.mark_phase(DURING_MAINTENANCE)
+
+        # Certificate rotation is detected against the certificate the
+        # cluster presented when the test started.
+        initial_fingerprint = None
+        if any('waitForCertificateRotation' in operation
+               for operation in self.spec.operations):
+            initial_fingerprint = self.certificate_fingerprint()
+
+        # Point in time restores restore the data as of the completion of the
+        # last snapshot taken by the test.
+        snapshot_time = None
 
         for operation in self.spec.operations:
+            if self.workload_runner.budget_breached:
+                LOGGER.info("Skipping the remaining operations because the "
+                            "error budget of the workload was breached")
+                break
+
             if len(operation) != 1:
                 raise ValueError("Operation must have exactly one key: %s" % operation)
                 
             op_name, op_spec = list(operation.items())[0]
+            phase_start = _time.time()
             
             if op_name == 'setClusterConfiguration':
                 # Step-3: begin maintenance routine.
@@ -246,24 +299,114 @@ class AtlasTestCase:
                         else:
                             sleep(5)
                 
+            elif op_name == 'setMaintenanceWindow':
+                LOGGER.info("Setting the project maintenance window to "
+                            "{}".format(op_spec))
+                self.maintenance_window_changed = True
+                self.maintenance_window_url.patch(**op_spec)
+
+            elif op_name == 'deferMaintenance':
+                LOGGER.info("Deferring the scheduled project maintenance")
+                self.maintenance_window_url.defer.post()
+
+            elif op_name == 'startMaintenance':
+                self.start_maintenance(op_spec)
+
+            elif op_name == 'enableComputeAutoScaling':
+                self.enable_compute_auto_scaling(op_spec)
+
+            elif op_name == 'waitForComputeScaling':
+                self.wait_for_compute_scaling(op_spec)
+
+            elif op_name == 'waitForCertificateRotation':
+                self.wait_for_certificate_rotation(
+                    op_spec, initial_fingerprint)
+
+            elif op_name == 'createSearchIndex':
+                self.create_search_index(op_spec)
+
+            elif op_name == 'takeSnapshot':
+                self.take_snapshot(op_spec)
+                snapshot_time = _time.time()
+
+            elif op_name == 'restoreToPointInTime':
+                if snapshot_time is None:
+                    raise AstrolabeTestCaseError(
+                        "restoreToPointInTime requires a preceding "
+                        "takeSnapshot operation")
+                self.restore_to_point_in_time(op_spec, snapshot_time)
+
             else:
                 raise Exception('Unrecognized operation %s' % op_name)
 
+            phases.append(
+                {'name': op_name, 'start': phase_start, 'end': _time.time()})
+            self.workload_runner.log_progress()
+
+        self.workload_runner.mark_phase(AFTER_MAINTENANCE)
+
         # Wait 10 seconds to ensure that the driver is not experiencing any
         # errors after the maintenance has concluded.
-        sleep(10)
+        if not self.workload_runner.budget_breached:
+            sleep(10)
         
         # Step-5: interrupt driver workload and capture streams
         stats = self.workload_runner.stop()
+        workload_end = _time.time()
+        if self.workload_runner.restarts:
+            LOGGER.warning("The workload executor was restarted {} times "
+                           "during the test".format(
+                               self.workload_runner.restarts))
 
         # Stop the timer
         timer.stop()
 
+        self.write_phases(phases)
+        try:
+            generate_html_report(
+                results_path=self.workload_runner.sentinel,
+                events_path=self.workload_runner.events,
+                phases_path=self.workload_runner.phases,
+                topology_path=self.workload_runner.topology,
+                output_path=self.workload_runner.report,
+                title=self.id)
+        except Exception as exc:
+            LOGGER.warning("Could not generate HTML report: %s" % exc)
+
+        all_phases = phases + maintenance_phases(
+            phases, workload_start, workload_end)
+        phase_failures = check_phase_assertions(
+            self.spec.get('phaseAssertions', []), all_phases,
+            self.workload_runner.events)
+        allowed_errors, allowed_failures = allowed_by_phase_assertions(
+            self.spec.get('phaseAssertions', []), all_phases,
+            self.workload_runner.events, stats)
+        for message in phase_failures:
+            LOGGER.info("Phase assertion failed: {}".format(message))
+        latency_failures = check_latency_assertions(
+            self.spec.get('latencyAssertions', {}),
+            self.workload_runner.metrics)
+        for message in latency_failures:
+            LOGGER.info("Latency assertion failed: {}".format(message))
+
         # Step-6: compute xunit entry.
         junit_test = junitparser.TestCase(self.id)
         junit_test.time = timer.elapsed
 
-        if (stats['numErrors'] != 0 or stats['numFailures'] != 0 or
+        if stats.get('skipped'):
+            LOGGER.info("SKIPPED: {!r} ({})".format(
+                self.id, stats.get('skipReason')))
+            junit_test.result = junitparser.Skipped(
+                stats.get('skipReason', ''))
+        elif phase_failures or latency_failures:
+            LOGGER.info("FAILED: {!r}".format(self.id))
+            self.failed = True
+            junit_test.result = junitparser.Failure(
+                '; '.join(phase_failures + latency_failures))
+        elif (stats.get('budgetBreach') or
+                stats['numErrors'] > allowed_errors or
+                stats['numFailures'] > allowed_failures or
+                stats.get('outcomeFailures', 0) != 0 or
                 stats['numSuccesses'] == 0):
             LOGGER.info("FAILED: {!r}".format(self.id))
             self.failed = True
@@ -275,6 +418,10 @@ class AtlasTestCase:
             # is only visible for failed tests.
 
         LOGGER.info("Workload Statistics: {}".format(stats))
+
+        if self.maintenance_window_changed:
+            LOGGER.info("Resetting the project maintenance window")
+            self.maintenance_window_url.delete()
         
         get_logs(admin_client=self.admin_client,
             project=self.project, cluster_name=self.cluster_name)
@@ -288,6 +435,268 @@ class AtlasTestCase:
 
         return junit_test
         
+    @property
+    def maintenance_window_url(self):
+        return self.client.groups[self.project.id].maintenanceWindow
+
+    def start_maintenance(self, op_spec):
+        """Ask Atlas to begin the pending maintenance of the project right
+        away, rather than at the maintenance window, and wait for it to
+        finish. Atlas reports startASAP as true until the maintenance
+        completes."""
+        timeout = 1800
+        if isinstance(op_spec, dict):
+            timeout = op_spec.get('timeout', timeout)
+
+        LOGGER.info("Starting the pending project maintenance")
+        self.maintenance_window_changed = True
+        self.maintenance_window_url.patch(startASAP=True)
+
+        timer = Timer()
+        timer.start()
+        while self.maintenance_window_url.get().data.get('startASAP'):
+            if timer.elapsed > timeout:
+                raise PollingTimeoutError(
+                    "Project maintenance did not complete after %s seconds" % timeout)
+            LOGGER.info("Project maintenance in progress; waited for "
+                        "%.1f sec" % timer.elapsed)
+            sleep(1.0 / self.config.polling_frequency)
+
+        self.wait_for_idle()
+
+    def ensure_online_archive(self, archive_spec):
+        """Create the online archive of the namespace described by
+        ``archive_spec``, unless the cluster already has one, and wait until
+        it is active."""
+        archives_url = self.cluster_url.onlineArchives
+        archive = None
+        for existing in archives_url.get().data.get('results', []):
+            if (existing['dbName'] == archive_spec['database'] and
+                    existing['collName'] == archive_spec['collection']):
+                archive = existing
+                break
+
+        if archive is None:
+            LOGGER.info("Configuring the online archive of {}.{}".format(
+                archive_spec['database'], archive_spec['collection']))
+            archive = archives_url.post(
+                dbName=archive_spec['database'],
+                collName=archive_spec['collection'],
+                criteria={
+                    'type': 'DATE',
+                    'dateField': archive_spec['dateField'],
+                    'expireAfterDays': archive_spec.get(
+                        'expireAfterDays', 1)}).data
+
+        timeout = archive_spec.get('timeout', 1800)
+        timer = Timer()
+        timer.start()
+        while archive['state'] != 'ACTIVE':
+            if timer.elapsed > timeout:
+                raise PollingTimeoutError(
+                    "The online archive did not become active after %s "
+                    "seconds" % timeout)
+            LOGGER.info("Online archive is {}; waited for {:.1f} sec".format(
+                archive['state'], timer.elapsed))
+            sleep(1.0 / self.config.polling_frequency)
+            archive = archives_url[archive['_id']].get().data
+        LOGGER.info("The online archive is active")
+
+    def enable_compute_auto_scaling(self, op_spec):
+        """Let Atlas scale the instance size of the cluster between the
+        given bounds with its CPU and memory utilization."""
+        provider_settings = self.cluster_url.get().data.providerSettings
+        LOGGER.info("Enabling compute auto-scaling between {} and {}".format(
+            op_spec['minInstanceSize'], op_spec['maxInstanceSize']))
+        self.cluster_url.patch(
+            autoScaling={'compute': {
+                'enabled': True,
+                'scaleDownEnabled': op_spec.get('scaleDownEnabled', False)}},
+            providerSettings={
+                'providerName': provider_settings.providerName,
+                'regionName': provider_settings.regionName,
+                'instanceSizeName': provider_settings.instanceSizeName,
+                'autoScaling': {'compute': {
+                    'minInstanceSize': op_spec['minInstanceSize'],
+                    'maxInstanceSize': op_spec['maxInstanceSize']}}})
+        self.wait_for_idle()
+
+    def wait_for_compute_scaling(self, op_spec):
+        """Wait until auto-scaling changes the instance size of the cluster
+        from the one of the initial configuration, and for the replacement
+        of the nodes to complete."""
+        timeout = 7200
+        if isinstance(op_spec, dict):
+            timeout = op_spec.get('timeout', timeout)
+        initial_size = self.spec.initialConfiguration.clusterConfiguration.\
+            providerSettings.instanceSizeName
+
+        LOGGER.info("Waiting for auto-scaling to change the instance size "
+                    "from {}".format(initial_size))
+        timer = Timer()
+        timer.start()
+        while True:
+            size = self.cluster_url.get().data.providerSettings.instanceSizeName
+            if size != initial_size:
+                break
+            if timer.elapsed > timeout:
+                raise PollingTimeoutError(
+                    "The cluster was not auto-scaled after %s seconds" % timeout)
+            LOGGER.info("Cluster not auto-scaled yet; waited for %.1f sec" %
+                        timer.elapsed)
+            sleep(30)
+
+        LOGGER.info("The cluster was auto-scaled to {}".format(size))
+        self.wait_for_idle()
+
+    def create_search_index(self, op_spec):
+        """Create an Atlas Search index on a collection of the cluster and
+        wait until it can be queried. An existing index of the same name is
+        reused."""
+        name = op_spec.get('name', 'default')
+        timeout = op_spec.get('timeout', 1800)
+        indexes_url = self.cluster_url.fts.indexes
+
+        index = None
+        for existing in indexes_url[op_spec['database']][
+                op_spec['collection']].get().data:
+            if existing['name'] == name:
+                index = existing
+                break
+
+        if index is None:
+            LOGGER.info("Creating the Atlas Search index {!r} of {}.{}".format(
+                name, op_spec['database'], op_spec['collection']))
+            index = indexes_url.post(
+                database=op_spec['database'],
+                collectionName=op_spec['collection'],
+                name=name,
+                mappings=op_spec.get('mappings', {'dynamic': True})).data
+
+        timer = Timer()
+        timer.start()
+        while index.get('status') != 'STEADY':
+            if index.get('status') == 'FAILED':
+                raise AstrolabeTestCaseError(
+                    "Atlas Search index {!r} failed to build".format(name))
+            if timer.elapsed > timeout:
+                raise PollingTimeoutError(
+                    "Atlas Search index was not built after %s seconds" %
+                    timeout)
+            LOGGER.info("Atlas Search index is {}; waited for {:.1f} "
+                        "sec".format(index.get('status'), timer.elapsed))
+            sleep(1.0 / self.config.polling_frequency)
+            index = indexes_url[index['indexID']].get().data
+        LOGGER.info("Atlas Search index {!r} is ready".format(name))
+
+    def take_snapshot(self, op_spec):
+        """Take an on-demand cloud backup snapshot of the cluster and wait
+        until it completes. The cluster must have cloud backups enabled."""
+        if not isinstance(op_spec, dict):
+            op_spec = {}
+        timeout = op_spec.get('timeout', 3600)
+
+        snapshots_url = self.cluster_url.backup.snapshots
+        LOGGER.info("Taking an on-demand snapshot of the cluster")
+        snapshot = snapshots_url.post(
+            description=op_spec.get('description', self.id),
+            retentionInDays=op_spec.get('retentionInDays', 1)).data
+
+        timer = Timer()
+        timer.start()
+        while snapshot['status'] != 'completed':
+            if snapshot['status'] == 'failed':
+                raise AstrolabeTestCaseError(
+                    "Snapshot {} failed".format(snapshot['id']))
+            if timer.elapsed > timeout:
+                raise PollingTimeoutError(
+                    "Snapshot did not complete after %s seconds" % timeout)
+            LOGGER.info("Snapshot is {}; waited for {:.1f} sec".format(
+                snapshot['status'], timer.elapsed))
+            sleep(1.0 / self.config.polling_frequency)
+            snapshot = snapshots_url[snapshot['id']].get().data
+        LOGGER.info("Snapshot {} completed".format(snapshot['id']))
+
+        self.wait_for_idle()
+
+    def restore_to_point_in_time(self, op_spec, point_in_time):
+        """Restore the cluster onto itself as of ``point_in_time``, in
+        seconds since the epoch, and wait until the restore job finishes and
+        the cluster is idle. The cluster must have continuous cloud backups
+        enabled."""
+        if not isinstance(op_spec, dict):
+            op_spec = {}
+        timeout = op_spec.get('timeout', 7200)
+
+        restore_jobs_url = self.cluster_url.backup.restoreJobs
+        LOGGER.info("Restoring the cluster to {}".format(
+            datetime.datetime.utcfromtimestamp(point_in_time).isoformat()))
+        job = restore_jobs_url.post(
+            deliveryType='pointInTime',
+            pointInTimeUTCSeconds=int(point_in_time),
+            targetClusterName=self.cluster_name,
+            targetGroupId=self.project.id).data
+
+        timer = Timer()
+        timer.start()
+        while not job.get('finishedAt'):
+            if job.get('failed') or job.get('cancelled') or job.get('expired'):
+                raise AstrolabeTestCaseError(
+                    "Restore job {} did not complete".format(job['id']))
+            if timer.elapsed > timeout:
+                raise PollingTimeoutError(
+                    "Restore did not complete after %s seconds" % timeout)
+            LOGGER.info("Restore in progress; waited for %.1f sec" %
+                        timer.elapsed)
+            sleep(1.0 / self.config.polling_frequency)
+            job = restore_jobs_url[job['id']].get().data
+        LOGGER.info("Restore job {} finished".format(job['id']))
+
+        self.wait_for_idle()
+
+    def certificate_fingerprint(self):
+        """Return the SHA-256 fingerprint of the TLS certificate presented
+        by the first host of the cluster."""
+        with mongo_client(self.get_connection_string()) as mc:
+            mc.admin.command('ping')
+            address = sorted(mc.nodes)[0]
+        pem = ssl.get_server_certificate(address)
+        return hashlib.sha256(ssl.PEM_cert_to_DER_cert(pem)).hexdigest()
+
+    def wait_for_certificate_rotation(self, op_spec, initial_fingerprint):
+        timeout = 3600
+        if isinstance(op_spec, dict):
+            timeout = op_spec.get('timeout', timeout)
+
+        LOGGER.info("Waiting for the certificate of the cluster to be "
+                    "rotated")
+        timer = Timer()
+        timer.start()
+        while True:
+            try:
+                fingerprint = self.certificate_fingerprint()
+            except Exception as exc:
+                # The host may be restarting with its new certificate.
+                LOGGER.info("Could not fetch the certificate of the "
+                            "cluster: {}".format(exc))
+            else:
+                if fingerprint != initial_fingerprint:
+                    break
+
+            if timer.elapsed > timeout:
+                raise PollingTimeoutError(
+                    "The certificate of the cluster was not rotated after "
+                    "%s seconds" % timeout)
+            LOGGER.info("Certificate not rotated yet; waited for %.1f sec" %
+                        timer.elapsed)
+            sleep(5)
+
+        LOGGER.info("The certificate of the cluster was rotated")
+
+    def write_phases(self, phases):
+        with open(self.workload_runner.phases, 'w') as fp:
+            json.dump({'phases': phases}, fp)
+
     def wait_for_idle(self):
         # Small delay to account for Atlas not updating cluster state
         # synchronously potentially in all maintenance operations
@@ -319,7 +728,9 @@ class AtlasTestCase:
 class SpecTestRunnerBase:
     """Base class for spec test runners."""
     def __init__(self, *, client, admin_client, test_locator_token, configuration, xunit_output,
-                 persist_clusters, no_create, workload_startup_time):
+                 persist_clusters, no_create, workload_startup_time,
+                 workload_ready_timeout=0, workload_max_restarts=0,
+                 online_archive_uri=None):
         self.cases = []
         self.client = client
         self.admin_client = admin_client
@@ -328,6 +739,9 @@ class SpecTestRunnerBase:
         self.persist_clusters = persist_clusters
         self.no_create = no_create
         self.workload_startup_time = workload_startup_time
+        self.workload_ready_timeout = workload_ready_timeout
+        self.workload_max_restarts = workload_max_restarts
+        self.online_archive_uri = online_archive_uri
 
         for full_path in self.find_spec_tests(test_locator_token):
             # Step-1: load test specification.
@@ -416,7 +830,10 @@ class SpecTestRunnerBase:
 
             # Run the case.
             xunit_test = active_case.run(persist_cluster=self.persist_clusters,
-                                         startup_time=self.workload_startup_time)
+                                         startup_time=self.workload_startup_time,
+                                         ready_timeout=self.workload_ready_timeout,
+                                         max_restarts=self.workload_max_restarts,
+                                         online_archive_uri=self.online_archive_uri)
             # Write xunit entry for case.
             self.xunit_logger.write_xml(
                 test_case=xunit_test,
@@ -20,10 +20,11 @@ import subprocess
 import sys
 import re
 import socket
+import threading
 import requests.packages.urllib3.util.connection as urllib3_cn
 from hashlib import sha256
 from contextlib import closing
-from time import sleep
+from time import monotonic, sleep
 
 import click
 import junitparser
@@ -33,6 +34,7 @@ from pymongo import MongoClient
 from .exceptions import (
     WorkloadExecutorError, AstrolabeTestCaseError, PrematureExitError
 )
+from .phases import BEFORE_MAINTENANCE
 from .poller import poll
 
 
@@ -164,6 +166,20 @@ def mongo_client(connection_string):
     return closing(client)
 
 
+# Newest version of the results.json format astrolabe understands. It is
+# requested from workload executors, which may write any older version.
+RESULTS_SCHEMA_VERSION = 2
+
+# Exit status of workload executors that stopped the workload early because
+# its error budget was breached.
+BUDGET_BREACHED_EXIT_CODE = 3
+
+# Exit status of workload executors that failed because of a problem of the
+# executor or of the workload, e.g. an invalid workload, rather than of the
+# driver or the cluster. Relaunching such an executor would fail the same way.
+HARNESS_ERROR_EXIT_CODE = 5
+
+
 class DriverWorkloadSubprocessRunner:
     """Convenience wrapper to run a workload executor in a subprocess."""
 
@@ -174,6 +190,30 @@ class DriverWorkloadSubprocessRunner:
         self.workload_subprocess = None
         self.sentinel = os.path.join(os.path.abspath(os.curdir), 'results.json')
         self.events = os.path.join(os.path.abspath(os.curdir), 'events.json')
+        self.phases = os.path.join(os.path.abspath(os.curdir), 'phases.json')
+        self.topology = os.path.join(
+            os.path.abspath(os.curdir), 'topology-timeline.json')
+        self.report = os.path.join(os.path.abspath(os.curdir), 'report.html')
+        self.ready = os.path.join(os.path.abspath(os.curdir), 'ready.json')
+        self.metrics = os.path.join(os.path.abspath(os.curdir), 'metrics.json')
+        self.sdam_events = os.path.join(
+            os.path.abspath(os.curdir), 'sdam-events.json')
+        self.progress = os.path.join(
+            os.path.abspath(os.curdir), 'progress.json')
+        self.phase_marker = os.path.join(
+            os.path.abspath(os.curdir), 'phase.json')
+        self.checkpoint = os.path.join(
+            os.path.abspath(os.curdir), 'checkpoint.json')
+        self.workload_file = os.path.join(
+            os.path.abspath(os.curdir), 'workload.json')
+
+        # State of the supervisor that relaunches the workload executor if
+        # it crashes, see spawn.
+        self.max_restarts = 0
+        self.restarts = 0
+        self._stopping = False
+        self._supervisor = None
+        self._lock = threading.Lock()
 
     @property
     def pid(self):
@@ -183,8 +223,63 @@ class DriverWorkloadSubprocessRunner:
     def returncode(self):
         return self.workload_subprocess.returncode
 
+    @property
+    def budget_breached(self):
+        """Whether the workload executor has exited because the error budget
+        of the workload was breached."""
+        return self.workload_subprocess.poll() == BUDGET_BREACHED_EXIT_CODE
+
+    def probe_capabilities(self, workload_executor, timeout=10):
+        """Ask the workload executor what it supports by running it with
+        ``--capabilities``. Returns the parsed capabilities, or an empty dict
+        if the executor does not support the flag, in which case the executor
+        is assumed to support only what every executor must."""
+        args = [workload_executor, '--capabilities']
+        if self.is_windows:
+            args = ['C:/cygwin/bin/bash'] + args
+        try:
+            output = subprocess.run(
+                args, stdout=subprocess.PIPE, stderr=subprocess.DEVNULL,
+                timeout=timeout, check=True).stdout
+            capabilities = json.loads(output.decode('utf-8'))
+        except (OSError, subprocess.SubprocessError, ValueError) as exc:
+            LOGGER.info("Workload executor did not report its capabilities "
+                        "({})".format(exc))
+            return {}
+        if not isinstance(capabilities, dict):
+            return {}
+        LOGGER.info("Workload executor capabilities: {}".format(
+            json.dumps(capabilities)))
+        return capabilities
+
     def spawn(self, *, workload_executor, connection_string, driver_workload,
-              startup_time=1):
+              startup_time=1, ready_timeout=0, max_restarts=0,
+              synthetic_load=None, online_archive_uri=None, test_name=None):
+        """Start the workload executor. If ``max_restarts`` is non-zero, a
+        supervisor thread relaunches the executor up to that many times if
+        it exits before it is stopped. The relaunched executor resumes the
+        counters it checkpointed to ``checkpoint.json``. If
+        ``synthetic_load`` is given, the executor puts the cluster under
+        that load alongside the workload. Client entities of the workload
+        with ``useOnlineArchive`` connect to ``online_archive_uri``.
+        ``test_name`` identifies the test in the metrics executors report
+        to the deployment at ``ASTROLABE_REPORT_URI``, if it is set."""
+        capabilities = self.probe_capabilities(workload_executor)
+        results_version = RESULTS_SCHEMA_VERSION
+        supported = capabilities.get('resultsSchemaVersions')
+        if supported:
+            results_version = min(RESULTS_SCHEMA_VERSION, max(supported))
+        channels = capabilities.get('controlChannels')
+        if ready_timeout and channels is not None and 'readyFile' not in channels:
+            LOGGER.info("Not waiting for readiness, which the workload "
+                        "executor does not signal")
+            ready_timeout = 0
+        if (synthetic_load and channels is not None and
+                'syntheticLoad' not in channels):
+            LOGGER.warning("The workload executor does not support synthetic "
+                           "load; the cluster may not be loaded enough for "
+                           "the test")
+
         LOGGER.info("Starting workload executor subprocess")
 
         try:
@@ -193,25 +288,57 @@ class DriverWorkloadSubprocessRunner:
         except FileNotFoundError:
             pass
 
-        try:
-            os.remove(self.events)
-            LOGGER.debug("Cleaned up events file at {}".format(self.events))
-        except FileNotFoundError:
-            pass
-
-        _args = [workload_executor, connection_string, json.dumps(driver_workload)]
+        for path in (self.events, self.phases, self.topology, self.report,
+                     self.metrics, self.sdam_events, self.ready,
+                     self.progress, self.checkpoint, self.workload_file):
+            try:
+                os.remove(path)
+                LOGGER.debug("Cleaned up file at {}".format(path))
+            except FileNotFoundError:
+                pass
+
+        # Workload executors that support readiness signaling write this
+        # file once the workload has reached a steady state. Those that
+        # support phase markers split their statistics by the phase named
+        # in the phase file.
+        self.mark_phase(BEFORE_MAINTENANCE)
+        env = dict(os.environ, ASTROLABE_READY_FILE=self.ready,
+                   ASTROLABE_PROGRESS_FILE=self.progress,
+                   ASTROLABE_PHASE_FILE=self.phase_marker,
+                   ASTROLABE_CHECKPOINT_FILE=self.checkpoint,
+                   ASTROLABE_RESULTS_SCHEMA_VERSION=str(results_version))
+        if synthetic_load:
+            env['ASTROLABE_SYNTHETIC_LOAD'] = json.dumps(synthetic_load)
+        if online_archive_uri:
+            env['ASTROLABE_ONLINE_ARCHIVE_URI'] = online_archive_uri
+        if test_name:
+            env['ASTROLABE_TEST_NAME'] = test_name
+
+        # Large workloads exceed the argument size limits of some platforms,
+        # so they are passed in a file to executors that can read one.
+        if channels is not None and 'workloadFile' in channels:
+            with open(self.workload_file, 'w') as fp:
+                json.dump(driver_workload, fp)
+            _args = [workload_executor, '--workload-file', self.workload_file,
+                     connection_string]
+        else:
+            _args = [workload_executor, connection_string,
+                     json.dumps(driver_workload)]
         if not self.is_windows:
             args = _args
-            self.workload_subprocess = subprocess.Popen(
-                args, preexec_fn=os.setsid)
+            self._popen_kwargs = dict(preexec_fn=os.setsid, env=env)
         else:
             args = ['C:/cygwin/bin/bash']
             args.extend(_args)
-            self.workload_subprocess = subprocess.Popen(
-                args, creationflags=subprocess.CREATE_NEW_PROCESS_GROUP)
-
+            self._popen_kwargs = dict(
+                creationflags=subprocess.CREATE_NEW_PROCESS_GROUP, env=env)
+        self._popen_args = args
+
+        self.max_restarts = max_restarts
+        self.restarts = 0
+        self._stopping = False
+        self._launch()
         LOGGER.debug("Subprocess argument list: {}".format(args))
-        LOGGER.info("Started workload executor [PID: {}]".format(self.pid))
 
         try:
             # Wait for the workload executor to start.
@@ -226,13 +353,92 @@ class DriverWorkloadSubprocessRunner:
             raise WorkloadExecutorError(
                 "Workload executor quit without receiving termination signal")
 
+        if ready_timeout:
+            self.wait_until_ready(ready_timeout)
+
+        if max_restarts:
+            self._supervisor = threading.Thread(
+                target=self._supervise, daemon=True)
+            self._supervisor.start()
+
         return self.workload_subprocess
 
+    def _launch(self):
+        self.workload_subprocess = subprocess.Popen(
+            self._popen_args, **self._popen_kwargs)
+        LOGGER.info("Started workload executor [PID: {}]".format(self.pid))
+
+    def _supervise(self):
+        """Relaunch the workload executor whenever it exits before being
+        stopped, other than because its error budget was breached or
+        because of a harness error, until max_restarts is reached."""
+        while True:
+            returncode = self.workload_subprocess.wait()
+            with self._lock:
+                if self._stopping or returncode == BUDGET_BREACHED_EXIT_CODE:
+                    return
+                if returncode == HARNESS_ERROR_EXIT_CODE:
+                    LOGGER.error("Workload executor [PID: {}] exited with a "
+                                 "harness error; not restarting it".format(
+                                     self.pid))
+                    return
+                if self.restarts >= self.max_restarts:
+                    LOGGER.error("Workload executor [PID: {}] exited with "
+                                 "status {} and was already restarted {} "
+                                 "times".format(self.pid, returncode,
+                                                self.restarts))
+                    return
+                self.restarts += 1
+                LOGGER.warning("Workload executor [PID: {}] exited with "
+                               "status {}; restarting it ({} of {})".format(
+                                   self.pid, returncode, self.restarts,
+                                   self.max_restarts))
+                self._launch()
+
+    def mark_phase(self, name):
+        """Name the phase the test is in for the workload executor. The
+        file is replaced atomically so that the executor never reads a
+        partial marker."""
+        LOGGER.info("Entering phase {!r}".format(name))
+        tmp = self.phase_marker + '.tmp'
+        with open(tmp, 'w') as fp:
+            json.dump({'name': name}, fp)
+        os.replace(tmp, self.phase_marker)
+
+    def wait_until_ready(self, timeout):
+        """Wait up to ``timeout`` seconds for the workload executor to write
+        the ready file. Executors that do not support readiness signaling
+        never write the file, in which case a warning is logged once the
+        timeout expires and the test proceeds as if the executor was ready."""
+        LOGGER.info("Waiting up to {} seconds for the workload executor "
+                    "to report that the workload is ready".format(timeout))
+        deadline = monotonic() + timeout
+        while monotonic() < deadline:
+            if os.path.exists(self.ready):
+                LOGGER.info("Workload executor is ready")
+                return
+            if self.workload_subprocess.poll() is not None:
+                raise WorkloadExecutorError(
+                    "Workload executor quit before the workload was ready")
+            sleep(0.5)
+        LOGGER.warning("Workload executor did not report readiness within "
+                       "{} seconds".format(timeout))
+
     def stop(self):
         '''Stop the process, verifying it didn't already exit.'''
         
+        if self.budget_breached:
+            LOGGER.info("Workload executor [PID: {}] already exited because "
+                        "the error budget was breached".format(self.pid))
+            return self.read_stats()
+
         LOGGER.info("Stopping workload executor [PID: {}]".format(self.pid))
-        
+
+        # Hold the lock so that the supervisor does not relaunch the
+        # executor once it exits.
+        with self._lock:
+            self._stopping = True
+
         try:
             if not self.is_windows:
                 os.killpg(self.workload_subprocess.pid, signal.SIGINT)
@@ -247,6 +453,9 @@ class DriverWorkloadSubprocessRunner:
         try:
             self.workload_subprocess.wait(timeout=t_wait)
             LOGGER.info("Stopped workload executor [PID: {}]".format(self.pid))
+            if self._supervisor is not None:
+                self._supervisor.join()
+                self._supervisor = None
         except subprocess.TimeoutExpired:
             raise WorkloadExecutorError(
                 "The workload executor did not terminate {} seconds "
@@ -267,15 +476,44 @@ class DriverWorkloadSubprocessRunner:
             with open(self.sentinel, 'r') as fp:
                 stats = json.load(fp)
                 LOGGER.info("Sentinel contains: %s" % json.dumps(stats))
+                LOGGER.debug("Results schema version: {}".format(
+                    stats.get('schemaVersion', 1)))
                 return stats
         except FileNotFoundError:
             LOGGER.error("Sentinel file not found")
-            raise WorkloadExecutorError("The workload executor did not write a results.json file in the expected location")
+            message = "The workload executor did not write a results.json file in the expected location"
+            progress = self.read_progress()
+            if progress is not None:
+                message += "; its last progress report had {numErrors} errors, {numFailures} failures and {numSuccesses} successes".format(**progress)
+            raise WorkloadExecutorError(message)
         except json.JSONDecodeError:
             LOGGER.error("Sentinel file contains malformed JSON")
             raise WorkloadExecutorError("The workload executor wrote a results.json that contained malformed JSON.")
 
 
+    def read_progress(self):
+        """Return the counts the workload executor last wrote to the
+        progress file, or None if it has not written one. Executors that do
+        not report progress never write the file."""
+        try:
+            with open(self.progress, 'r') as fp:
+                progress = json.load(fp)
+        except (FileNotFoundError, json.JSONDecodeError):
+            return None
+        for key in ('numErrors', 'numFailures', 'numSuccesses'):
+            progress.setdefault(key, -1)
+        return progress
+
+    def log_progress(self):
+        """Log the counts the workload executor last reported, if any."""
+        progress = self.read_progress()
+        if progress is None:
+            return
+        LOGGER.info("Workload executor progress: {numErrors} errors, "
+                    "{numFailures} failures, {numSuccesses} successes".format(
+                        **progress))
+
+
 def get_logs(admin_client, project, cluster_name):
     LOGGER.info(f'Retrieving logs for {cluster_name}')
     data = admin_client.nds.groups[project.id].clusters[cluster_name].get(api_version='private').data
@@ -0,0 +1,150 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// The assertion operations check the state of the cluster while the workload runs, e.g. at the end
+// of a phase of the workload. Like the outcome of the workload they read from the primary with a
+// majority read concern, but an assertion that does not hold is counted as a failure of the
+// operation rather than as an outcome failure. The databaseName and collectionName arguments
+// default to those of the workload collection.
+
+// assertionTarget holds the namespace arguments shared by the assertion operations.
+type assertionTarget struct {
+	databaseName   string
+	collectionName string
+}
+
+// parseTarget handles the namespace arguments of an assertion and reports whether key was one.
+func (t *assertionTarget) parseTarget(key string, val bson.RawValue) bool {
+	switch key {
+	case "databaseName":
+		t.databaseName = val.StringValue()
+	case "collectionName":
+		t.collectionName = val.StringValue()
+	default:
+		return false
+	}
+	return true
+}
+
+// database returns the database of the target, defaulting to that of the workload collection.
+func (t *assertionTarget) database(r *workloadRunner) *mongo.Database {
+	name := t.databaseName
+	if name == "" {
+		name = r.coll.Database().Name()
+	}
+	return r.client.Database(name, options.Database().
+		SetReadPreference(readpref.Primary()).
+		SetReadConcern(readconcern.Majority()))
+}
+
+// collection returns the name of the collection of the target, defaulting to the workload
+// collection.
+func (t *assertionTarget) collection(r *workloadRunner) string {
+	if t.collectionName == "" {
+		return r.coll.Name()
+	}
+	return t.collectionName
+}
+
+// executeAssertCollectionExists checks that the collection exists.
+func (r *workloadRunner) executeAssertCollectionExists(args bson.Raw) (bool, error) {
+	var target assertionTarget
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		if !target.parseTarget(elem.Key(), elem.Value()) {
+			return false, harnessError(fmt.Errorf("unrecognized assertCollectionExists option: %v", elem.Key()))
+		}
+	}
+
+	db := target.database(r)
+	collName := target.collection(r)
+	names, err := db.ListCollectionNames(context.Background(), bson.D{{Key: "name", Value: collName}})
+	if err != nil {
+		return false, err
+	}
+	if len(names) == 0 {
+		return false, &failure{msg: fmt.Sprintf("collection %s.%s does not exist", db.Name(), collName)}
+	}
+	return true, nil
+}
+
+// executeAssertIndexExists checks that the collection has an index named by the indexName argument.
+func (r *workloadRunner) executeAssertIndexExists(args bson.Raw) (bool, error) {
+	var target assertionTarget
+	var indexName string
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch {
+		case target.parseTarget(key, val):
+		case key == "indexName":
+			indexName = val.StringValue()
+		default:
+			return false, harnessError(fmt.Errorf("unrecognized assertIndexExists option: %v", key))
+		}
+	}
+
+	db := target.database(r)
+	collName := target.collection(r)
+	cursor, err := db.Collection(collName).Indexes().List(context.Background())
+	if err != nil {
+		return false, err
+	}
+	var indexes []bson.Raw
+	if err := cursor.All(context.Background(), &indexes); err != nil {
+		return false, err
+	}
+	for _, index := range indexes {
+		if name, ok := index.Lookup("name").StringValueOK(); ok && name == indexName {
+			return true, nil
+		}
+	}
+	return false, &failure{msg: fmt.Sprintf("index %s does not exist on %s.%s", indexName, db.Name(), collName)}
+}
+
+// executeAssertDocumentCount checks that the number of documents in the collection matching the
+// optional filter argument is the count argument, as judged by the verifier of the workload.
+func (r *workloadRunner) executeAssertDocumentCount(args bson.Raw) (bool, error) {
+	var target assertionTarget
+	filter := emptyDoc
+	var expected int64
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch {
+		case target.parseTarget(key, val):
+		case key == "filter":
+			filter = val.Document()
+		case key == "count":
+			expected = val.AsInt64()
+		default:
+			return false, harnessError(fmt.Errorf("unrecognized assertDocumentCount option: %v", key))
+		}
+	}
+
+	db := target.database(r)
+	collName := target.collection(r)
+	actual, err := db.Collection(collName).CountDocuments(context.Background(), filter)
+	if err != nil {
+		return false, err
+	}
+	if !r.verifier.countsMatch(expected, actual) {
+		return false, &failure{msg: fmt.Sprintf(
+			"%s.%s has %d matching documents, expected %d", db.Name(), collName, actual, expected)}
+	}
+	return true, nil
+}
@@ -0,0 +1,59 @@
+package executor
+
+import (
+	"time"
+)
+
+// iterationBackoff makes the executor wait after an iteration of the workload in which an
+// operation errored, instead of starting the next iteration immediately. While the cluster is
+// unavailable this keeps the executor from flooding the events and errors with the same error,
+// which would also make the error counts depend on how quickly the driver fails. The wait doubles
+// after each consecutive errored iteration, up to a maximum, and is reset by an iteration without
+// errors.
+type iterationBackoff struct {
+	// wait after the first errored iteration; defaults to 100
+	InitialMS int64 `bson:"initialMS"`
+	// upper bound of the wait; defaults to 10000
+	MaxMS int64 `bson:"maxMS"`
+}
+
+// BackoffStats holds the waits made after errored iterations.
+type BackoffStats struct {
+	NumBackoffs int     `json:"numBackoffs"`
+	TotalMS     float64 `json:"totalMS"`
+}
+
+// backoffAfterIteration waits after an iteration according to the iteration backoff of the
+// workload, if it has one, and reports whether the workload was not stopped in the meantime.
+func (r *workloadRunner) backoffAfterIteration(config *iterationBackoff, errored bool) bool {
+	if config == nil {
+		return true
+	}
+	if !errored {
+		r.iterationDelay = 0
+		return true
+	}
+
+	if r.iterationDelay == 0 {
+		r.iterationDelay = 100 * time.Millisecond
+		if config.InitialMS > 0 {
+			r.iterationDelay = time.Duration(config.InitialMS) * time.Millisecond
+		}
+	} else {
+		r.iterationDelay *= 2
+	}
+	maxDelay := 10 * time.Second
+	if config.MaxMS > 0 {
+		maxDelay = time.Duration(config.MaxMS) * time.Millisecond
+	}
+	if r.iterationDelay > maxDelay {
+		r.iterationDelay = maxDelay
+	}
+
+	if r.results.IterationBackoff == nil {
+		r.results.IterationBackoff = &BackoffStats{}
+	}
+	r.results.IterationBackoff.NumBackoffs++
+	r.results.IterationBackoff.TotalMS += milliseconds(r.iterationDelay)
+	return r.pause(r.iterationDelay)
+}
@@ -0,0 +1,69 @@
+package executor
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// errorBudget declares thresholds beyond which the workload has obviously failed. When one is
+// breached, the executor stops the workload early and records the breach in the results, so that
+// a scenario with hours of maintenance left does not have to run to the end to report its failure.
+type errorBudget struct {
+	// number of errors the workload may report; zero for no limit
+	MaxErrors int `bson:"maxErrors"`
+	// time for which every operation may error; zero for no limit
+	MaxContinuousDowntimeMS int64 `bson:"maxContinuousDowntimeMS"`
+}
+
+// BudgetBreach describes the error budget threshold that stopped the workload.
+type BudgetBreach struct {
+	Threshold string  `json:"threshold"`
+	Limit     float64 `json:"limit"`
+	Actual    float64 `json:"actual"`
+}
+
+// checkErrorBudget updates the current downtime with the outcome of an operation and records a
+// breach of the error budget of the workload, if it has one. It reports whether the workload may
+// continue.
+func (r *workloadRunner) checkErrorBudget(budget *errorBudget, err error) bool {
+	if budget == nil {
+		return true
+	}
+	if _, isFailure := err.(*failure); err != nil && !isFailure {
+		if r.downSince.IsZero() {
+			r.downSince = time.Now()
+		}
+	} else {
+		r.downSince = time.Time{}
+	}
+
+	// the workers of a run share the budget
+	numErrors := int(atomic.LoadInt64(r.runErrors))
+	var breach *BudgetBreach
+	switch {
+	case budget.MaxErrors > 0 && numErrors > budget.MaxErrors:
+		breach = &BudgetBreach{
+			Threshold: "maxErrors",
+			Limit:     float64(budget.MaxErrors),
+			Actual:    float64(numErrors),
+		}
+	case budget.MaxContinuousDowntimeMS > 0 && !r.downSince.IsZero():
+		downtime := milliseconds(time.Since(r.downSince))
+		if downtime > float64(budget.MaxContinuousDowntimeMS) {
+			breach = &BudgetBreach{
+				Threshold: "maxContinuousDowntimeMS",
+				Limit:     float64(budget.MaxContinuousDowntimeMS),
+				Actual:    downtime,
+			}
+		}
+	}
+	if breach == nil {
+		return true
+	}
+
+	r.results.BudgetBreach = breach
+	r.recordFailure(fmt.Sprintf("error budget breached: %s is %v, the limit is %v",
+		breach.Threshold, breach.Actual, breach.Limit))
+	return false
+}
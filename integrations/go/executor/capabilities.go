@@ -0,0 +1,73 @@
+package executor
+
+// Capabilities describes what the executor supports, so that astrolabe can adapt to each driver's
+// executor instead of assuming what every executor supports.
+type Capabilities struct {
+	// formats of the workloads the executor runs; "legacy" is the driverWorkload format with
+	// top-level operations, and "unified" the unified test format run by RunUnified
+	WorkloadSchemaVersions []string `json:"workloadSchemaVersions"`
+	// versions of the results.json format the executor can write, see VersionedResultsFileSink
+	ResultsSchemaVersions []int `json:"resultsSchemaVersions"`
+	// files and streams the executor can write
+	OutputFormats []string `json:"outputFormats"`
+	// ways the orchestrator can control the executor, or the executor can signal the orchestrator
+	ControlChannels []string `json:"controlChannels"`
+}
+
+// ExecutorCapabilities returns the capabilities of this executor.
+func ExecutorCapabilities() *Capabilities {
+	versions := make([]int, 0, LatestResultsSchemaVersion)
+	for v := 1; v <= LatestResultsSchemaVersion; v++ {
+		versions = append(versions, v)
+	}
+	workloads := []string{"legacy"}
+	if unifiedRunnerAvailable {
+		workloads = append(workloads, "unified")
+	}
+	return &Capabilities{
+		WorkloadSchemaVersions: workloads,
+		ResultsSchemaVersions:  versions,
+		OutputFormats: []string{
+			"results.json",
+			"events.json",
+			"events.json.partial",
+			"topology-timeline.json",
+			"metrics.json",
+			"sdam-events.json",
+			"heap.pprof",
+			"tap",
+			"mongodbReport",
+		},
+		ControlChannels: []string{
+			// SIGINT or SIGTERM stops the workload
+			"terminationSignal",
+			// ASTROLABE_READY_FILE is written once the workload is ready
+			"readyFile",
+			// SIGHUP reloads the file given by -workload-file
+			"workloadReload",
+			// -workload-file reads the workload from a file, or from stdin if it is -, instead of
+			// the command line
+			"workloadFile",
+			// exit status 3 reports a breached error budget
+			"budgetBreachExitStatus",
+			// ASTROLABE_RESULTS_SCHEMA_VERSION selects the results.json format
+			"resultsSchemaVersion",
+			// ASTROLABE_PHASE_FILE names the current phase of the test
+			"phaseFile",
+			// ASTROLABE_PROGRESS_FILE is replaced with the counters at every flush
+			"progressFile",
+			// ASTROLABE_CHECKPOINT_FILE lets a restarted executor resume the counters of a crashed one
+			"checkpointFile",
+			// ASTROLABE_SYNTHETIC_LOAD puts the cluster under load alongside the workload
+			"syntheticLoad",
+			// ASTROLABE_ONLINE_ARCHIVE_URI is what client entities with useOnlineArchive connect to
+			"onlineArchive",
+			// ASTROLABE_CONTROL_URI is the control cluster writes are mirrored to
+			"controlCluster",
+			// -status-addr or ASTROLABE_STATUS_ADDR serves /healthz, /readyz and /status over HTTP
+			"statusEndpoint",
+			// -ops-per-second, -operation-delay and -ramp-up pace the operation loop
+			"pacing",
+		},
+	}
+}
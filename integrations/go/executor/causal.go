@@ -0,0 +1,107 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// causalDocumentID is the _id of the document written and read back by checkCausalConsistency
+// operations, unless the operation names another.
+const causalDocumentID = "causalConsistency"
+
+// CausalStats holds the outcome counts for checkCausalConsistency operations.
+type CausalStats struct {
+	NumChecks     int `json:"numChecks"`
+	NumStaleReads int `json:"numStaleReads"`
+}
+
+// causalStats returns the causal consistency stats of the workload, creating them on first use so
+// that workloads without checkCausalConsistency operations do not report them.
+func (r *workloadRunner) causalStats() *CausalStats {
+	if r.results.CausalConsistency == nil {
+		r.results.CausalConsistency = &CausalStats{}
+	}
+	return r.results.CausalConsistency
+}
+
+// executeCheckCausalConsistency writes an increasing sequence number to a document of the workload
+// collection in a causally consistent session and reads the document back in the same session
+// from a secondary. The driver sends the read with the afterClusterTime of the write, so the read
+// must return the sequence number just written. Writes and reads use majority write and read
+// concerns, which causal consistency across elections requires. A stale read is a failure that
+// reports the document that was read.
+func (r *workloadRunner) executeCheckCausalConsistency(op *operation) (bool, error) {
+	var id interface{} = causalDocumentID
+	rp := readpref.Secondary()
+
+	elems, _ := op.Arguments.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "documentId":
+			id = val
+		case "readPreference":
+			p, err := createReadPreference(val)
+			if err != nil {
+				return false, harnessError(fmt.Errorf("invalid readPreference: %v", err))
+			}
+			rp = p
+		default:
+			return false, harnessError(fmt.Errorf("unrecognized checkCausalConsistency option: %v", key))
+		}
+	}
+	writeColl, err := r.coll.Clone(options.Collection().SetWriteConcern(writeconcern.New(writeconcern.WMajority())))
+	if err != nil {
+		return false, err
+	}
+	readColl, err := r.coll.Clone(options.Collection().
+		SetReadPreference(rp).
+		SetReadConcern(readconcern.Majority()))
+	if err != nil {
+		return false, err
+	}
+
+	sess, err := r.client.StartSession(options.Session().SetCausalConsistency(true))
+	if err != nil {
+		return false, err
+	}
+	defer sess.EndSession(context.Background())
+	sessCtx := mongo.NewSessionContext(context.Background(), sess)
+
+	stats := r.causalStats()
+	stats.NumChecks++
+	r.causalSeq++
+	seq := r.causalSeq
+
+	filter := bson.D{{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "seq", Value: seq}}}}
+	if _, err = writeColl.UpdateOne(sessCtx, filter, update, options.Update().SetUpsert(true)); err != nil {
+		return false, err
+	}
+
+	var doc bson.Raw
+	err = readColl.FindOne(sessCtx, filter).Decode(&doc)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return false, err
+	}
+	got, ok := doc.Lookup("seq").AsInt64OK()
+	if err == mongo.ErrNoDocuments || !ok || got < seq {
+		stats.NumStaleReads++
+		evidence := "no document"
+		if doc != nil {
+			evidence = doc.String()
+		}
+		return false, &failure{msg: fmt.Sprintf(
+			"stale read in causally consistent session: wrote seq %d to %v, read %s", seq, id, evidence)}
+	}
+	return true, nil
+}
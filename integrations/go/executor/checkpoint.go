@@ -0,0 +1,154 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// checkpointInterval is how often the cumulative counters are written to the checkpoint file, see
+// Options.CheckpointFile. Outcomes after the last checkpoint are lost if the executor crashes.
+const checkpointInterval = time.Second
+
+// ResumeSink is implemented by sinks that keep state on disk across a crash of the executor, e.g.
+// the partial events file. Resume is called before the workload starts if the run resumes from a
+// checkpoint of a previous executor process.
+type ResumeSink interface {
+	Sink
+	Resume() error
+}
+
+// Restart records the executor resuming the workload from a checkpoint after the previous executor
+// process died.
+type Restart struct {
+	// seconds since the Unix epoch
+	Time float64 `json:"time"`
+	RecordTime
+	// time of the checkpoint the counters were restored from, in seconds since the Unix epoch.
+	// Outcomes between the checkpoint and the crash are not counted.
+	CheckpointTime float64 `json:"checkpointTime"`
+}
+
+// checkpoint holds the cumulative counters of a run, which survive a crash of the executor.
+type checkpoint struct {
+	Time          float64           `json:"time"`
+	NumErrors     int               `json:"numErrors"`
+	NumFailures   int               `json:"numFailures"`
+	NumSuccesses  int               `json:"numSuccesses"`
+	NumIterations int               `json:"numIterations"`
+	NumAppRetries int               `json:"numAppRetries"`
+	Operations    []*OperationStats `json:"operations"`
+	Restarts      []Restart         `json:"restarts,omitempty"`
+}
+
+// restoreCheckpoint adds the counters of the checkpoint left by a previous executor process, if
+// there is one, to the results, records the restart and reports whether the run resumes. It must be
+// called after the operations of the workload are tracked.
+func (r *workloadRunner) restoreCheckpoint() (bool, error) {
+	if r.checkpointPath == "" {
+		return false, nil
+	}
+	data, err := ioutil.ReadFile(r.checkpointPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("reading checkpoint failed: %v", err)
+	}
+	var saved checkpoint
+	if err = json.Unmarshal(data, &saved); err != nil {
+		return false, fmt.Errorf("malformed checkpoint: %v", err)
+	}
+
+	r.results.NumErrors += saved.NumErrors
+	atomic.AddInt64(r.runErrors, int64(saved.NumErrors))
+	r.results.NumFailures += saved.NumFailures
+	r.results.NumSuccesses += saved.NumSuccesses
+	r.results.NumIterations += saved.NumIterations
+	r.results.NumAppRetries += saved.NumAppRetries
+	for _, savedStats := range saved.Operations {
+		stats, ok := r.opStats[savedStats.Name]
+		if !ok {
+			stats = &OperationStats{Name: savedStats.Name}
+			r.opStats[savedStats.Name] = stats
+			r.results.Operations = append(r.results.Operations, stats)
+		}
+		stats.NumErrors += savedStats.NumErrors
+		stats.NumFailures += savedStats.NumFailures
+		stats.NumSuccesses += savedStats.NumSuccesses
+		stats.NumAppRetries += savedStats.NumAppRetries
+	}
+
+	t, rt := stamp()
+	r.results.Restarts = append(saved.Restarts, Restart{Time: t, RecordTime: rt, CheckpointTime: saved.Time})
+	fmt.Fprintf(os.Stderr, "resumed the workload from the checkpoint at %s (restart %d)\n",
+		r.checkpointPath, len(r.results.Restarts))
+
+	for _, sink := range r.sinks {
+		if rs, ok := sink.(ResumeSink); ok {
+			if err := rs.Resume(); err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// maybeCheckpoint writes the cumulative counters of the run, including the last counters published
+// by the other workers, to the checkpoint file if the last checkpoint is older than
+// checkpointInterval. The file is replaced atomically so that a crash while writing it leaves the
+// previous checkpoint. Errors are reported to stderr since checkpoints only provide durability.
+func (r *workloadRunner) maybeCheckpoint() {
+	if r.checkpointPath == "" || time.Since(r.lastCheckpoint) < checkpointInterval {
+		return
+	}
+	r.lastCheckpoint = time.Now()
+
+	saved := checkpoint{
+		Time:          now(),
+		NumErrors:     r.results.NumErrors,
+		NumFailures:   r.results.NumFailures,
+		NumSuccesses:  r.results.NumSuccesses,
+		NumIterations: r.results.NumIterations,
+		NumAppRetries: r.results.NumAppRetries,
+		Restarts:      r.results.Restarts,
+	}
+	operations := make(map[string]*OperationStats)
+	for _, stats := range r.results.Operations {
+		copied := *stats
+		operations[stats.Name] = &copied
+		saved.Operations = append(saved.Operations, &copied)
+	}
+	for _, w := range r.workers {
+		w.snapshot.mu.Lock()
+		progress := w.snapshot.progress
+		saved.NumErrors += progress.NumErrors
+		saved.NumFailures += progress.NumFailures
+		saved.NumSuccesses += progress.NumSuccesses
+		saved.NumIterations += progress.NumIterations
+		saved.NumAppRetries += w.snapshot.numAppRetries
+		for _, stats := range w.snapshot.operations {
+			merged, ok := operations[stats.Name]
+			if !ok {
+				merged = &OperationStats{Name: stats.Name}
+				operations[stats.Name] = merged
+				saved.Operations = append(saved.Operations, merged)
+			}
+			merged.NumErrors += stats.NumErrors
+			merged.NumFailures += stats.NumFailures
+			merged.NumSuccesses += stats.NumSuccesses
+			merged.NumAppRetries += stats.NumAppRetries
+		}
+		w.snapshot.mu.Unlock()
+	}
+	data, err := json.Marshal(saved)
+	if err == nil {
+		err = writeFileAtomically(r.checkpointPath, data)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "writing checkpoint failed: %v\n", err)
+	}
+}
@@ -0,0 +1,120 @@
+package executor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// clientChurn configures clients that are created and destroyed alongside the workload, which is
+// how applications on serverless platforms use the driver. Each client connects, pings the primary
+// and disconnects.
+type clientChurn struct {
+	// clients created per second
+	Rate float64
+	// maximum number of clients alive at once; defaults to 10. A client due while the maximum
+	// is reached is not created.
+	Concurrency int
+}
+
+// ChurnStats summarizes the clients created by the client churn of a workload.
+type ChurnStats struct {
+	NumClients  int    `json:"numClients"`
+	NumFailures int    `json:"numFailures"`
+	NumSkipped  int    `json:"numSkipped"`
+	LastError   string `json:"lastError,omitempty"`
+	// time from creating a client until its ping succeeded, for successful clients
+	LatencyMS LatencySummary `json:"latencyMS"`
+}
+
+// LatencySummary holds percentiles of a set of latencies.
+type LatencySummary struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// churner runs the client churn of a workload.
+type churner struct {
+	config  *clientChurn
+	newOpts func() *options.ClientOptions
+
+	mu        sync.Mutex
+	stats     ChurnStats
+	latencies []float64
+	wg        sync.WaitGroup
+}
+
+// run creates clients at the configured rate until ctx is done and waits for the clients that are
+// still alive to finish.
+func (c *churner) run(ctx context.Context) {
+	concurrency := c.config.Concurrency
+	if concurrency <= 0 {
+		concurrency = 10
+	}
+	slots := make(chan struct{}, concurrency)
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / c.config.Rate))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			c.wg.Wait()
+			return
+		case <-ticker.C:
+		}
+
+		select {
+		case slots <- struct{}{}:
+		default:
+			c.mu.Lock()
+			c.stats.NumSkipped++
+			c.mu.Unlock()
+			continue
+		}
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			defer func() { <-slots }()
+			c.churnOne(ctx)
+		}()
+	}
+}
+
+func (c *churner) churnOne(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	client, err := mongo.Connect(ctx, c.newOpts())
+	if err == nil {
+		err = client.Ping(ctx, readpref.Primary())
+		_ = client.Disconnect(context.Background())
+	}
+	latency := milliseconds(time.Since(start))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.NumClients++
+	if err != nil {
+		c.stats.NumFailures++
+		c.stats.LastError = err.Error()
+		return
+	}
+	c.latencies = append(c.latencies, latency)
+}
+
+// summary returns the statistics of the clients created so far.
+func (c *churner) summary() *ChurnStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.LatencyMS = summarizeLatencies(c.latencies)
+	return &stats
+}
@@ -0,0 +1,231 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"go.mongodb.org/mongo-driver/tag"
+)
+
+// defaultClientID is the client the results of operations on the workload collection, and on
+// collection entities that do not name a client, are attributed to.
+const defaultClientID = "default"
+
+// clientEntity is an additional client with its own connection pool and configuration, e.g. a
+// writer using the primary and a reader using secondaries. Operations on collections created from
+// a client are attributed to it in Results.Clients, so that read and write availability can be
+// reported independently. Unset options default to those of the connection string.
+type clientEntity struct {
+	ID string `bson:"id"`
+	// a mode, e.g. "primary" or "secondaryPreferred", or a read preference document, see
+	// createReadPreference
+	ReadPreference bson.RawValue `bson:"readPreference"`
+	// e.g. "local" or "majority"
+	ReadConcern  string            `bson:"readConcern"`
+	WriteConcern *writeConcernSpec `bson:"writeConcern"`
+	// connect to the federated database instance of the online archive of the cluster, see
+	// Options.OnlineArchiveURI, instead of to the cluster
+	UseOnlineArchive bool `bson:"useOnlineArchive"`
+	// encrypt and decrypt the fields of documents automatically, see autoEncryptOpts
+	AutoEncryptOpts *autoEncryptOpts `bson:"autoEncryptOpts"`
+}
+
+type writeConcernSpec struct {
+	// a number of nodes or a tag set name such as "majority"
+	W          interface{}
+	J          *bool
+	WTimeoutMS int64 `bson:"wtimeoutMS"`
+}
+
+// ClientStats holds the outcome counts for the operations attributed to a client.
+type ClientStats struct {
+	NumErrors    int `json:"numErrors"`
+	NumFailures  int `json:"numFailures"`
+	NumSuccesses int `json:"numSuccesses"`
+}
+
+func (spec *writeConcernSpec) writeConcern() (*writeconcern.WriteConcern, error) {
+	var opts []writeconcern.Option
+	switch w := spec.W.(type) {
+	case nil:
+	case int32:
+		opts = append(opts, writeconcern.W(int(w)))
+	case int64:
+		opts = append(opts, writeconcern.W(int(w)))
+	case float64:
+		opts = append(opts, writeconcern.W(int(w)))
+	case string:
+		if w == "majority" {
+			opts = append(opts, writeconcern.WMajority())
+		} else {
+			opts = append(opts, writeconcern.WTagSet(w))
+		}
+	default:
+		return nil, fmt.Errorf("unrecognized w: %v", w)
+	}
+	if spec.J != nil {
+		opts = append(opts, writeconcern.J(*spec.J))
+	}
+	if spec.WTimeoutMS > 0 {
+		opts = append(opts, writeconcern.WTimeout(time.Duration(spec.WTimeoutMS)*time.Millisecond))
+	}
+	return writeconcern.New(opts...), nil
+}
+
+// createReadPreference creates a read preference from either a mode such as "secondary" or a
+// document like {mode: "secondary", tagSets: [{nodeType: "ANALYTICS"}], maxStalenessSeconds: 90},
+// so that routing to tagged members, e.g. analytics nodes, and the exclusion of stale secondaries
+// can be exercised.
+func createReadPreference(val bson.RawValue) (*readpref.ReadPref, error) {
+	if val.Type == bson.TypeString {
+		mode, err := readpref.ModeFromString(val.StringValue())
+		if err != nil {
+			return nil, err
+		}
+		return readpref.New(mode)
+	}
+
+	doc, ok := val.DocumentOK()
+	if !ok {
+		return nil, fmt.Errorf("unrecognized readPreference type: %v", val.Type)
+	}
+	mode := readpref.PrimaryMode
+	var rpOpts []readpref.Option
+	elems, _ := doc.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "mode":
+			m, err := readpref.ModeFromString(val.StringValue())
+			if err != nil {
+				return nil, err
+			}
+			mode = m
+		case "tagSets":
+			var tagSets []map[string]string
+			if err := val.Unmarshal(&tagSets); err != nil {
+				return nil, fmt.Errorf("invalid tagSets: %v", err)
+			}
+			rpOpts = append(rpOpts, readpref.WithTagSets(tag.NewTagSetsFromMaps(tagSets)...))
+		case "maxStalenessSeconds":
+			seconds := val.AsInt64()
+			rpOpts = append(rpOpts, readpref.WithMaxStaleness(time.Duration(seconds)*time.Second))
+		default:
+			return nil, fmt.Errorf("unrecognized readPreference option: %v", key)
+		}
+	}
+	return readpref.New(mode, rpOpts...)
+}
+
+// createClientEntities connects a client for each entity. The clients share the command monitor and
+// the dialer of the workload client.
+func (r *workloadRunner) createClientEntities(ctx context.Context, uri string, entities []*clientEntity) error {
+	for _, entity := range entities {
+		if entity.ID == "" {
+			return errors.New("client entities require an id")
+		}
+		if _, ok := r.clients[entity.ID]; ok || entity.ID == defaultClientID {
+			return fmt.Errorf("duplicate client entity id %q", entity.ID)
+		}
+
+		entityURI := uri
+		if entity.UseOnlineArchive {
+			if r.onlineArchiveURI == "" {
+				return fmt.Errorf("client entity %q uses the online archive, but no online archive connection string was given", entity.ID)
+			}
+			entityURI = r.onlineArchiveURI
+		}
+		opts := options.Client().ApplyURI(entityURI).SetMonitor(r.clientOpts.Monitor).
+			SetPoolMonitor(r.clientOpts.PoolMonitor)
+		if r.clientOpts.Dialer != nil {
+			opts.SetDialer(r.clientOpts.Dialer)
+		}
+		if r.clientOpts.DisableOCSPEndpointCheck != nil {
+			opts.SetDisableOCSPEndpointCheck(*r.clientOpts.DisableOCSPEndpointCheck)
+		}
+		if entity.ReadPreference.Type != 0 {
+			rp, err := createReadPreference(entity.ReadPreference)
+			if err != nil {
+				return fmt.Errorf("client entity %q: %v", entity.ID, err)
+			}
+			opts.SetReadPreference(rp)
+		}
+		if entity.ReadConcern != "" {
+			opts.SetReadConcern(readconcern.New(readconcern.Level(entity.ReadConcern)))
+		}
+		if entity.WriteConcern != nil {
+			wc, err := entity.WriteConcern.writeConcern()
+			if err != nil {
+				return fmt.Errorf("client entity %q: %v", entity.ID, err)
+			}
+			opts.SetWriteConcern(wc)
+		}
+		if entity.AutoEncryptOpts != nil {
+			aeOpts, err := r.autoEncryptionOptions(entity.AutoEncryptOpts)
+			if err != nil {
+				return fmt.Errorf("client entity %q: %v", entity.ID, err)
+			}
+			opts.SetAutoEncryptionOptions(aeOpts)
+		}
+
+		client, err := mongo.Connect(ctx, opts)
+		if err != nil {
+			return err
+		}
+		r.clients[entity.ID] = client
+		r.results.Clients[entity.ID] = &ClientStats{}
+	}
+	if len(entities) > 0 {
+		r.results.Clients[defaultClientID] = &ClientStats{}
+	}
+	return nil
+}
+
+// unmonitoredClientOptions returns the options for a client that connects like the workload
+// client, but whose events are not recorded.
+func (r *workloadRunner) unmonitoredClientOptions() *options.ClientOptions {
+	opts := options.Client().ApplyURI(r.uri)
+	if r.clientOpts.Dialer != nil {
+		opts.SetDialer(r.clientOpts.Dialer)
+	}
+	if r.clientOpts.DisableOCSPEndpointCheck != nil {
+		opts.SetDisableOCSPEndpointCheck(*r.clientOpts.DisableOCSPEndpointCheck)
+	}
+	return opts
+}
+
+// disconnectClients disconnects the clients created from client entities.
+func (r *workloadRunner) disconnectClients() {
+	for _, client := range r.clients {
+		_ = client.Disconnect(context.Background())
+	}
+}
+
+// clientStats returns the stats of the client op is attributed to, or nil if the workload has no
+// client entities or op does not use a collection.
+func (r *workloadRunner) clientStats(op *operation) *ClientStats {
+	if len(r.results.Clients) == 0 {
+		return nil
+	}
+	if op.Object == "collection" {
+		return r.results.Clients[defaultClientID]
+	}
+	if _, ok := r.collections[op.Object]; !ok {
+		return nil
+	}
+	id, ok := r.collectionClients[op.Object]
+	if !ok {
+		id = defaultClientID
+	}
+	return r.results.Clients[id]
+}
@@ -0,0 +1,128 @@
+package executor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ClockSkewStats reports how far the clock of the executor was from the clock of the primary while
+// the workload ran, so that the timestamps of events.json can be lined up with the logs of the
+// cluster. A positive skew means the clock of the server is ahead.
+type ClockSkewStats struct {
+	NumSamples int     `json:"numSamples"`
+	NumErrors  int     `json:"numErrors"`
+	MinMS      float64 `json:"minMS"`
+	MaxMS      float64 `json:"maxMS"`
+	// the skew of the last sample, which is the best estimate for aligning the end of the run
+	LastMS  float64           `json:"lastMS"`
+	Samples []ClockSkewSample `json:"samples,omitempty"`
+}
+
+// ClockSkewSample is one comparison of the clocks of the executor and the primary.
+type ClockSkewSample struct {
+	// seconds since the Unix epoch, on the clock of the executor
+	Time float64 `json:"time"`
+	RecordTime
+	// localTime of the isMaster response minus the midpoint of the round trip
+	SkewMS      float64 `json:"skewMS"`
+	RoundTripMS float64 `json:"roundTripMS"`
+	// $clusterTime of the response minus the time it was received, if the response had one. The
+	// cluster time has a resolution of a second and only advances with writes, so it lags the
+	// clock of the server on an idle cluster.
+	ClusterTimeSkewMS *float64 `json:"clusterTimeSkewMS,omitempty"`
+}
+
+// maxClockSkewSamples caps the number of samples kept, so that a long run at a short interval does
+// not grow results.json without bound. Later samples still count towards the summary.
+const maxClockSkewSamples = 1000
+
+// clockSkewSampler compares the clocks of the executor and the primary at an interval, using a
+// client that is not monitored so that its commands are not recorded as workload events.
+type clockSkewSampler struct {
+	client   *mongo.Client
+	interval time.Duration
+
+	mu    sync.Mutex
+	stats ClockSkewStats
+}
+
+// run takes a sample right away and then one per interval until ctx is done.
+func (s *clockSkewSampler) run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.sample(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *clockSkewSampler) sample(ctx context.Context) {
+	var reply struct {
+		LocalTime   primitive.DateTime `bson:"localTime"`
+		ClusterTime struct {
+			ClusterTime primitive.Timestamp `bson:"clusterTime"`
+		} `bson:"$clusterTime"`
+	}
+	sent := time.Now()
+	err := s.client.Database("admin").RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&reply)
+	received := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		// the sampler is stopped by cancelling ctx, which fails the command in flight
+		if ctx.Err() == nil {
+			s.stats.NumErrors++
+		}
+		return
+	}
+
+	roundTrip := received.Sub(sent)
+	midpoint := sent.Add(roundTrip / 2)
+	serverTime := time.Unix(0, int64(reply.LocalTime)*int64(time.Millisecond))
+	skew := milliseconds(serverTime.Sub(midpoint))
+
+	stats := &s.stats
+	if stats.NumSamples == 0 || skew < stats.MinMS {
+		stats.MinMS = skew
+	}
+	if stats.NumSamples == 0 || skew > stats.MaxMS {
+		stats.MaxMS = skew
+	}
+	stats.LastMS = skew
+	stats.NumSamples++
+	if len(stats.Samples) >= maxClockSkewSamples {
+		return
+	}
+
+	sample := ClockSkewSample{
+		Time:        float64(received.UnixNano()) / float64(time.Second),
+		RecordTime:  newRecordTime(received),
+		SkewMS:      skew,
+		RoundTripMS: milliseconds(roundTrip),
+	}
+	if clusterTime := reply.ClusterTime.ClusterTime; clusterTime.T != 0 {
+		clusterSkew := milliseconds(time.Unix(int64(clusterTime.T), 0).Sub(received))
+		sample.ClusterTimeSkewMS = &clusterSkew
+	}
+	stats.Samples = append(stats.Samples, sample)
+}
+
+// summary returns a copy of the stats collected so far.
+func (s *clockSkewSampler) summary() *ClockSkewStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := s.stats
+	copied.Samples = append([]ClockSkewSample(nil), s.stats.Samples...)
+	return &copied
+}
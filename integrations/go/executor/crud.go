@@ -0,0 +1,539 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The CRUD operations beyond insertOne, insertMany, find, updateOne, deleteOne and aggregate. Their
+// arguments and expected results follow the CRUD spec tests, e.g.
+//
+//	- name: findOneAndUpdate
+//	  object: collection
+//	  arguments:
+//	    filter: {_id: 1}
+//	    update: {$inc: {x: 1}}
+//	    returnDocument: After
+//	  result: {_id: 1, x: 2}
+
+func init() {
+	registerCollectionOperation("updateMany", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		res, err := executeUpdateMany(ctx, coll, op.Arguments)
+		return verifyUpdateResult(res, op.Result, r.verifier), err
+	})
+	registerCollectionOperation("replaceOne", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		res, err := executeReplaceOne(ctx, coll, op.Arguments)
+		return verifyUpdateResult(res, op.Result, r.verifier), err
+	})
+	registerCollectionOperation("deleteMany", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		res, err := executeDeleteMany(ctx, coll, op.Arguments)
+		return verifyDeleteResult(res, op.Result, r.verifier), err
+	})
+	registerCollectionOperation("bulkWrite", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		res, err := executeBulkWrite(ctx, coll, op.Arguments)
+		return verifyBulkWriteResult(res, op.Result, r.verifier), err
+	})
+	registerCollectionOperation("countDocuments", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		count, err := executeCountDocuments(ctx, coll, op.Arguments)
+		return verifyCountResult(count, op.Result, r.verifier), err
+	})
+	registerCollectionOperation("distinct", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		values, err := executeDistinct(ctx, coll, op.Arguments)
+		return verifyDistinctResult(values, op.Result, r.verifier), err
+	})
+	registerCollectionOperation("findOneAndDelete", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		doc, err := singleResultDocument(executeFindOneAndDelete(ctx, coll, op.Arguments))
+		return verifySingleResult(doc, op.Result, r.verifier), err
+	})
+	registerCollectionOperation("findOneAndReplace", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		doc, err := singleResultDocument(executeFindOneAndReplace(ctx, coll, op.Arguments))
+		return verifySingleResult(doc, op.Result, r.verifier), err
+	})
+	registerCollectionOperation("findOneAndUpdate", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		doc, err := singleResultDocument(executeFindOneAndUpdate(ctx, coll, op.Arguments))
+		return verifySingleResult(doc, op.Result, r.verifier), err
+	})
+}
+
+func executeUpdateMany(ctx context.Context, coll *mongo.Collection, args bson.Raw) (*mongo.UpdateResult, error) {
+	filter, update, opts, err := parseUpdateArguments("updateMany", args)
+	if err != nil {
+		return nil, err
+	}
+	if label := operationLabel(ctx); label != "" {
+		opts = opts.SetComment(label)
+	}
+
+	return coll.UpdateMany(ctx, filter, update, opts)
+}
+
+func executeReplaceOne(ctx context.Context, coll *mongo.Collection, args bson.Raw) (*mongo.UpdateResult, error) {
+	filter := emptyDoc
+	replacement := emptyDoc
+	opts := options.Replace().SetUpsert(false)
+
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "filter":
+			filter = val.Document()
+		case "replacement":
+			replacement = val.Document()
+		case "upsert":
+			opts = opts.SetUpsert(val.Boolean())
+		case "collation":
+			collation, err := createCollation(val.Document())
+			if err != nil {
+				return nil, err
+			}
+			opts = opts.SetCollation(collation)
+		default:
+			return nil, harnessError(fmt.Errorf("unrecognized replaceOne option: %v", key))
+		}
+	}
+	if label := operationLabel(ctx); label != "" {
+		opts = opts.SetComment(label)
+	}
+
+	return coll.ReplaceOne(ctx, filter, replacement, opts)
+}
+
+func executeDeleteMany(ctx context.Context, coll *mongo.Collection, args bson.Raw) (*mongo.DeleteResult, error) {
+	filter, opts, err := parseDeleteArguments("deleteMany", args)
+	if err != nil {
+		return nil, err
+	}
+	if label := operationLabel(ctx); label != "" {
+		opts = opts.SetComment(label)
+	}
+
+	return coll.DeleteMany(ctx, filter, opts)
+}
+
+// executeBulkWrite runs the write models of the requests argument, each a document with a single
+// key naming the model, e.g. {insertOne: {document: {x: 1}}} or {deleteMany: {filter: {}}}.
+func executeBulkWrite(ctx context.Context, coll *mongo.Collection, args bson.Raw) (*mongo.BulkWriteResult, error) {
+	var models []mongo.WriteModel
+	opts := options.BulkWrite()
+
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "requests":
+			vals, _ := val.Array().Values()
+			for _, v := range vals {
+				model, err := createWriteModel(v.Document())
+				if err != nil {
+					return nil, err
+				}
+				models = append(models, model)
+			}
+		case "ordered":
+			opts = opts.SetOrdered(val.Boolean())
+		default:
+			return nil, harnessError(fmt.Errorf("unrecognized bulkWrite option: %v", key))
+		}
+	}
+	if label := operationLabel(ctx); label != "" {
+		opts = opts.SetComment(label)
+	}
+
+	return coll.BulkWrite(ctx, models, opts)
+}
+
+// create a write model from a bulkWrite request
+func createWriteModel(request bson.Raw) (mongo.WriteModel, error) {
+	elems, _ := request.Elements()
+	if len(elems) != 1 {
+		return nil, harnessError(fmt.Errorf("bulkWrite requests must have exactly one key, got %v", request))
+	}
+	name := elems[0].Key()
+	args := elems[0].Value().Document()
+
+	switch name {
+	case "insertOne":
+		doc, err := args.LookupErr("document")
+		if err != nil {
+			return nil, harnessError(errors.New("bulkWrite insertOne requests require a document"))
+		}
+		return mongo.NewInsertOneModel().SetDocument(doc.Document()), nil
+	case "updateOne":
+		filter, update, opts, err := parseUpdateArguments("bulkWrite updateOne", args)
+		if err != nil {
+			return nil, err
+		}
+		model := mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(update).SetUpsert(*opts.Upsert)
+		if opts.Collation != nil {
+			model = model.SetCollation(opts.Collation)
+		}
+		return model, nil
+	case "updateMany":
+		filter, update, opts, err := parseUpdateArguments("bulkWrite updateMany", args)
+		if err != nil {
+			return nil, err
+		}
+		model := mongo.NewUpdateManyModel().SetFilter(filter).SetUpdate(update).SetUpsert(*opts.Upsert)
+		if opts.Collation != nil {
+			model = model.SetCollation(opts.Collation)
+		}
+		return model, nil
+	case "replaceOne":
+		model := mongo.NewReplaceOneModel().SetFilter(emptyDoc).SetReplacement(emptyDoc).SetUpsert(false)
+		replaceElems, _ := args.Elements()
+		for _, elem := range replaceElems {
+			switch elem.Key() {
+			case "filter":
+				model = model.SetFilter(elem.Value().Document())
+			case "replacement":
+				model = model.SetReplacement(elem.Value().Document())
+			case "upsert":
+				model = model.SetUpsert(elem.Value().Boolean())
+			case "collation":
+				collation, err := createCollation(elem.Value().Document())
+				if err != nil {
+					return nil, err
+				}
+				model = model.SetCollation(collation)
+			default:
+				return nil, harnessError(fmt.Errorf("unrecognized bulkWrite replaceOne option: %v", elem.Key()))
+			}
+		}
+		return model, nil
+	case "deleteOne":
+		filter, opts, err := parseDeleteArguments("bulkWrite deleteOne", args)
+		if err != nil {
+			return nil, err
+		}
+		model := mongo.NewDeleteOneModel().SetFilter(filter)
+		if opts.Collation != nil {
+			model = model.SetCollation(opts.Collation)
+		}
+		return model, nil
+	case "deleteMany":
+		filter, opts, err := parseDeleteArguments("bulkWrite deleteMany", args)
+		if err != nil {
+			return nil, err
+		}
+		model := mongo.NewDeleteManyModel().SetFilter(filter)
+		if opts.Collation != nil {
+			model = model.SetCollation(opts.Collation)
+		}
+		return model, nil
+	default:
+		return nil, harnessError(fmt.Errorf("unrecognized bulkWrite request: %v", name))
+	}
+}
+
+func executeCountDocuments(ctx context.Context, coll *mongo.Collection, args bson.Raw) (int64, error) {
+	filter := emptyDoc
+	opts := options.Count()
+
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "filter":
+			filter = val.Document()
+		case "skip":
+			opts = opts.SetSkip(val.AsInt64())
+		case "limit":
+			opts = opts.SetLimit(val.AsInt64())
+		case "collation":
+			collation, err := createCollation(val.Document())
+			if err != nil {
+				return 0, err
+			}
+			opts = opts.SetCollation(collation)
+		default:
+			return 0, harnessError(fmt.Errorf("unrecognized countDocuments option: %v", key))
+		}
+	}
+	if label := operationLabel(ctx); label != "" {
+		opts = opts.SetComment(label)
+	}
+
+	return coll.CountDocuments(ctx, filter, opts)
+}
+
+func executeDistinct(ctx context.Context, coll *mongo.Collection, args bson.Raw) ([]interface{}, error) {
+	var fieldName string
+	filter := emptyDoc
+	opts := options.Distinct()
+
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "fieldName":
+			fieldName = val.StringValue()
+		case "filter":
+			filter = val.Document()
+		case "collation":
+			collation, err := createCollation(val.Document())
+			if err != nil {
+				return nil, err
+			}
+			opts = opts.SetCollation(collation)
+		default:
+			return nil, harnessError(fmt.Errorf("unrecognized distinct option: %v", key))
+		}
+	}
+	if label := operationLabel(ctx); label != "" {
+		opts = opts.SetComment(label)
+	}
+
+	return coll.Distinct(ctx, fieldName, filter, opts)
+}
+
+// create a ReturnDocument from the returnDocument argument of findOneAndReplace and
+// findOneAndUpdate
+func createReturnDocument(val bson.RawValue) (options.ReturnDocument, error) {
+	switch val.StringValue() {
+	case "Before":
+		return options.Before, nil
+	case "After":
+		return options.After, nil
+	default:
+		return 0, harnessError(fmt.Errorf("unrecognized returnDocument: %v", val.StringValue()))
+	}
+}
+
+func executeFindOneAndDelete(ctx context.Context, coll *mongo.Collection, args bson.Raw) (*mongo.SingleResult, error) {
+	filter := emptyDoc
+	opts := options.FindOneAndDelete()
+
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "filter":
+			filter = val.Document()
+		case "sort":
+			opts = opts.SetSort(val.Document())
+		case "projection":
+			opts = opts.SetProjection(val.Document())
+		case "collation":
+			collation, err := createCollation(val.Document())
+			if err != nil {
+				return nil, err
+			}
+			opts = opts.SetCollation(collation)
+		default:
+			return nil, harnessError(fmt.Errorf("unrecognized findOneAndDelete option: %v", key))
+		}
+	}
+	if label := operationLabel(ctx); label != "" {
+		opts = opts.SetComment(label)
+	}
+
+	return coll.FindOneAndDelete(ctx, filter, opts), nil
+}
+
+func executeFindOneAndReplace(ctx context.Context, coll *mongo.Collection, args bson.Raw) (*mongo.SingleResult, error) {
+	filter := emptyDoc
+	replacement := emptyDoc
+	opts := options.FindOneAndReplace().SetUpsert(false)
+
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "filter":
+			filter = val.Document()
+		case "replacement":
+			replacement = val.Document()
+		case "sort":
+			opts = opts.SetSort(val.Document())
+		case "projection":
+			opts = opts.SetProjection(val.Document())
+		case "upsert":
+			opts = opts.SetUpsert(val.Boolean())
+		case "returnDocument":
+			returnDocument, err := createReturnDocument(val)
+			if err != nil {
+				return nil, err
+			}
+			opts = opts.SetReturnDocument(returnDocument)
+		case "collation":
+			collation, err := createCollation(val.Document())
+			if err != nil {
+				return nil, err
+			}
+			opts = opts.SetCollation(collation)
+		default:
+			return nil, harnessError(fmt.Errorf("unrecognized findOneAndReplace option: %v", key))
+		}
+	}
+	if label := operationLabel(ctx); label != "" {
+		opts = opts.SetComment(label)
+	}
+
+	return coll.FindOneAndReplace(ctx, filter, replacement, opts), nil
+}
+
+func executeFindOneAndUpdate(ctx context.Context, coll *mongo.Collection, args bson.Raw) (*mongo.SingleResult, error) {
+	filter := emptyDoc
+	var update interface{} = emptyDoc
+	opts := options.FindOneAndUpdate().SetUpsert(false)
+
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "filter":
+			filter = val.Document()
+		case "update":
+			var err error
+			if update, err = createUpdate(val); err != nil {
+				return nil, err
+			}
+		case "sort":
+			opts = opts.SetSort(val.Document())
+		case "projection":
+			opts = opts.SetProjection(val.Document())
+		case "upsert":
+			opts = opts.SetUpsert(val.Boolean())
+		case "returnDocument":
+			returnDocument, err := createReturnDocument(val)
+			if err != nil {
+				return nil, err
+			}
+			opts = opts.SetReturnDocument(returnDocument)
+		case "collation":
+			collation, err := createCollation(val.Document())
+			if err != nil {
+				return nil, err
+			}
+			opts = opts.SetCollation(collation)
+		default:
+			return nil, harnessError(fmt.Errorf("unrecognized findOneAndUpdate option: %v", key))
+		}
+	}
+	if label := operationLabel(ctx); label != "" {
+		opts = opts.SetComment(label)
+	}
+
+	return coll.FindOneAndUpdate(ctx, filter, update, opts), nil
+}
+
+// singleResultDocument returns the document of res, or nil if no document matched, which is not
+// an error for the findOneAndX operations. err is the error of running the operation, if any.
+func singleResultDocument(res *mongo.SingleResult, err error) (bson.Raw, error) {
+	if err != nil {
+		return nil, err
+	}
+	doc, err := res.DecodeBytes()
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	return doc, err
+}
+
+func verifySingleResult(doc bson.Raw, result interface{}, v verifier) bool {
+	if result == nil {
+		return true
+	}
+	if doc == nil {
+		return false
+	}
+	expected, ok := result.(bson.Raw)
+	return ok && v.documentsMatch(expected, doc)
+}
+
+func verifyBulkWriteResult(res *mongo.BulkWriteResult, result interface{}, v verifier) bool {
+	if result == nil {
+		return true
+	}
+	if res == nil {
+		return false
+	}
+
+	var expected struct {
+		InsertedCount int64 `bson:"insertedCount"`
+		MatchedCount  int64 `bson:"matchedCount"`
+		ModifiedCount int64 `bson:"modifiedCount"`
+		DeletedCount  int64 `bson:"deletedCount"`
+		UpsertedCount int64 `bson:"upsertedCount"`
+	}
+	err := bson.Unmarshal(result.(bson.Raw), &expected)
+	if err != nil {
+		return false
+	}
+
+	return v.countsMatch(expected.InsertedCount, res.InsertedCount) &&
+		v.countsMatch(expected.MatchedCount, res.MatchedCount) &&
+		v.countsMatch(expected.ModifiedCount, res.ModifiedCount) &&
+		v.countsMatch(expected.DeletedCount, res.DeletedCount) &&
+		v.countsMatch(expected.UpsertedCount, res.UpsertedCount)
+}
+
+func verifyCountResult(count int64, result interface{}, v verifier) bool {
+	if result == nil {
+		return true
+	}
+
+	var expected int64
+	switch n := result.(type) {
+	case int32:
+		expected = int64(n)
+	case int64:
+		expected = n
+	case float64:
+		if n != math.Floor(n) {
+			return false
+		}
+		expected = int64(n)
+	default:
+		return false
+	}
+	return v.countsMatch(expected, count)
+}
+
+// verifyDistinctResult compares the distinct values in order, each wrapped in a document so that
+// the verifier of the workload decides whether they match.
+func verifyDistinctResult(values []interface{}, result interface{}, v verifier) bool {
+	if result == nil {
+		return true
+	}
+
+	expected, ok := result.(bson.A)
+	if !ok || len(expected) != len(values) {
+		return false
+	}
+	for i := range expected {
+		expectedDoc, err := bson.Marshal(bson.D{{Key: "value", Value: expected[i]}})
+		if err != nil {
+			return false
+		}
+		actualDoc, err := bson.Marshal(bson.D{{Key: "value", Value: values[i]}})
+		if err != nil {
+			return false
+		}
+		if !v.documentsMatch(expectedDoc, actualDoc) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,168 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// cursorNotFoundCode is the server error code returned by a getMore for a cursor the server does
+// not know, e.g. because the server that opened it was restarted.
+const cursorNotFoundCode = 43
+
+// CursorStats holds the outcome counts for the cursors iterated by iterateCursor operations.
+type CursorStats struct {
+	NumCursors  int `json:"numCursors"`
+	NumGetMores int `json:"numGetMores"`
+	// getMores that failed because the server no longer knew the cursor
+	NumCursorNotFound int `json:"numCursorNotFound"`
+	// cursors reopened after a CursorNotFound error
+	NumResumes int `json:"numResumes"`
+	// getMores sent to a different server than the command that opened the cursor
+	NumMisrouted int `json:"numMisrouted"`
+}
+
+func init() {
+	registerCollectionOperation("iterateCursor", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		return r.executeIterateCursor(ctx, coll, op)
+	})
+}
+
+// cursorStats returns the cursor stats of the workload, creating them on first use so that
+// workloads without iterateCursor operations do not report them.
+func (r *workloadRunner) cursorStats() *CursorStats {
+	if r.results.Cursors == nil {
+		r.results.Cursors = &CursorStats{}
+	}
+	return r.results.Cursors
+}
+
+// executeIterateCursor opens a cursor sorted by _id and iterates it to the end, pausing before each
+// getMore so that a cursor with a small batch size stays open across the maintenance of the
+// cluster. Every getMore must be sent to the server that opened the cursor. If
+// resumeOnCursorNotFound is set, a cursor the server no longer knows is reopened after the last
+// document read instead of failing the operation. The optional result is the expected number of
+// documents, as {numDocuments: n}. Iteration stops early, without checking the result, when the
+// workload is stopped.
+func (r *workloadRunner) executeIterateCursor(ctx context.Context, coll *mongo.Collection, op *operation) (bool, error) {
+	filter := emptyDoc
+	var batchSize int32 = 2
+	var delay time.Duration
+	resume := false
+
+	elems, _ := op.Arguments.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "filter":
+			filter = val.Document()
+		case "batchSize":
+			batchSize = int32(val.AsInt64())
+		case "delayMS":
+			delay = time.Duration(val.AsInt64()) * time.Millisecond
+		case "resumeOnCursorNotFound":
+			resume = val.Boolean()
+		default:
+			return false, harnessError(fmt.Errorf("unrecognized iterateCursor option: %v", key))
+		}
+	}
+
+	stats := r.cursorStats()
+	var numDocuments int64
+	var lastID interface{}
+	for {
+		cursorFilter := interface{}(filter)
+		if lastID != nil {
+			cursorFilter = bson.D{
+				{Key: "$and", Value: bson.A{filter, bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: lastID}}}}}},
+			}
+		}
+		opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetBatchSize(batchSize)
+		cur, err := coll.Find(ctx, cursorFilter, opts)
+		if err != nil {
+			return false, err
+		}
+		stats.NumCursors++
+		address := r.lastCommandAddress()
+
+		stopped := false
+		for {
+			getMore := cur.RemainingBatchLength() == 0 && cur.ID() != 0
+			if getMore {
+				if !r.pause(delay) {
+					stopped = true
+					break
+				}
+				stats.NumGetMores++
+			}
+			if !cur.Next(ctx) {
+				break
+			}
+			if getMore {
+				if got := r.lastCommandAddress(); got != address {
+					stats.NumMisrouted++
+					r.recordFailure(fmt.Sprintf("getMore of cursor opened on %s was sent to %s", address, got))
+				}
+			}
+			numDocuments++
+			lastID = cur.Current.Lookup("_id")
+		}
+		err = cur.Err()
+		_ = cur.Close(context.Background())
+
+		switch {
+		case stopped:
+			return true, nil
+		case err == nil:
+			return r.verifyDocumentCount(numDocuments, op.Result), nil
+		case isCursorNotFound(err):
+			stats.NumCursorNotFound++
+			if !resume {
+				return false, err
+			}
+			stats.NumResumes++
+		default:
+			return false, err
+		}
+	}
+}
+
+// pause waits for d and reports whether the workload was not stopped in the meantime.
+func (r *workloadRunner) pause(d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+func (r *workloadRunner) verifyDocumentCount(actual int64, result interface{}) bool {
+	if result == nil {
+		return true
+	}
+	var expected struct {
+		NumDocuments int64 `bson:"numDocuments"`
+	}
+	if bson.Unmarshal(result.(bson.Raw), &expected) != nil {
+		return false
+	}
+	return r.verifier.countsMatch(expected.NumDocuments, actual)
+}
+
+func isCursorNotFound(err error) bool {
+	coded, ok := err.(interface{ HasErrorCode(int) bool })
+	return ok && coded.HasErrorCode(cursorNotFoundCode)
+}
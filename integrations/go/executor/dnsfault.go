@@ -0,0 +1,100 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// dnsFault is an entry of the dnsFaults schedule of a workload. While a fault is active, dialing a
+// server by host name either fails as if the name could not be resolved or is delayed as if the
+// resolver were slow. Connections to IP addresses are not affected.
+//
+// The faults apply to the lookups made when connections are established. SRV and TXT lookups for
+// mongodb+srv connection strings are made by the driver itself and cannot be intercepted.
+type dnsFault struct {
+	// seconds after the operation loop starts at which the fault begins
+	At float64
+	// seconds the fault lasts; the fault lasts until the workload finishes if omitted
+	Duration float64
+	// "fail" (the default) or "delay"
+	Mode string
+	// milliseconds each lookup is delayed by in "delay" mode
+	DelayMS int `bson:"delayMS"`
+	// host names the fault applies to; defaults to every host
+	Hosts []string
+}
+
+// dnsFaultDialer injects the dnsFaults of a workload before handing the connection to next.
+type dnsFaultDialer struct {
+	next   options.ContextDialer
+	faults []*dnsFault
+
+	mu sync.Mutex
+	// when the operation loop started; faults are inactive until then
+	start time.Time
+}
+
+// begin starts the fault schedule.
+func (d *dnsFaultDialer) begin() {
+	d.mu.Lock()
+	d.start = time.Now()
+	d.mu.Unlock()
+}
+
+// end stops the fault schedule, so that the outcome of the workload is verified without faults.
+func (d *dnsFaultDialer) end() {
+	d.mu.Lock()
+	d.start = time.Time{}
+	d.mu.Unlock()
+}
+
+// activeFault returns the first fault that applies to host at the current time, or nil.
+func (d *dnsFaultDialer) activeFault(host string) *dnsFault {
+	d.mu.Lock()
+	start := d.start
+	d.mu.Unlock()
+	if start.IsZero() {
+		return nil
+	}
+
+	elapsed := time.Since(start).Seconds()
+	for _, f := range d.faults {
+		if elapsed < f.At || (f.Duration > 0 && elapsed >= f.At+f.Duration) {
+			continue
+		}
+		if appliesTo(f.Hosts, host) {
+			return f
+		}
+	}
+	return nil
+}
+
+func (d *dnsFaultDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil || net.ParseIP(host) != nil {
+		return d.next.DialContext(ctx, network, address)
+	}
+
+	if f := d.activeFault(host); f != nil {
+		switch f.Mode {
+		case "delay":
+			timer := time.NewTimer(time.Duration(f.DelayMS) * time.Millisecond)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			}
+		case "", "fail":
+			return nil, &net.DNSError{Err: "simulated lookup failure", Name: host, IsTemporary: true}
+		default:
+			return nil, fmt.Errorf("unrecognized dnsFaults mode: %v", f.Mode)
+		}
+	}
+	return d.next.DialContext(ctx, network, address)
+}
@@ -0,0 +1,212 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// ClusterWriteStats holds the outcome counts and latencies of the mirrored writes on one cluster of
+// a dual-cluster comparison.
+type ClusterWriteStats struct {
+	// "maintenance" for the cluster the workload runs against, "control" for the other
+	Cluster      string         `json:"cluster"`
+	NumErrors    int            `json:"numErrors"`
+	NumFailures  int            `json:"numFailures"`
+	NumSuccesses int            `json:"numSuccesses"`
+	LatencyMS    LatencySummary `json:"latencyMS"`
+	LastError    string         `json:"lastError,omitempty"`
+
+	latencies []float64
+}
+
+// NamespaceComparison compares the final contents of a collection written by mirrored writes on
+// the two clusters. Documents are compared without their _id, which the driver generates
+// independently for each cluster.
+type NamespaceComparison struct {
+	Namespace    string `json:"namespace"`
+	Count        int    `json:"count"`
+	ControlCount int    `json:"controlCount"`
+	// number of documents of either cluster without an equal document on the other
+	NumDifferent int    `json:"numDifferent"`
+	Error        string `json:"error,omitempty"`
+}
+
+// DualClusterComparison reports the mirrored writes of a dual-cluster comparison, how the cluster
+// under maintenance differed from the control cluster, and whether both ended with the same data.
+type DualClusterComparison struct {
+	Clusters          []*ClusterWriteStats   `json:"clusters"`
+	ErrorDelta        int                    `json:"errorDelta"`
+	P99LatencyDeltaMS float64                `json:"p99LatencyDeltaMS"`
+	Namespaces        []*NamespaceComparison `json:"namespaces"`
+	DataMatches       bool                   `json:"dataMatches"`
+}
+
+// mirror runs every write of the workload a second time against a control cluster that undergoes
+// no maintenance, see Options.ControlURI, so that errors and latencies caused by the maintenance
+// can be told apart from those of the workload itself.
+type mirror struct {
+	client *mongo.Client
+	// written namespaces, keyed by "db.coll", in the form [db, coll]
+	namespaces map[string][2]string
+
+	mu       sync.Mutex
+	clusters [2]*ClusterWriteStats
+}
+
+func newMirror(client *mongo.Client) *mirror {
+	return &mirror{
+		client:     client,
+		namespaces: make(map[string][2]string),
+		clusters: [2]*ClusterWriteStats{
+			{Cluster: "maintenance"},
+			{Cluster: "control"},
+		},
+	}
+}
+
+// mirrored reports whether op is mirrored to the control cluster. Writes in transactions are not,
+// since the control write could not be rolled back with the transaction.
+func mirrored(ctx context.Context, op *operation) bool {
+	return comparisonOperations[op.Name] && mongo.SessionFromContext(ctx) == nil
+}
+
+// run runs op with fn against coll, then against the same namespace of the control cluster, and
+// returns the outcome of the former.
+func (m *mirror) run(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation, fn collectionOperationFunc) (bool, error) {
+	start := time.Now()
+	pass, err := fn(ctx, r, coll, op)
+	m.record(m.clusters[0], pass, err, time.Since(start))
+
+	db, name := coll.Database().Name(), coll.Name()
+	m.namespaces[db+"."+name] = [2]string{db, name}
+	control := m.client.Database(db).Collection(name)
+	start = time.Now()
+	controlPass, controlErr := fn(context.Background(), r, control, op)
+	m.record(m.clusters[1], controlPass, controlErr, time.Since(start))
+
+	return pass, err
+}
+
+func (m *mirror) record(stats *ClusterWriteStats, pass bool, err error, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch {
+	case err != nil:
+		stats.NumErrors++
+		stats.LastError = err.Error()
+	case pass:
+		stats.NumSuccesses++
+		stats.latencies = append(stats.latencies, milliseconds(latency))
+	default:
+		stats.NumFailures++
+	}
+}
+
+// compare compares the final contents of the written namespaces on both clusters, read from the
+// primaries with a majority read concern, records a failure for each namespace whose contents
+// differ and returns the comparison.
+func (m *mirror) compare(r *workloadRunner) *DualClusterComparison {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	comparison := &DualClusterComparison{DataMatches: true}
+	for _, stats := range m.clusters {
+		copied := *stats
+		copied.LatencyMS = summarizeLatencies(stats.latencies)
+		copied.latencies = nil
+		comparison.Clusters = append(comparison.Clusters, &copied)
+	}
+	maintenance, control := comparison.Clusters[0], comparison.Clusters[1]
+	comparison.ErrorDelta = maintenance.NumErrors - control.NumErrors
+	comparison.P99LatencyDeltaMS = maintenance.LatencyMS.P99 - control.LatencyMS.P99
+
+	names := make([]string, 0, len(m.namespaces))
+	for ns := range m.namespaces {
+		names = append(names, ns)
+	}
+	sort.Strings(names)
+	for _, ns := range names {
+		db, coll := m.namespaces[ns][0], m.namespaces[ns][1]
+		result := &NamespaceComparison{Namespace: ns}
+		comparison.Namespaces = append(comparison.Namespaces, result)
+
+		docs, err := readWithoutIDs(r.client, db, coll)
+		if err == nil {
+			var controlDocs map[string]int
+			if controlDocs, err = readWithoutIDs(m.client, db, coll); err == nil {
+				result.Count, result.ControlCount = total(docs), total(controlDocs)
+				result.NumDifferent = multisetDifference(docs, controlDocs)
+			}
+		}
+		switch {
+		case err != nil:
+			result.Error = err.Error()
+			comparison.DataMatches = false
+			r.recordError(fmt.Errorf("comparing %s across clusters failed: %v", ns, err))
+		case result.NumDifferent > 0:
+			comparison.DataMatches = false
+			r.recordFailure(fmt.Sprintf("%s differs between the clusters: %d documents on the cluster under maintenance, %d on the control cluster, %d without a match",
+				ns, result.Count, result.ControlCount, result.NumDifferent))
+		}
+	}
+	return comparison
+}
+
+// readWithoutIDs returns the documents of db.coll without their _id, as canonical extended JSON,
+// with the number of times each occurs.
+func readWithoutIDs(client *mongo.Client, db, coll string) (map[string]int, error) {
+	collOpts := options.Collection().
+		SetReadPreference(readpref.Primary()).
+		SetReadConcern(readconcern.Majority())
+	opts := options.Find().SetProjection(bson.D{{Key: "_id", Value: 0}})
+	cursor, err := client.Database(db).Collection(coll, collOpts).Find(context.Background(), emptyDoc, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	docs := make(map[string]int)
+	for cursor.Next(context.Background()) {
+		data, err := bson.MarshalExtJSON(cursor.Current, true, false)
+		if err != nil {
+			return nil, err
+		}
+		docs[string(data)]++
+	}
+	return docs, cursor.Err()
+}
+
+func total(docs map[string]int) int {
+	n := 0
+	for _, count := range docs {
+		n += count
+	}
+	return n
+}
+
+// multisetDifference returns the number of documents of a without an equal document in b, plus
+// those of b without one in a.
+func multisetDifference(a, b map[string]int) int {
+	diff := 0
+	for doc, count := range a {
+		if d := count - b[doc]; d > 0 {
+			diff += d
+		}
+	}
+	for doc, count := range b {
+		if d := count - a[doc]; d > 0 {
+			diff += d
+		}
+	}
+	return diff
+}
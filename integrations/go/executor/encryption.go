@@ -0,0 +1,281 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Encrypted workloads exercise client-side field level encryption and queryable encryption across
+// maintenance, which can break the key vault reads and the query analysis of mongocryptd or
+// crypt_shared. A client entity with autoEncryptOpts encrypts and decrypts the fields of the
+// documents its collections read and write, and a client encryption entity runs createDataKey,
+// encrypt and decrypt operations. The credentials of the KMS providers are never part of the
+// workload; both take them from Options.KMS. The driver only supports encryption when the executor
+// is built with the "cse" tag, i.e. with GO_BUILD_TAGS=cse, and libmongocrypt installed.
+
+// defaultKeyVaultNamespace is where data keys are stored unless the workload names another
+// namespace.
+const defaultKeyVaultNamespace = "keyvault.datakeys"
+
+// defaultEncryptionAlgorithm is the algorithm of encrypt operations that do not name one.
+const defaultEncryptionAlgorithm = "AEAD_AES_256_CBC_HMAC_SHA_512-Random"
+
+// autoEncryptOpts configures the automatic encryption of a client entity.
+type autoEncryptOpts struct {
+	// defaults to keyvault.datakeys
+	KeyVaultNamespace string `bson:"keyVaultNamespace"`
+	// names of the KMS providers of Options.KMS the client may use; defaults to all of them
+	KMSProviders []string `bson:"kmsProviders"`
+	// JSON schemas of the encrypted fields of client-side field level encryption, keyed by
+	// namespace
+	SchemaMap map[string]bson.Raw `bson:"schemaMap"`
+	// encrypted fields of queryable encryption, keyed by namespace; createCollection operations on
+	// these namespaces also create the metadata collections
+	EncryptedFieldsMap   map[string]bson.Raw `bson:"encryptedFieldsMap"`
+	BypassAutoEncryption bool                `bson:"bypassAutoEncryption"`
+	// options of mongocryptd or crypt_shared, e.g. cryptSharedLibPath or mongocryptdBypassSpawn
+	ExtraOptions map[string]interface{} `bson:"extraOptions"`
+}
+
+// clientEncryptionEntity is a named ClientEncryption that operations can use as their object.
+type clientEncryptionEntity struct {
+	ID string `bson:"id"`
+	// ID of the client entity the data keys are read and written with; defaults to the workload
+	// client
+	KeyVaultClient string `bson:"keyVaultClient"`
+	// defaults to keyvault.datakeys
+	KeyVaultNamespace string `bson:"keyVaultNamespace"`
+	// names of the KMS providers of Options.KMS the entity may use; defaults to all of them
+	KMSProviders []string `bson:"kmsProviders"`
+}
+
+// clientEncryption is a client encryption entity and what its last operations produced, so that a
+// loop can create a data key, encrypt a value with it and decrypt it again.
+type clientEncryption struct {
+	ce *mongo.ClientEncryption
+	// the data key created by the last createDataKey operation, if any
+	keyID *primitive.Binary
+	// the value given to the last encrypt operation and the ciphertext it returned
+	plaintext  bson.RawValue
+	ciphertext *primitive.Binary
+}
+
+// kmsProviders returns the credentials and TLS configuration of the named KMS providers of
+// Options.KMS, or of all of them if names is empty.
+func (r *workloadRunner) kmsProviders(names []string) (map[string]map[string]interface{}, map[string]*tls.Config, error) {
+	if r.kms == nil || len(r.kms.Providers) == 0 {
+		return nil, nil, errors.New("encryption requires a KMS provider, but none is configured")
+	}
+	if len(names) == 0 {
+		names = r.kms.ProviderNames()
+	}
+	providers := make(map[string]map[string]interface{}, len(names))
+	tlsConfig := make(map[string]*tls.Config)
+	for _, name := range names {
+		credentials, ok := r.kms.Providers[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("the %s KMS provider is not configured", name)
+		}
+		providers[name] = credentials
+		if config, ok := r.kms.TLSConfig[name]; ok {
+			tlsConfig[name] = config
+		}
+	}
+	return providers, tlsConfig, nil
+}
+
+// autoEncryptionOptions returns the driver options for spec.
+func (r *workloadRunner) autoEncryptionOptions(spec *autoEncryptOpts) (*options.AutoEncryptionOptions, error) {
+	providers, tlsConfig, err := r.kmsProviders(spec.KMSProviders)
+	if err != nil {
+		return nil, err
+	}
+	keyVaultNamespace := spec.KeyVaultNamespace
+	if keyVaultNamespace == "" {
+		keyVaultNamespace = defaultKeyVaultNamespace
+	}
+	opts := options.AutoEncryption().
+		SetKeyVaultNamespace(keyVaultNamespace).
+		SetKmsProviders(providers).
+		SetTLSConfig(tlsConfig).
+		SetBypassAutoEncryption(spec.BypassAutoEncryption)
+	if len(spec.SchemaMap) > 0 {
+		opts.SetSchemaMap(namespaceMap(spec.SchemaMap))
+	}
+	if len(spec.EncryptedFieldsMap) > 0 {
+		opts.SetEncryptedFieldsMap(namespaceMap(spec.EncryptedFieldsMap))
+	}
+	if len(spec.ExtraOptions) > 0 {
+		opts.SetExtraOptions(spec.ExtraOptions)
+	}
+	return opts, nil
+}
+
+// namespaceMap converts a map of documents keyed by namespace to the type the driver takes.
+func namespaceMap(docs map[string]bson.Raw) map[string]interface{} {
+	m := make(map[string]interface{}, len(docs))
+	for ns, doc := range docs {
+		m[ns] = doc
+	}
+	return m
+}
+
+func (r *workloadRunner) createClientEncryptionEntities(entities []*clientEncryptionEntity) error {
+	for _, entity := range entities {
+		if entity.ID == "" {
+			return errors.New("client encryption entities require an id")
+		}
+		if _, ok := objectTypes[entity.ID]; ok {
+			return fmt.Errorf("client encryption entity id %q is reserved", entity.ID)
+		}
+		_, isCollection := r.collections[entity.ID]
+		if _, ok := r.clientEncryptions[entity.ID]; ok || isCollection {
+			return fmt.Errorf("duplicate entity id %q", entity.ID)
+		}
+
+		client := r.client
+		if entity.KeyVaultClient != "" {
+			var ok bool
+			if client, ok = r.clients[entity.KeyVaultClient]; !ok {
+				return fmt.Errorf("client encryption entity %q uses unknown client %q", entity.ID, entity.KeyVaultClient)
+			}
+		}
+		providers, tlsConfig, err := r.kmsProviders(entity.KMSProviders)
+		if err != nil {
+			return fmt.Errorf("client encryption entity %q: %v", entity.ID, err)
+		}
+		keyVaultNamespace := entity.KeyVaultNamespace
+		if keyVaultNamespace == "" {
+			keyVaultNamespace = defaultKeyVaultNamespace
+		}
+		ce, err := mongo.NewClientEncryption(client, options.ClientEncryption().
+			SetKeyVaultNamespace(keyVaultNamespace).
+			SetKmsProviders(providers).
+			SetTLSConfig(tlsConfig))
+		if err != nil {
+			return fmt.Errorf("client encryption entity %q: %v", entity.ID, err)
+		}
+		r.clientEncryptions[entity.ID] = &clientEncryption{ce: ce}
+	}
+	return nil
+}
+
+// closeClientEncryptions closes the client encryption entities.
+func (r *workloadRunner) closeClientEncryptions() {
+	for _, entity := range r.clientEncryptions {
+		_ = entity.ce.Close(context.Background())
+	}
+}
+
+func (r *workloadRunner) executeClientEncryptionOperation(entity *clientEncryption, op *operation) (bool, error) {
+	switch op.Name {
+	case "createDataKey":
+		return true, entity.createDataKey(op)
+	case "encrypt":
+		return true, entity.encrypt(op)
+	case "decrypt":
+		return entity.decrypt()
+	default:
+		return false, harnessError(errors.New("unrecognized clientEncryption operation: " + op.Name))
+	}
+}
+
+// createDataKey creates a data key with the KMS provider named by the kmsProvider argument, and
+// the optional masterKey and keyAltNames arguments. Later encrypt operations without a keyAltName
+// use the key.
+func (c *clientEncryption) createDataKey(op *operation) error {
+	var provider string
+	opts := options.DataKey()
+	elems, _ := op.Arguments.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "kmsProvider":
+			provider = val.StringValue()
+		case "masterKey":
+			opts.SetMasterKey(val.Document())
+		case "keyAltNames":
+			var names []string
+			if err := val.Unmarshal(&names); err != nil {
+				return fmt.Errorf("invalid keyAltNames: %v", err)
+			}
+			opts.SetKeyAltNames(names)
+		default:
+			return harnessError(fmt.Errorf("unrecognized createDataKey option: %v", key))
+		}
+	}
+
+	keyID, err := c.ce.CreateDataKey(context.Background(), provider, opts)
+	if err != nil {
+		return err
+	}
+	c.keyID = &keyID
+	return nil
+}
+
+// encrypt explicitly encrypts the value argument with the data key named by the keyAltName
+// argument, or with the data key of the last createDataKey operation, and the algorithm argument,
+// which defaults to AEAD_AES_256_CBC_HMAC_SHA_512-Random. A later decrypt operation decrypts the
+// result.
+func (c *clientEncryption) encrypt(op *operation) error {
+	var value bson.RawValue
+	opts := options.Encrypt().SetAlgorithm(defaultEncryptionAlgorithm)
+	var keyAltName string
+	elems, _ := op.Arguments.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "value":
+			value = val
+		case "algorithm":
+			opts.SetAlgorithm(val.StringValue())
+		case "keyAltName":
+			keyAltName = val.StringValue()
+			opts.SetKeyAltName(keyAltName)
+		default:
+			return harnessError(fmt.Errorf("unrecognized encrypt option: %v", key))
+		}
+	}
+	if keyAltName == "" {
+		if c.keyID == nil {
+			return errors.New("encrypt has no keyAltName and no data key was created")
+		}
+		opts.SetKeyID(*c.keyID)
+	}
+
+	ciphertext, err := c.ce.Encrypt(context.Background(), value, opts)
+	if err != nil {
+		return err
+	}
+	c.plaintext = value
+	c.ciphertext = &ciphertext
+	return nil
+}
+
+// decrypt explicitly decrypts the ciphertext of the last encrypt operation. A value that differs
+// from the one that was encrypted is a failure.
+func (c *clientEncryption) decrypt() (bool, error) {
+	if c.ciphertext == nil {
+		return false, errors.New("decrypt has no value to decrypt, since no value was encrypted")
+	}
+	value, err := c.ce.Decrypt(context.Background(), *c.ciphertext)
+	if err != nil {
+		return false, err
+	}
+	if value.Type != c.plaintext.Type || !bytes.Equal(value.Value, c.plaintext.Value) {
+		return false, &failure{msg: fmt.Sprintf("decrypted %v, but %v was encrypted", value, c.plaintext)}
+	}
+	return true, nil
+}
@@ -0,0 +1,155 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// endpointFailover makes the executor switch the workload client between the connection string it
+// was started with and those in Options.FailoverURIs, e.g. the old and the new address of a cluster
+// being migrated. The executor moves on to the next connection string, wrapping around after the
+// last, at the times of the schedule or after a run of consecutive errors. Switches happen at
+// iteration boundaries. Client entities keep the connection string they were created with.
+type endpointFailover struct {
+	// seconds after the loop started at which to switch to the next connection string
+	Schedule []float64
+	// number of consecutive operation errors after which to switch; zero to only follow the
+	// schedule
+	AfterConsecutiveErrors int `bson:"afterConsecutiveErrors"`
+}
+
+// EndpointPhase records a period in which the workload client used one connection string.
+type EndpointPhase struct {
+	// the connection string without credentials
+	URI string `json:"uri"`
+	// seconds since the Unix epoch
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	// "initial", "schedule" or "errors"
+	Reason       string `json:"reason"`
+	NumErrors    int    `json:"numErrors"`
+	NumSuccesses int    `json:"numSuccesses"`
+}
+
+// endpointSwitcher tracks the connection strings of an endpoint failover.
+type endpointSwitcher struct {
+	config  *endpointFailover
+	uris    []string
+	current int
+	// start of the operation loop
+	start time.Time
+	// index of the next entry of the schedule
+	nextScheduled     int
+	consecutiveErrors int
+}
+
+// recordEndpointResult counts the result of an operation towards the current endpoint phase.
+func (r *workloadRunner) recordEndpointResult(err error) {
+	s := r.endpoints
+	if s == nil {
+		return
+	}
+	phase := &r.results.Endpoints[len(r.results.Endpoints)-1]
+	if _, isFailure := err.(*failure); err != nil && !isFailure {
+		phase.NumErrors++
+		s.consecutiveErrors++
+		return
+	}
+	phase.NumSuccesses++
+	s.consecutiveErrors = 0
+}
+
+// maybeSwitchEndpoint switches the workload client to the next connection string if the schedule
+// or the consecutive errors of the endpoint failover call for it. It is called at iteration
+// boundaries.
+func (r *workloadRunner) maybeSwitchEndpoint() {
+	s := r.endpoints
+	if s == nil {
+		return
+	}
+
+	reason := ""
+	elapsed := time.Since(s.start).Seconds()
+	switch {
+	case s.nextScheduled < len(s.config.Schedule) && elapsed >= s.config.Schedule[s.nextScheduled]:
+		s.nextScheduled++
+		reason = "schedule"
+	case s.config.AfterConsecutiveErrors > 0 && s.consecutiveErrors >= s.config.AfterConsecutiveErrors:
+		reason = "errors"
+	default:
+		return
+	}
+
+	next := (s.current + 1) % len(s.uris)
+	if err := r.switchEndpoint(s.uris[next]); err != nil {
+		r.recordError(fmt.Errorf("switching to %s failed: %v", redactURI(s.uris[next]), err))
+		return
+	}
+	s.current = next
+	s.consecutiveErrors = 0
+
+	t := now()
+	r.results.Endpoints[len(r.results.Endpoints)-1].End = t
+	r.results.Endpoints = append(r.results.Endpoints, EndpointPhase{
+		URI:    redactURI(s.uris[next]),
+		Start:  t,
+		Reason: reason,
+	})
+	fmt.Fprintf(os.Stderr, "switched the workload client to %s (%s)\n", redactURI(s.uris[next]), reason)
+}
+
+// switchEndpoint replaces the workload client with one connected to uri, with the same monitors and
+// dialer. Collection entities that use the workload client are recreated from the new client.
+func (r *workloadRunner) switchEndpoint(uri string) error {
+	opts := options.Client().ApplyURI(uri).
+		SetMonitor(r.clientOpts.Monitor).
+		SetServerMonitor(r.clientOpts.ServerMonitor).
+		SetPoolMonitor(r.clientOpts.PoolMonitor)
+	if r.clientOpts.Dialer != nil {
+		opts.SetDialer(r.clientOpts.Dialer)
+	}
+	if r.clientOpts.DisableOCSPEndpointCheck != nil {
+		opts.SetDisableOCSPEndpointCheck(*r.clientOpts.DisableOCSPEndpointCheck)
+	}
+	client, err := mongo.Connect(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+
+	old := r.client
+	r.client = client
+	r.clientOpts = opts
+	r.uri = uri
+	r.coll = client.Database(r.coll.Database().Name()).Collection(r.coll.Name())
+	for id, coll := range r.collections {
+		if _, ok := r.collectionClients[id]; !ok {
+			r.collections[id] = client.Database(coll.Database().Name()).Collection(coll.Name())
+		}
+	}
+	_ = old.Disconnect(context.Background())
+	return nil
+}
+
+// redactURI returns uri without the credentials it may contain.
+func redactURI(uri string) string {
+	scheme := strings.Index(uri, "://")
+	if scheme < 0 {
+		return uri
+	}
+	rest := uri[scheme+3:]
+	hosts := rest
+	if slash := strings.Index(rest, "/"); slash >= 0 {
+		hosts = rest[:slash]
+	}
+	at := strings.LastIndex(hosts, "@")
+	if at < 0 {
+		return uri
+	}
+	return uri[:scheme+3] + rest[at+1:]
+}
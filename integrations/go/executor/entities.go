@@ -0,0 +1,46 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+)
+
+// collectionEntity is a named collection that operations can use as their object, which allows a
+// workload to spread its operations across several namespaces.
+type collectionEntity struct {
+	ID string `bson:"id"`
+	// defaults to the workload database
+	DatabaseName   string `bson:"databaseName"`
+	CollectionName string `bson:"collectionName"`
+	// ID of the client entity to use; defaults to the workload client
+	Client string `bson:"client"`
+}
+
+func (r *workloadRunner) createCollectionEntities(entities []*collectionEntity) error {
+	for _, entity := range entities {
+		if entity.ID == "" || entity.CollectionName == "" {
+			return errors.New("collection entities require an id and a collectionName")
+		}
+		if _, ok := objectTypes[entity.ID]; ok {
+			return fmt.Errorf("collection entity id %q is reserved", entity.ID)
+		}
+		if _, ok := r.collections[entity.ID]; ok {
+			return fmt.Errorf("duplicate collection entity id %q", entity.ID)
+		}
+
+		dbName := entity.DatabaseName
+		if dbName == "" {
+			dbName = r.coll.Database().Name()
+		}
+		client := r.client
+		if entity.Client != "" {
+			var ok bool
+			if client, ok = r.clients[entity.Client]; !ok {
+				return fmt.Errorf("collection entity %q uses unknown client %q", entity.ID, entity.Client)
+			}
+			r.collectionClients[entity.ID] = entity.Client
+		}
+		r.collections[entity.ID] = client.Database(dbName).Collection(entity.CollectionName)
+	}
+	return nil
+}
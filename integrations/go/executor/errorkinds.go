@@ -0,0 +1,155 @@
+package executor
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Kinds of errors in Results.ErrorKinds and ErrorRecord.Kind. TLS handshake and OCSP errors are
+// kept apart from other network errors, since they are expected while Atlas rotates the
+// certificates of a cluster and point at the certificate chain rather than at connectivity.
+const (
+	errorKindTLSHandshake = "tlsHandshake"
+	errorKindOCSP         = "ocsp"
+	errorKindNetwork      = "network"
+	errorKindTimeout      = "timeout"
+	errorKindServer       = "server"
+	errorKindOther        = "other"
+)
+
+// classifyError returns the kind of err. The driver wraps the errors of the TLS handshake in its
+// own connection errors, so they are looked for in the chain of wrapped errors first, and by
+// message for driver versions that do not wrap them.
+func classifyError(err error) string {
+	msg := err.Error()
+	if strings.Contains(msg, "OCSP") {
+		return errorKindOCSP
+	}
+
+	var invalid x509.CertificateInvalidError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var recordHeader tls.RecordHeaderError
+	switch {
+	case errors.As(err, &invalid), errors.As(err, &unknownAuthority), errors.As(err, &hostname),
+		errors.As(err, &recordHeader), strings.Contains(msg, "x509: "), strings.Contains(msg, "tls: "):
+		return errorKindTLSHandshake
+	case mongo.IsTimeout(err):
+		return errorKindTimeout
+	case mongo.IsNetworkError(err):
+		return errorKindNetwork
+	}
+
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		return errorKindServer
+	}
+	return errorKindOther
+}
+
+// Categories of errors in ErrorRecord.Category and RunError.Category, which tell problems of the
+// driver or the cluster apart from problems of the executor. The executor binary exits with a
+// distinct status for each of them.
+const (
+	// connecting to the cluster or preparing it for the workload failed
+	ErrorCategorySetup = "setup"
+	// an operation of the workload returned an error
+	ErrorCategoryOperation = "operation"
+	// an operation returned an unexpected result, or a check of the workload did not hold
+	ErrorCategoryFailure = "failure"
+	// the workload is invalid, or the executor itself failed, e.g. it panicked
+	ErrorCategoryHarness = "harness"
+)
+
+// RunError is an error that kept a workload from running to completion, with the category of its
+// cause.
+type RunError struct {
+	Category string
+	Err      error
+}
+
+func (e *RunError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
+
+func setupError(err error) error {
+	return &RunError{Category: ErrorCategorySetup, Err: err}
+}
+
+func harnessError(err error) error {
+	return &RunError{Category: ErrorCategoryHarness, Err: err}
+}
+
+// ErrorCategory returns the category of an error returned by Run. Errors that were not classified
+// are harness errors.
+func ErrorCategory(err error) string {
+	var runErr *RunError
+	if errors.As(err, &runErr) {
+		return runErr.Category
+	}
+	return ErrorCategoryHarness
+}
+
+// errorCategory returns the category of an error recorded while running the workload, which is
+// that of the operation that returned it unless it was classified otherwise.
+func errorCategory(err error) string {
+	var runErr *RunError
+	if errors.As(err, &runErr) {
+		return runErr.Category
+	}
+	return ErrorCategoryOperation
+}
+
+// serverErrorDetails returns the code, code name and error labels of the server error in the chain
+// of err, if there is one. Write errors are reported by the code of their first write error, or of
+// their write concern error if they have none.
+func serverErrorDetails(err error) (code int, codeName string, labels []string) {
+	var commandErr mongo.CommandError
+	var writeErr mongo.WriteException
+	var bulkErr mongo.BulkWriteException
+	switch {
+	case errors.As(err, &commandErr):
+		return int(commandErr.Code), commandErr.Name, commandErr.Labels
+	case errors.As(err, &writeErr):
+		code, codeName = writeErrorCode(writeErr.WriteConcernError)
+		if len(writeErr.WriteErrors) > 0 {
+			code, codeName = writeErr.WriteErrors[0].Code, ""
+		}
+		return code, codeName, writeErr.Labels
+	case errors.As(err, &bulkErr):
+		code, codeName = writeErrorCode(bulkErr.WriteConcernError)
+		if len(bulkErr.WriteErrors) > 0 {
+			code, codeName = bulkErr.WriteErrors[0].Code, ""
+		}
+		return code, codeName, bulkErr.Labels
+	}
+	return 0, "", nil
+}
+
+// recordPanic records the value p of a recovered panic as a harness error and returns the error.
+// The stack of the panic is written to stderr.
+func (r *workloadRunner) recordPanic(p interface{}) error {
+	err := harnessError(fmt.Errorf("executor panicked: %v", p))
+	fmt.Fprintf(os.Stderr, "%v\n%s", err, debug.Stack())
+	r.results.HarnessError = err.Error()
+	r.recordError(err)
+	return err
+}
+
+func writeErrorCode(wcErr *mongo.WriteConcernError) (int, string) {
+	if wcErr == nil {
+		return 0, ""
+	}
+	return wcErr.Code, wcErr.Name
+}
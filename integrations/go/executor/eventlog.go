@@ -0,0 +1,343 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Default limits on the number of records kept in memory, see Options.
+const (
+	DefaultMaxEvents   = 100000
+	DefaultMaxErrors   = 10000
+	DefaultMaxFailures = 10000
+)
+
+// RecordTime is the time of a record in the forms every record carries in addition to its float
+// seconds since the Unix epoch: RFC 3339 for reading, and milliseconds since the Unix epoch for
+// joining the artifacts of the executor with other logs, such as those of Atlas.
+type RecordTime struct {
+	Timestamp   string `json:"timestamp"`
+	TimestampMS int64  `json:"timestampMS"`
+}
+
+func newRecordTime(t time.Time) RecordTime {
+	return RecordTime{
+		Timestamp:   t.UTC().Format(time.RFC3339Nano),
+		TimestampMS: t.UnixNano() / int64(time.Millisecond),
+	}
+}
+
+// stamp returns the current time as seconds since the Unix epoch and as a RecordTime.
+func stamp() (float64, RecordTime) {
+	t := time.Now()
+	return seconds(t), newRecordTime(t)
+}
+
+// seconds returns t in seconds since the Unix epoch, the unit of the times of the records.
+func seconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+// Event is a command event observed on the workload client.
+type Event struct {
+	Name        string  `json:"name"`
+	CommandName string  `json:"commandName"`
+	RequestID   int64   `json:"requestId"`
+	Address     string  `json:"address"`
+	ObservedAt  float64 `json:"observedAt"`
+	RecordTime
+	// the database and the command as canonical extended JSON, for started events if the executor
+	// captures commands, see Options.CaptureCommands
+	DatabaseName string          `json:"databaseName,omitempty"`
+	Command      json.RawMessage `json:"command,omitempty"`
+}
+
+// ErrorRecord describes an error or a failure that occurred while running the workload.
+type ErrorRecord struct {
+	Error string  `json:"error"`
+	Time  float64 `json:"time"`
+	RecordTime
+	// label of the operation that caused the error, if it has one
+	Label string `json:"label,omitempty"`
+	// kind of the error, see Results.ErrorKinds; empty for failures
+	Kind string `json:"kind,omitempty"`
+	// category of the error, e.g. "operation" or "harness", or "failure" for failures
+	Category string `json:"category"`
+	// code, code name and error labels of the server error, if the error is one
+	Code     int      `json:"code,omitempty"`
+	CodeName string   `json:"codeName,omitempty"`
+	Labels   []string `json:"labels,omitempty"`
+}
+
+// Records are the events, errors and failures recorded while running a workload.
+type Records struct {
+	Events   []Event       `json:"events"`
+	Errors   []ErrorRecord `json:"errors"`
+	Failures []ErrorRecord `json:"failures"`
+}
+
+// eventLimits caps the events, errors and failures kept in memory. A negative limit means the
+// records are not capped.
+type eventLimits struct {
+	maxEvents   int
+	maxErrors   int
+	maxFailures int
+}
+
+func (opts Options) limits() eventLimits {
+	orDefault := func(limit, def int) int {
+		if limit == 0 {
+			return def
+		}
+		return limit
+	}
+	return eventLimits{
+		maxEvents:   orDefault(opts.MaxEvents, DefaultMaxEvents),
+		maxErrors:   orDefault(opts.MaxErrors, DefaultMaxErrors),
+		maxFailures: orDefault(opts.MaxFailures, DefaultMaxFailures),
+	}
+}
+
+func withinLimit(n, limit int) bool {
+	return limit < 0 || n < limit
+}
+
+func now() float64 {
+	return seconds(time.Now())
+}
+
+// recordError counts an error and keeps a record of it unless the error limit has been reached.
+func (r *workloadRunner) recordError(err error) {
+	r.recordLabeledError(err, "")
+}
+
+// recordLabeledError is like recordError for an error caused by an operation with the given label.
+func (r *workloadRunner) recordLabeledError(err error, label string) {
+	r.recordErrorAt(err, label, time.Now())
+}
+
+// recordErrorAt is like recordLabeledError for an error that occurred at t.
+func (r *workloadRunner) recordErrorAt(err error, label string, t time.Time) {
+	r.results.NumErrors++
+	if r.runErrors != nil {
+		atomic.AddInt64(r.runErrors, 1)
+	}
+	kind := classifyError(err)
+	if r.results.ErrorKinds == nil {
+		r.results.ErrorKinds = make(map[string]int)
+	}
+	r.results.ErrorKinds[kind]++
+	if !withinLimit(r.numErrors, r.limits.maxErrors) {
+		r.results.DroppedErrors++
+		return
+	}
+	r.numErrors++
+	code, codeName, labels := serverErrorDetails(err)
+	// the progress reporter may be flushing the records
+	r.eventsMu.Lock()
+	defer r.eventsMu.Unlock()
+	r.results.Errors = append(r.results.Errors, ErrorRecord{
+		Error:      err.Error(),
+		Time:       seconds(t),
+		RecordTime: newRecordTime(t),
+		Label:      label,
+		Kind:       kind,
+		Category:   errorCategory(err),
+		Code:       code,
+		CodeName:   codeName,
+		Labels:     labels,
+	})
+}
+
+// recordFailure counts a failure and keeps a record of it unless the failure limit has been
+// reached.
+func (r *workloadRunner) recordFailure(msg string) {
+	r.recordLabeledFailure(msg, "")
+}
+
+// recordLabeledFailure is like recordFailure for a failure of an operation with the given label.
+func (r *workloadRunner) recordLabeledFailure(msg string, label string) {
+	r.recordFailureAt(msg, label, time.Now())
+}
+
+// recordFailureAt is like recordLabeledFailure for a failure that occurred at t.
+func (r *workloadRunner) recordFailureAt(msg string, label string, t time.Time) {
+	r.results.NumFailures++
+	if !withinLimit(r.numFailures, r.limits.maxFailures) {
+		r.results.DroppedFailures++
+		return
+	}
+	r.numFailures++
+	r.eventsMu.Lock()
+	defer r.eventsMu.Unlock()
+	r.results.Failures = append(r.results.Failures, ErrorRecord{
+		Error:      msg,
+		Time:       seconds(t),
+		RecordTime: newRecordTime(t),
+		Label:      label,
+		Category:   ErrorCategoryFailure,
+	})
+}
+
+// recordEvent keeps a record of a command event unless the event limit has been reached. It is
+// called from the command monitor and may run concurrently with the operation loop.
+func (r *workloadRunner) recordEvent(evt Event) {
+	r.recordEventAt(evt, time.Now())
+}
+
+// recordEventAt is recordEvent for an event observed at t.
+func (r *workloadRunner) recordEventAt(evt Event, t time.Time) {
+	r.eventsMu.Lock()
+	defer r.eventsMu.Unlock()
+
+	if !withinLimit(r.numEvents, r.limits.maxEvents) {
+		r.results.DroppedEvents++
+		return
+	}
+	r.numEvents++
+	evt.ObservedAt, evt.RecordTime = seconds(t), newRecordTime(t)
+	r.results.Events = append(r.results.Events, evt)
+}
+
+// flushRecords hands the records kept since the last flush to every FlushSink and releases them,
+// and the metrics of the interval since the last flush to every IntervalSink. Errors are reported
+// to stderr since flushing only provides durability for partial data; the records are still
+// dropped from memory to bound its growth. It must be called with flushMu held, see maybeFlush.
+func (r *workloadRunner) flushRecords() {
+	r.eventsMu.Lock()
+	records := r.results.Records
+	r.results.Records = Records{}
+	r.eventsMu.Unlock()
+	r.lastFlush = time.Now()
+
+	for _, sink := range r.sinks {
+		if fs, ok := sink.(FlushSink); ok {
+			if err := fs.Flush(&records); err != nil {
+				fmt.Fprintf(os.Stderr, "flushing records failed: %v\n", err)
+			}
+		}
+	}
+	r.writeIntervals()
+}
+
+// eventsFileSink writes events.json. Flushed records are appended to a partial file next to it,
+// one JSON-encoded Records document per line, and merged into events.json when the workload
+// finishes. If the executor dies, the partial file holds every record flushed so far.
+type eventsFileSink struct {
+	path string
+	// set once the partial file has been created by this sink, so that a partial file left behind
+	// by an earlier run is truncated rather than merged
+	flushed bool
+}
+
+// EventsFileSink returns a Sink that writes the recorded events, errors and failures as JSON to the
+// file at path, which is the format astrolabe reads from events.json. The sink implements
+// FlushSink; records flushed while the workload runs are kept in path + ".partial" until the
+// workload finishes.
+func EventsFileSink(path string) Sink {
+	return &eventsFileSink{path: path}
+}
+
+func (s *eventsFileSink) partialPath() string {
+	return s.path + ".partial"
+}
+
+func (s *eventsFileSink) Flush(records *Records) error {
+	if len(records.Events) == 0 && len(records.Errors) == 0 && len(records.Failures) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("marshal events failed: %v", err)
+	}
+
+	flags := os.O_APPEND | os.O_CREATE | os.O_WRONLY
+	if !s.flushed {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(s.partialPath(), flags, 0644)
+	if err != nil {
+		return err
+	}
+	s.flushed = true
+	if _, err = f.Write(append(data, '\n')); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Resume keeps the partial file of the executor process that died, so that its records are merged
+// into events.json together with those of this process.
+func (s *eventsFileSink) Resume() error {
+	s.flushed = true
+	return nil
+}
+
+func (s *eventsFileSink) WriteResults(results *Results) error {
+	var all Records
+	if s.flushed {
+		if err := s.readPartial(&all); err != nil {
+			return err
+		}
+	}
+	all.Events = append(all.Events, results.Events...)
+	all.Errors = append(all.Errors, results.Errors...)
+	all.Failures = append(all.Failures, results.Failures...)
+
+	// astrolabe expects arrays, even if nothing was recorded
+	if all.Events == nil {
+		all.Events = []Event{}
+	}
+	if all.Errors == nil {
+		all.Errors = []ErrorRecord{}
+	}
+	if all.Failures == nil {
+		all.Failures = []ErrorRecord{}
+	}
+
+	data, err := json.Marshal(all)
+	if err != nil {
+		return fmt.Errorf("marshal events failed: %v", err)
+	}
+	if err = writeFileAtomically(s.path, data); err != nil {
+		return err
+	}
+	if s.flushed {
+		return os.Remove(s.partialPath())
+	}
+	return nil
+}
+
+// readPartial appends the records in the partial file, if any, to all.
+func (s *eventsFileSink) readPartial(all *Records) error {
+	f, err := os.Open(s.partialPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	for {
+		var batch Records
+		err := dec.Decode(&batch)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading %s failed: %v", s.partialPath(), err)
+		}
+		all.Events = append(all.Events, batch.Events...)
+		all.Errors = append(all.Errors, batch.Errors...)
+		all.Failures = append(all.Failures, batch.Failures...)
+	}
+}
@@ -0,0 +1,1568 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var emptyDoc = []byte{5, 0, 0, 0, 0}
+
+type driverWorkload struct {
+	Collection  string
+	Database    string
+	Clients     []*clientEntity
+	Collections []*collectionEntity
+	InitialData []*collectionData `bson:"initialData"`
+	Hooks       workloadHooks
+	// the workload is skipped unless one of the requirements is met
+	RunOnRequirements []*RunOnRequirement `bson:"runOnRequirements"`
+	// legacy spelling of runOnRequirements, merged into it when the workload is parsed
+	RunOn       []*legacyRunOn `bson:"runOn"`
+	Verifier    bson.Raw
+	Operations  []*operation
+	Outcome     []*collectionOutcome
+	Tests       []*testCase
+	Faults      []*fault
+	DNSFaults   []*dnsFault  `bson:"dnsFaults"`
+	ClientChurn *clientChurn `bson:"clientChurn"`
+
+	WriteConcernComparison *writeConcernComparison `bson:"writeConcernComparison"`
+	LinearizabilityCheck   *linearizabilityCheck   `bson:"linearizabilityCheck"`
+	IterationBackoff       *iterationBackoff       `bson:"iterationBackoff"`
+	ErrorBudget            *errorBudget            `bson:"errorBudget"`
+	EndpointFailover       *endpointFailover       `bson:"endpointFailover"`
+	WeightedSelection      *weightedSelection      `bson:"weightedSelection"`
+	// number of workers that run the operation loop at once, see runWorkers; defaults to 1
+	Concurrency int
+	// limits the rate of the operation loop, see Pacing
+	Pacing *Pacing `bson:"pacing"`
+	// client encryption entities for explicit encryption operations, see clientEncryptionEntity
+	ClientEncryptions []*clientEncryptionEntity `bson:"clientEncryptions"`
+}
+
+type operation struct {
+	Object      string
+	Name        string
+	Arguments   bson.Raw
+	Result      interface{}
+	RetryPolicy *retryPolicy `bson:"retryPolicy"`
+	// relative frequency of the operation, see weightedSelection
+	Weight *float64
+	// joins the records and commands of the operation to the server logs, see withOperationLabel
+	Label string
+}
+
+// Results are the counts astrolabe uses to decide whether a workload passed. They are written to
+// results.json by the workload executor binary.
+type Results struct {
+	NumErrors       int `json:"numErrors"`
+	NumFailures     int `json:"numFailures"`
+	NumSuccesses    int `json:"numSuccesses"`
+	OutcomeFailures int `json:"outcomeFailures"`
+	// iterations of the operation loop that ran every operation, across all workers
+	NumIterations int `json:"numIterations"`
+	// retries made by the executor for operations with a retry policy, on top of those of the
+	// driver
+	NumAppRetries int `json:"numAppRetries,omitempty"`
+	// seed of all randomized behavior of the executor, see Options.Seed
+	Seed int64 `json:"seed"`
+	// number of errors per kind: tlsHandshake, ocsp, network, timeout, server or other
+	ErrorKinds map[string]int `json:"errorKinds,omitempty"`
+
+	// set if the workload did not run because the cluster does not meet its runOnRequirements
+	Skipped    bool   `json:"skipped,omitempty"`
+	SkipReason string `json:"skipReason,omitempty"`
+	// how the runOnRequirements of the workload and its test cases were evaluated, if it has any
+	Requirements *RequirementDiagnostics `json:"requirements,omitempty"`
+	// command distribution and outages per mongos, if the cluster is sharded
+	Mongos map[string]*MongosStats `json:"mongos,omitempty"`
+	// outcome counts per client, if the workload has client entities
+	Clients map[string]*ClientStats `json:"clients,omitempty"`
+	// clients created and destroyed alongside the workload, if it configures client churn
+	ClientChurn *ChurnStats `json:"clientChurn,omitempty"`
+	// number of failures caused by commands of a connection, cursor or transaction being sent to
+	// a different service than the one it is pinned to in load-balanced mode
+	PinningViolations int `json:"pinningViolations,omitempty"`
+	// servers the reads of operations with a tagged read preference were sent to, if there were any
+	Routing *RoutingStats `json:"routing,omitempty"`
+	// server selection and command execution latency per operation name, or per label for
+	// labelled operations
+	Latency map[string]*OperationLatency `json:"latency,omitempty"`
+	// collections tailed by tailCollection operations, keyed by namespace
+	Tailing map[string]*TailStats `json:"tailing,omitempty"`
+	// cursors iterated by iterateCursor operations, if the workload has any
+	Cursors *CursorStats `json:"cursors,omitempty"`
+	// error and latency differences between two write concerns, if the workload compares them
+	WriteConcernComparison *WriteConcernComparison `json:"writeConcernComparison,omitempty"`
+	// checks and stale reads of checkCausalConsistency operations, if the workload has any
+	CausalConsistency *CausalStats `json:"causalConsistency,omitempty"`
+	// reads and inconsistent or expired snapshots of snapshotReads operations, if the workload has any
+	Snapshot *SnapshotStats `json:"snapshot,omitempty"`
+	// the connection strings the workload client used, if the workload configures an endpoint
+	// failover
+	Endpoints []EndpointPhase `json:"endpoints,omitempty"`
+	// the error budget threshold that stopped the workload early, if one was breached
+	BudgetBreach *BudgetBreach `json:"budgetBreach,omitempty"`
+	// waits after iterations with errors, if the workload configures an iteration backoff
+	IterationBackoff *BackoffStats `json:"iterationBackoff,omitempty"`
+	// operations run by the loop per second, and the waits of its pacing, if it ran
+	Throughput *ThroughputStats `json:"throughput,omitempty"`
+	// commits, aborts and retries of the transactions run by withTransaction operations
+	Transactions *TransactionStats `json:"transactions,omitempty"`
+	// outcome of checking the history of a register for linearizability, if the workload has a
+	// linearizability check
+	Linearizability *LinearizabilityStats `json:"linearizability,omitempty"`
+	// outcomes, latencies and downtime per phase of the test, if the executor was given a phase
+	// file
+	Phases []*PhaseMetrics `json:"phases,omitempty"`
+	// offset of the clock of the primary from that of the executor, see Options.ClockSkewInterval
+	ClockSkew *ClockSkewStats `json:"clockSkew,omitempty"`
+	// operations of the synthetic load, see Options.SyntheticLoad
+	SyntheticLoad *LoadStats `json:"syntheticLoad,omitempty"`
+	// indexes missing or stale after the loop, if the workload declared any
+	Indexes *IndexStats `json:"indexes,omitempty"`
+	// mirrored writes and final data of the cluster under test and the control cluster, see
+	// Options.ControlURI
+	DualCluster *DualClusterComparison `json:"dualCluster,omitempty"`
+
+	// the panic that aborted the run, if the executor panicked; it is also recorded as an error of
+	// category ErrorCategoryHarness
+	HarnessError string `json:"harnessError,omitempty"`
+
+	// number of records not kept because a limit in Options was reached
+	DroppedEvents   int `json:"droppedEvents,omitempty"`
+	DroppedErrors   int `json:"droppedErrors,omitempty"`
+	DroppedFailures int `json:"droppedFailures,omitempty"`
+
+	// workload changes made while the executor was running, see Options.Reloads
+	Transitions []Transition `json:"transitions,omitempty"`
+	// size and growth of the heap of the executor, if it checks for leaks, see
+	// Options.MaxHeapGrowthMBPerHour
+	Heap *HeapStats `json:"heap,omitempty"`
+	// executor processes that resumed the workload after the previous one died, see
+	// Options.CheckpointFile
+	Restarts []Restart `json:"restarts,omitempty"`
+	// how long the clients of the workload were without a writable server
+	Failover *FailoverStats `json:"failover,omitempty"`
+
+	// server state transitions seen by the driver; see TopologyTimelineFileSink
+	Topology *TopologyTimeline `json:"-"`
+	// end-to-end latencies and throughput of the operation loop; see MetricsFileSink
+	Metrics *Metrics `json:"-"`
+	// SDAM and pool events of the clients of the workload; see SDAMEventsFileSink
+	SDAMEvents []SDAMEvent `json:"-"`
+
+	// per-operation counts, in the order the operations first appear in the workload
+	Operations []*OperationStats `json:"-"`
+
+	// records written to events.json that have not been flushed; see EventsFileSink
+	Records `json:"-"`
+}
+
+// OperationStats holds the outcome counts for all operations sharing a name.
+type OperationStats struct {
+	Name         string
+	NumErrors    int
+	NumFailures  int
+	NumSuccesses int
+	// retries made according to the retry policy of the operation
+	NumAppRetries int
+}
+
+var specTestRegistry = bson.NewRegistryBuilder().
+	RegisterTypeMapEntry(bson.TypeEmbeddedDocument, reflect.TypeOf(bson.Raw{})).Build()
+
+func executeInsertOne(ctx context.Context, coll *mongo.Collection, args bson.Raw, gen *textGenerator) (*mongo.InsertOneResult, error) {
+	doc := bson.Raw(emptyDoc)
+	size := 0
+	var text *textSpec
+	opts := options.InsertOne()
+
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "document":
+			doc = val.Document()
+		case "documentSize":
+			size = int(val.AsInt64())
+		case "text":
+			var err error
+			if text, err = parseTextSpec(val.Document()); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, harnessError(fmt.Errorf("unrecognized insertOne option: %v", key))
+		}
+	}
+	var err error
+	if text != nil {
+		if doc, err = gen.addText(doc, text); err != nil {
+			return nil, err
+		}
+	}
+	if size > 0 {
+		if doc, err = padDocument(doc, size); err != nil {
+			return nil, err
+		}
+	}
+	if label := operationLabel(ctx); label != "" {
+		opts = opts.SetComment(label)
+	}
+
+	return coll.InsertOne(ctx, doc, opts)
+}
+
+// executeInsertMany inserts each of the documents repeat times, with generated text if text is set,
+// padded to documentSize bytes if set. Documents that are repeated must not have an _id.
+func executeInsertMany(ctx context.Context, coll *mongo.Collection, args bson.Raw, gen *textGenerator) (*mongo.InsertManyResult, error) {
+	var docs []bson.Raw
+	repeat := 1
+	size := 0
+	var text *textSpec
+	opts := options.InsertMany()
+
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "documents":
+			vals, _ := val.Array().Values()
+			for _, v := range vals {
+				docs = append(docs, v.Document())
+			}
+		case "repeat":
+			repeat = int(val.AsInt64())
+		case "documentSize":
+			size = int(val.AsInt64())
+		case "ordered":
+			opts = opts.SetOrdered(val.Boolean())
+		case "text":
+			var err error
+			if text, err = parseTextSpec(val.Document()); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, harnessError(fmt.Errorf("unrecognized insertMany option: %v", key))
+		}
+	}
+
+	var batch []interface{}
+	for _, doc := range docs {
+		for i := 0; i < repeat; i++ {
+			// every repetition gets text of its own
+			d := doc
+			var err error
+			if text != nil {
+				if d, err = gen.addText(d, text); err != nil {
+					return nil, err
+				}
+			}
+			if size > 0 {
+				if d, err = padDocument(d, size); err != nil {
+					return nil, err
+				}
+			}
+			batch = append(batch, d)
+		}
+	}
+	if label := operationLabel(ctx); label != "" {
+		opts = opts.SetComment(label)
+	}
+	return coll.InsertMany(ctx, batch, opts)
+}
+
+func executeFind(ctx context.Context, coll *mongo.Collection, args bson.Raw) (*mongo.Cursor, error) {
+	filter := emptyDoc
+	opts := options.Find()
+
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "filter":
+			filter = val.Document()
+		case "sort":
+			opts = opts.SetSort(val.Document())
+		case "collation":
+			collation, err := createCollation(val.Document())
+			if err != nil {
+				return nil, err
+			}
+			opts = opts.SetCollation(collation)
+		case "readPreference":
+			var err error
+			if ctx, coll, err = withReadPreference(ctx, coll, val); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, harnessError(fmt.Errorf("unrecognized find option: %v", key))
+		}
+	}
+	if label := operationLabel(ctx); label != "" {
+		opts = opts.SetComment(label)
+	}
+
+	return coll.Find(ctx, filter, opts)
+}
+
+// withReadPreference returns coll with the read preference in val, see createReadPreference, and
+// a context under which the servers the reads are sent to are checked against the read preference.
+func withReadPreference(ctx context.Context, coll *mongo.Collection, val bson.RawValue) (context.Context, *mongo.Collection, error) {
+	rp, err := createReadPreference(val)
+	if err != nil {
+		return nil, nil, harnessError(fmt.Errorf("invalid readPreference: %v", err))
+	}
+	clone, err := coll.Clone(options.Collection().SetReadPreference(rp))
+	if err != nil {
+		return nil, nil, err
+	}
+	return withRoutedReadPreference(ctx, rp), clone, nil
+}
+
+// create an update document or pipeline from a bson.RawValue
+func createUpdate(updateVal bson.RawValue) (interface{}, error) {
+	switch updateVal.Type {
+	case bson.TypeEmbeddedDocument:
+		return updateVal.Document(), nil
+	case bson.TypeArray:
+		var updateDocs []bson.Raw
+		docs, _ := updateVal.Array().Values()
+		for _, doc := range docs {
+			updateDocs = append(updateDocs, doc.Document())
+		}
+
+		return updateDocs, nil
+	default:
+		return nil, harnessError(fmt.Errorf("unrecognized update type: %v", updateVal.Type))
+	}
+}
+
+// parseUpdateArguments returns the filter, update and options of the update operation name. The
+// update is padded by paddingSize bytes if set, see padUpdate.
+func parseUpdateArguments(name string, args bson.Raw) (bson.Raw, interface{}, *options.UpdateOptions, error) {
+	filter := emptyDoc
+	var update interface{} = emptyDoc
+	paddingSize := 0
+	opts := options.Update()
+
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "filter":
+			filter = val.Document()
+		case "update":
+			var err error
+			if update, err = createUpdate(val); err != nil {
+				return nil, nil, nil, err
+			}
+		case "upsert":
+			opts = opts.SetUpsert(val.Boolean())
+		case "collation":
+			collation, err := createCollation(val.Document())
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			opts = opts.SetCollation(collation)
+		case "paddingSize":
+			paddingSize = int(val.AsInt64())
+		default:
+			return nil, nil, nil, harnessError(fmt.Errorf("unrecognized %s option: %v", name, key))
+		}
+	}
+	if paddingSize > 0 {
+		var err error
+		if update, err = padUpdate(update, paddingSize); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if opts.Upsert == nil {
+		opts = opts.SetUpsert(false)
+	}
+	return filter, update, opts, nil
+}
+
+func executeUpdateOne(ctx context.Context, coll *mongo.Collection, args bson.Raw) (*mongo.UpdateResult, error) {
+	filter, update, opts, err := parseUpdateArguments("updateOne", args)
+	if err != nil {
+		return nil, err
+	}
+	if label := operationLabel(ctx); label != "" {
+		opts = opts.SetComment(label)
+	}
+
+	return coll.UpdateOne(ctx, filter, update, opts)
+}
+
+// parseDeleteArguments returns the filter and options of the delete operation name.
+func parseDeleteArguments(name string, args bson.Raw) (bson.Raw, *options.DeleteOptions, error) {
+	filter := emptyDoc
+	opts := options.Delete()
+
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "filter":
+			filter = val.Document()
+		case "collation":
+			collation, err := createCollation(val.Document())
+			if err != nil {
+				return nil, nil, err
+			}
+			opts = opts.SetCollation(collation)
+		default:
+			return nil, nil, harnessError(fmt.Errorf("unrecognized %s option: %v", name, key))
+		}
+	}
+	return filter, opts, nil
+}
+
+func executeDeleteOne(ctx context.Context, coll *mongo.Collection, args bson.Raw) (*mongo.DeleteResult, error) {
+	filter, opts, err := parseDeleteArguments("deleteOne", args)
+	if err != nil {
+		return nil, err
+	}
+	if label := operationLabel(ctx); label != "" {
+		opts = opts.SetComment(label)
+	}
+
+	return coll.DeleteOne(ctx, filter, opts)
+}
+
+// create collation options from a collation document
+func createCollation(doc bson.Raw) (*options.Collation, error) {
+	var collation options.Collation
+
+	elems, _ := doc.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "locale":
+			collation.Locale = val.StringValue()
+		case "caseLevel":
+			collation.CaseLevel = val.Boolean()
+		case "caseFirst":
+			collation.CaseFirst = val.StringValue()
+		case "strength":
+			collation.Strength = int(val.AsInt64())
+		case "numericOrdering":
+			collation.NumericOrdering = val.Boolean()
+		case "alternate":
+			collation.Alternate = val.StringValue()
+		case "maxVariable":
+			collation.MaxVariable = val.StringValue()
+		case "normalization":
+			collation.Normalization = val.Boolean()
+		case "backwards":
+			collation.Backwards = val.Boolean()
+		default:
+			return nil, harnessError(fmt.Errorf("unrecognized collation option: %v", key))
+		}
+	}
+	return &collation, nil
+}
+
+// namespaceExistsCode is the server error code returned when creating a collection that exists.
+const namespaceExistsCode = 48
+
+func executeCreateCollection(ctx context.Context, coll *mongo.Collection, args bson.Raw) error {
+	opts := options.CreateCollection()
+
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "capped":
+			opts = opts.SetCapped(val.Boolean())
+		case "size":
+			opts = opts.SetSizeInBytes(val.AsInt64())
+		case "max":
+			opts = opts.SetMaxDocuments(val.AsInt64())
+		case "timeseries":
+			timeSeries, err := createTimeSeriesOptions(val.Document())
+			if err != nil {
+				return err
+			}
+			opts = opts.SetTimeSeriesOptions(timeSeries)
+		case "expireAfterSeconds":
+			opts = opts.SetExpireAfterSeconds(val.AsInt64())
+		default:
+			return harnessError(fmt.Errorf("unrecognized createCollection option: %v", key))
+		}
+	}
+
+	return createCollection(ctx, coll, opts)
+}
+
+func createTimeSeriesOptions(doc bson.Raw) (*options.TimeSeriesOptions, error) {
+	opts := options.TimeSeries()
+
+	elems, _ := doc.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "timeField":
+			opts = opts.SetTimeField(val.StringValue())
+		case "metaField":
+			opts = opts.SetMetaField(val.StringValue())
+		case "granularity":
+			opts = opts.SetGranularity(val.StringValue())
+		default:
+			return nil, harnessError(fmt.Errorf("unrecognized timeseries option: %v", key))
+		}
+	}
+	return opts, nil
+}
+
+// createCollection creates coll with opts. It succeeds if the collection already exists, so that
+// the operation can be repeated by every iteration of the workload.
+func createCollection(ctx context.Context, coll *mongo.Collection, opts *options.CreateCollectionOptions) error {
+	err := coll.Database().CreateCollection(ctx, coll.Name(), opts)
+	if coded, ok := err.(interface{ HasErrorCode(int) bool }); ok && coded.HasErrorCode(namespaceExistsCode) {
+		return nil
+	}
+	return err
+}
+
+func executeAggregate(ctx context.Context, coll *mongo.Collection, args bson.Raw) (*mongo.Cursor, error) {
+	var pipeline []bson.Raw
+	opts := options.Aggregate()
+
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "pipeline":
+			stages, _ := val.Array().Values()
+			for _, stage := range stages {
+				pipeline = append(pipeline, stage.Document())
+			}
+		case "batchSize":
+			opts = opts.SetBatchSize(int32(val.AsInt64()))
+		case "allowDiskUse":
+			opts = opts.SetAllowDiskUse(val.Boolean())
+		case "collation":
+			collation, err := createCollation(val.Document())
+			if err != nil {
+				return nil, err
+			}
+			opts = opts.SetCollation(collation)
+		case "readPreference":
+			var err error
+			if ctx, coll, err = withReadPreference(ctx, coll, val); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, harnessError(fmt.Errorf("unrecognized aggregate option: %v", key))
+		}
+	}
+	if label := operationLabel(ctx); label != "" {
+		opts = opts.SetComment(label)
+	}
+
+	return coll.Aggregate(ctx, pipeline, opts)
+}
+
+func verifyInsertOneResult(actualResult *mongo.InsertOneResult, expectedResult interface{}) bool {
+	if expectedResult == nil {
+		return true
+	}
+
+	var expected mongo.InsertOneResult
+	if bson.Unmarshal(expectedResult.(bson.Raw), &expected) != nil {
+		return false
+	}
+
+	expectedID := expected.InsertedID
+	if f, ok := expectedID.(float64); ok && f == math.Floor(f) {
+		expectedID = int32(f)
+	}
+
+	return expectedID == nil || (actualResult != nil && expectedID == actualResult.InsertedID)
+}
+
+func verifyInsertManyResult(res *mongo.InsertManyResult, result interface{}, v verifier) bool {
+	if result == nil {
+		return true
+	}
+	if res == nil {
+		return false
+	}
+
+	var expected struct {
+		InsertedCount int64 `bson:"insertedCount"`
+	}
+	err := bson.Unmarshal(result.(bson.Raw), &expected)
+	if err != nil {
+		return false
+	}
+	return v.countsMatch(expected.InsertedCount, int64(len(res.InsertedIDs)))
+}
+
+// verifyCursorResult reports whether the documents of cur match result, if it is set, and closes
+// cur. The returned error is that of iterating or closing the cursor, e.g. a network error during a
+// failover, which counts as an error of the operation rather than as a failure.
+func verifyCursorResult(cur *mongo.Cursor, result interface{}, v verifier) (pass bool, err error) {
+	if cur == nil {
+		return result == nil, nil
+	}
+
+	defer func() {
+		if closeErr := cur.Close(context.Background()); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	if result == nil {
+		return true, nil
+	}
+	for _, expected := range result.(bson.A) {
+		if !cur.Next(context.Background()) {
+			return false, cur.Err()
+		}
+		if !v.documentsMatch(expected.(bson.Raw), cur.Current) {
+			return false, nil
+		}
+	}
+
+	if cur.Next(context.Background()) {
+		return false, nil
+	}
+	return true, cur.Err()
+}
+
+func verifyUpdateResult(res *mongo.UpdateResult, result interface{}, v verifier) bool {
+	if result == nil {
+		return true
+	}
+	if res == nil {
+		return false
+	}
+
+	var expected struct {
+		MatchedCount  int64 `bson:"matchedCount"`
+		ModifiedCount int64 `bson:"modifiedCount"`
+		UpsertedCount int64 `bson:"upsertedCount"`
+	}
+	err := bson.Unmarshal(result.(bson.Raw), &expected)
+	if err != nil {
+		return false
+	}
+
+	if !v.countsMatch(expected.MatchedCount, res.MatchedCount) {
+		return false
+	}
+	if !v.countsMatch(expected.ModifiedCount, res.ModifiedCount) {
+		return false
+	}
+
+	actualUpsertedCount := int64(0)
+	if res.UpsertedID != nil {
+		actualUpsertedCount = 1
+	}
+	return v.countsMatch(expected.UpsertedCount, actualUpsertedCount)
+}
+
+func verifyDeleteResult(res *mongo.DeleteResult, result interface{}, v verifier) bool {
+	if result == nil {
+		return true
+	}
+	if res == nil {
+		return false
+	}
+
+	var expected struct {
+		DeletedCount int64 `bson:"deletedCount"`
+	}
+	err := bson.Unmarshal(result.(bson.Raw), &expected)
+	if err != nil {
+		return false
+	}
+	return v.countsMatch(expected.DeletedCount, res.DeletedCount)
+}
+
+func init() {
+	registerCollectionOperation("insertOne", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		res, err := executeInsertOne(ctx, coll, op.Arguments, r.text)
+		return verifyInsertOneResult(res, op.Result), err
+	})
+	registerCollectionOperation("insertMany", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		res, err := executeInsertMany(ctx, coll, op.Arguments, r.text)
+		return verifyInsertManyResult(res, op.Result, r.verifier), err
+	})
+	registerCollectionOperation("find", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		cursor, err := executeFind(ctx, coll, op.Arguments)
+		if err != nil {
+			return false, err
+		}
+		return verifyCursorResult(cursor, op.Result, r.verifier)
+	})
+	registerCollectionOperation("deleteOne", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		res, err := executeDeleteOne(ctx, coll, op.Arguments)
+		return verifyDeleteResult(res, op.Result, r.verifier), err
+	})
+	registerCollectionOperation("createCollection", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		return true, executeCreateCollection(ctx, coll, op.Arguments)
+	})
+	registerCollectionOperation("aggregate", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		r.recordOutputCollection(coll, op.Arguments)
+		cursor, err := executeAggregate(ctx, coll, op.Arguments)
+		if err != nil {
+			return false, err
+		}
+		return verifyCursorResult(cursor, op.Result, r.verifier)
+	})
+	registerCollectionOperation("updateOne", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		res, err := executeUpdateOne(ctx, coll, op.Arguments)
+		return verifyUpdateResult(res, op.Result, r.verifier), err
+	})
+
+	registerObjectType("collection", func(r *workloadRunner, op *operation) (bool, error) {
+		return r.executeCollectionOperation(r.transactionContext(r.coll), r.coll, op)
+	})
+	registerObjectType("testRunner", func(r *workloadRunner, op *operation) (bool, error) {
+		return r.executeTestRunnerOperation(op)
+	})
+}
+
+func (r *workloadRunner) executeCollectionOperation(ctx context.Context, coll *mongo.Collection, op *operation) (bool, error) {
+	fn, ok := collectionOperations[op.Name]
+	if !ok {
+		return false, harnessError(errors.New("unrecognized collection operation: " + op.Name))
+	}
+	ctx = withOperationLabel(ctx, op.Label)
+	if r.mirror != nil && mirrored(ctx, op) {
+		return r.mirror.run(ctx, r, coll, op, fn)
+	}
+	return fn(ctx, r, coll, op)
+}
+
+// workloadRunner holds the state shared by the operations of a workload.
+type workloadRunner struct {
+	uri        string
+	clientOpts *options.ClientOptions
+	client     *mongo.Client
+	coll       *mongo.Collection
+	// named client entities, keyed by ID
+	clients map[string]*mongo.Client
+	// named collection entities, keyed by ID
+	collections map[string]*mongo.Collection
+	// named client encryption entities, keyed by ID
+	clientEncryptions map[string]*clientEncryption
+	// IDs of the client entities that collection entities were created from, keyed by
+	// collection entity ID
+	collectionClients map[string]string
+	// compares actual results against the expected results of operations
+	verifier verifier
+
+	// fail points enabled by the workload, in the order they were first enabled
+	failPoints []enabledFailPoint
+	// direct connections to individual servers, keyed by address
+	hostClients map[string]*mongo.Client
+
+	// address of the server that executed the most recent command
+	lastAddress   string
+	lastAddressMu sync.Mutex
+
+	mongos  *mongosTracker
+	pinning *pinningTracker
+	// servers the reads of operations with a tagged read preference were sent to
+	routing *routingTracker
+	latency *latencyTracker
+	// end-to-end latencies and throughput for metrics.json
+	metrics *metricsRecorder
+	// limits the rate of the operations of the loop, shared by its workers
+	pacer *pacer
+	// server state transitions for topology-timeline.json
+	topology *topologyTracker
+	// SDAM and pool events for sdam-events.json and the time to recover writes
+	sdam *sdamTracker
+
+	// closed when the operation loop is stopped
+	done <-chan struct{}
+	// current wait after errored iterations, see iterationBackoff
+	iterationDelay time.Duration
+	// picks the operations of each iteration, see weightedSelection; seeded with Results.Seed
+	rng *rand.Rand
+	// generates the text of insertOne and insertMany and the terms of searches; seeded with
+	// Results.Seed
+	text *textGenerator
+	// connection strings of the endpoint failover, if the workload configures one
+	endpoints *endpointSwitcher
+	// the phase of the test the orchestrator is in, if it was given a phase file
+	phases *phaseTracker
+	// see Options.CheckpointFile
+	checkpointPath string
+	lastCheckpoint time.Time
+	// see Options.HeartbeatFile
+	heartbeatPath string
+	lastHeartbeat time.Time
+	// samples of the heap of the executor, if it checks for leaks
+	heap *heapTracker
+	// time since which every operation has errored, see errorBudget
+	downSince time.Time
+	// errors recorded by all the workers of the run, which the error budget applies to
+	runErrors *int64
+	// workloads reloaded by the first worker, which another worker switches to at its next
+	// iteration boundary
+	reloaded chan *driverWorkload
+	// transaction of a startTransaction operation that has not been committed or aborted yet
+	txn *explicitTransaction
+	// last sequence number written by a checkCausalConsistency operation
+	causalSeq int64
+	// tailers started by tailCollection operations, keyed by namespace
+	tailers map[string]*tailer
+	// collections written by aggregate operations, keyed by namespace
+	outputCollections map[string]*mongo.Collection
+	// indexes checked by verifyIndexes after the loop, keyed by namespace and name
+	declaredIndexes map[string]*declaredIndex
+
+	results Results
+	opStats map[string]*OperationStats
+	sinks   []Sink
+
+	// test case selected by Options.TestName
+	testName string
+
+	limits   eventLimits
+	eventsMu sync.Mutex
+	// number of records kept so far, including those already flushed
+	numEvents   int
+	numErrors   int
+	numFailures int
+
+	// counters published by the loop at every iteration boundary, see ProgressSink
+	snapshot progressSnapshot
+	// held while flushing, which the loop and the progress reporter both do; guards lastFlush and
+	// interval
+	flushMu       sync.Mutex
+	flushInterval time.Duration
+	lastFlush     time.Time
+	// counts at the start of the interval reported to IntervalSinks at the next flush
+	interval intervalCounts
+
+	// cluster the requirements are evaluated against, fetched on first use
+	server     *ServerInfo
+	serverless bool
+
+	// KMS providers for encrypted workloads, see Options.KMS
+	kms *KMSConfig
+	// see Options.OnlineArchiveURI
+	onlineArchiveURI string
+	// the control cluster writes are mirrored to, see Options.ControlURI
+	mirror *mirror
+	// see Options.CaptureCommands
+	captureCommands bool
+	// the other workers of the operation loop, if it runs with a concurrency above 1
+	workers []*workloadRunner
+}
+
+// runOperation executes op with the function registered for its object or, on a collection, its
+// name. Legacy workloads are not run by the unified test runner of the driver: it is not
+// importable from the 2.x drivers, and it has no equivalent of the pacing, the error budget, the
+// concurrent workers, the reloads or the fault injection of the operation loop. Both entrypoints
+// write the same results.json and events.json, see RunUnified.
+func (r *workloadRunner) runOperation(op *operation) (bool, error) {
+	// execute the command on the given object
+	if fn, ok := objectTypes[op.Object]; ok {
+		return fn(r, op)
+	}
+	if coll, ok := r.collections[op.Object]; ok {
+		return r.executeCollectionOperation(r.transactionContext(coll), coll, op)
+	}
+	if entity, ok := r.clientEncryptions[op.Object]; ok {
+		return r.executeClientEncryptionOperation(entity, op)
+	}
+	return false, harnessError(errors.New("unrecognized object: " + op.Object))
+}
+
+// runLoop executes the operations of the workload in order, repeatedly, until done is closed. A
+// workload received from reloads, or passed on by the first worker, replaces the current one at
+// the next iteration boundary. The
+// sinks are notified that the workload is ready after the first iteration in which every operation
+// succeeded.
+func (r *workloadRunner) runLoop(done <-chan struct{}, reloads <-chan []byte, workload *driverWorkload) {
+	r.done = done
+	ready := false
+	for iteration := 0; ; iteration++ {
+		select {
+		case <-done:
+			return
+		case spec := <-reloads:
+			r.reloadWorkload(workload, spec, iteration)
+		case next := <-r.reloaded:
+			r.replaceWorkload(workload, next)
+		default:
+		}
+		r.publishProgress(iteration)
+		r.maybeFlush()
+		r.maybeSwitchEndpoint()
+		r.updatePhase()
+		r.maybeCheckpoint()
+		r.maybeHeartbeat(iteration)
+		r.maybeSampleHeap()
+		succeeded := true
+		errored := false
+		for _, operation := range r.selectOperations(workload) {
+			select {
+			case <-done:
+				return
+			default:
+				if !r.paceOperation() {
+					return
+				}
+				r.latency.operationStarted()
+				start := time.Now()
+				pass, err := r.runOperationWithRetries(operation)
+				r.metrics.record(operation.latencyKey(), start, time.Since(start), pass && err == nil)
+				selection, command := r.latency.operationFinished(operation.latencyKey())
+				r.recordPhaseResult(operation, pass, err, selection, command)
+				if !r.recordResult(operation, pass, err) {
+					succeeded = false
+				}
+				if err != nil && errorCategory(err) == ErrorCategoryHarness {
+					// the operation is invalid, e.g. it has an unrecognized option
+					r.results.HarnessError = err.Error()
+					return
+				}
+				if _, isFailure := err.(*failure); err != nil && !isFailure {
+					errored = true
+				}
+				r.recordEndpointResult(err)
+				if !r.checkErrorBudget(workload.ErrorBudget, err) {
+					return
+				}
+				if r.recordPinningViolations() {
+					succeeded = false
+				}
+				if r.recordRoutingViolations() {
+					succeeded = false
+				}
+			}
+		}
+		r.results.NumIterations++
+		if succeeded && !ready {
+			ready = true
+			r.notifyReady()
+		}
+		if !r.backoffAfterIteration(workload.IterationBackoff, errored) {
+			return
+		}
+	}
+}
+
+// recordResult counts the result of op in the results, the stats of the operation and the stats of
+// the client it is attributed to, and reports whether op succeeded.
+// failure is returned by operations whose result did not match in place of a bare false, to report
+// the details of the mismatch instead of a generic message.
+type failure struct {
+	msg string
+}
+
+func (f *failure) Error() string {
+	return f.msg
+}
+
+func (r *workloadRunner) recordResult(op *operation, pass bool, err error) bool {
+	stats := r.opStats[op.Name]
+	clientStats := r.clientStats(op)
+	if clientStats == nil {
+		clientStats = &ClientStats{}
+	}
+
+	f, isFailure := err.(*failure)
+	switch {
+	case isFailure:
+		r.recordLabeledFailure(f.msg, op.Label)
+		stats.NumFailures++
+		clientStats.NumFailures++
+		return false
+	case err != nil:
+		r.recordLabeledError(err, op.Label)
+		stats.NumErrors++
+		clientStats.NumErrors++
+	case pass:
+		r.results.NumSuccesses++
+		stats.NumSuccesses++
+		clientStats.NumSuccesses++
+	default:
+		r.recordLabeledFailure(fmt.Sprintf("%s on %s returned an unexpected result", op.Name, op.Object), op.Label)
+		stats.NumFailures++
+		clientStats.NumFailures++
+	}
+	return err == nil && pass
+}
+
+// trackOperations adds an OperationStats entry to the results for each new operation name.
+func (r *workloadRunner) trackOperations(operations []*operation) {
+	for _, operation := range operations {
+		if _, ok := r.opStats[operation.Name]; !ok {
+			stats := &OperationStats{Name: operation.Name}
+			r.opStats[operation.Name] = stats
+			r.results.Operations = append(r.results.Operations, stats)
+		}
+	}
+}
+
+// Run connects to the cluster at uri and runs the workload described by spec, an extended JSON
+// document in the workload format, until ctx is done. The results are passed to each of the sinks
+// before Run returns, including when the workload is invalid, the cluster cannot be set up or the
+// executor panics, so that an aborted run is still reported. Errors that keep the workload from
+// running to completion are RunErrors, see ErrorCategory; they are recorded in Results.Errors and
+// returned together with the results.
+func Run(ctx context.Context, uri string, spec []byte, sinks ...Sink) (*Results, error) {
+	return RunWithOptions(ctx, uri, spec, Options{}, sinks...)
+}
+
+// Options configure a workload run.
+type Options struct {
+	// Reloads delivers replacement workloads while the workload is running. See reloadWorkload
+	// for which parts of the workload can be replaced.
+	Reloads <-chan []byte
+	// TestName selects a single test case of a workload with several tests. If empty, every test
+	// case is run.
+	TestName string
+	// MaxEvents, MaxErrors and MaxFailures cap the number of records kept in memory and written
+	// by EventsFileSink, so that a pathological run cannot exhaust memory. Records beyond the
+	// limit are counted in Results instead. Zero selects the default limit and a negative value
+	// disables the limit.
+	MaxEvents   int
+	MaxErrors   int
+	MaxFailures int
+	// FlushInterval is how often recorded events, errors and failures are handed to sinks that
+	// implement FlushSink, and the progress of the workload to sinks that implement ProgressSink,
+	// while the workload is running. Records are flushed at iteration boundaries, so an interval
+	// shorter than an iteration flushes once per iteration, and by a background reporter while an
+	// operation holds up the loop for longer than the interval. Zero disables flushing and keeps
+	// every record in memory until the workload finishes.
+	FlushInterval time.Duration
+	// Serverless tells the executor that the cluster is a serverless instance, which cannot be
+	// detected from the server, for evaluating runOnRequirements.
+	Serverless bool
+	// HostMap redirects connections to the servers it lists, keyed by "host:port" or by "host"
+	// alone to keep the port, to other addresses. See ParseHostMap.
+	HostMap map[string]string
+	// ToxiproxyURL is the address of the API of a toxiproxy server, e.g. "http://localhost:8474".
+	// If set, every connection is routed through a toxiproxy proxy and the faults schedule of the
+	// workload is applied to the proxies.
+	ToxiproxyURL string
+	// FailoverURIs are the connection strings, in addition to the one the executor was started
+	// with, that the endpoint failover of a workload switches between.
+	FailoverURIs []string
+	// Seed seeds all randomized behavior of the executor, so that a run can be replayed. Zero uses
+	// the seed of the weighted selection of the workload, if it has one, or picks one from the
+	// clock. The seed used is reported in Results.Seed.
+	Seed int64
+	// MetricsBucketSize is the width of the time buckets the throughput of the operation loop is
+	// counted in, see Metrics. Zero selects 10 seconds.
+	MetricsBucketSize time.Duration
+	// ClockSkewInterval is how often the executor compares its clock with that of the primary
+	// while the workload runs, see ClockSkewStats. Zero disables the comparison.
+	ClockSkewInterval time.Duration
+	// SyntheticLoad runs reads and writes against a separate collection alongside the workload to
+	// put the cluster under load, see SyntheticLoad. Nil disables the load.
+	SyntheticLoad *SyntheticLoad
+	// PhaseFile is the path of a file in which the orchestrator names the current phase of the
+	// test as a JSON object with a "name" field, e.g. {"name": "duringMaintenance"}. The executor
+	// splits its outcomes, latencies and downtime by phase in Results.Phases.
+	PhaseFile string
+	// CheckpointFile is the path of a file to which the executor saves its cumulative counters
+	// while the workload runs. If the file exists when the executor starts, the executor resumes
+	// the counters of the previous executor process, which is assumed to have died, and records
+	// the restart in Results.Restarts. A resumed run does not insert the initial data or run the
+	// beforeLoop hooks again. The orchestrator removes the file before starting a new workload.
+	CheckpointFile string
+	// HeartbeatFile is the path of a file the executor rewrites about once a second at iteration
+	// boundaries while the workload runs, for a watchdog to detect an executor that is stuck in an
+	// operation.
+	HeartbeatFile string
+	// MaxHeapGrowthMBPerHour enables leak detection: the executor samples its live heap at
+	// iteration boundaries and fails the run if the heap grew faster than this, in MB per hour,
+	// after a warm-up. Zero disables leak detection. See HeapStats.
+	MaxHeapGrowthMBPerHour float64
+	// HeapSampleInterval is the interval between heap samples; zero selects 10 seconds.
+	HeapSampleInterval time.Duration
+	// HeapProfileFile is the path the heap profile is written to if a leak is detected.
+	HeapProfileFile string
+	// DisableOCSPEndpointCheck stops every client of the executor from contacting OCSP responders
+	// when the server does not staple an OCSP response, so that a workload can be run while the
+	// responders are unreachable.
+	DisableOCSPEndpointCheck bool
+	// OnlineArchiveURI is the connection string of the federated database instance that queries
+	// both the cluster and its online archive, for client entities with useOnlineArchive.
+	OnlineArchiveURI string
+	// ControlURI is the connection string of a control cluster that undergoes no maintenance. If
+	// set, every insertOne, insertMany, updateOne and deleteOne of the workload outside of a
+	// transaction is run against the same namespace of the control cluster after it is run against
+	// the cluster under test, and the final contents of the written namespaces are compared, see
+	// DualClusterComparison. The initial data is inserted into both clusters.
+	ControlURI string
+	// CaptureCommands records the database and the command document of every command started
+	// event in events.json, so that the commands can be replayed against another cluster, see
+	// Replay. The commands include the documents of the workload, so events.json grows accordingly.
+	CaptureCommands bool
+	// KMS holds the KMS providers available to encrypted workloads, see KMSConfigFromEnv. Nil
+	// means no provider is configured.
+	KMS *KMSConfig
+	// Concurrency is the number of workers that run the operation loop at once, each with a client
+	// of its own, see runWorkers. It overrides the concurrency of the workload; zero uses that of
+	// the workload. Reloaded workloads replace the operations of every worker.
+	Concurrency int
+	// Pacing limits the rate of the operation loop. It overrides the pacing of the workload; nil
+	// uses that of the workload.
+	Pacing *Pacing
+}
+
+// finish records the panic p that aborted the run, if it is not nil, or else the error err that
+// ended it, passes the results to the sinks and returns them with the error of the run. Every
+// entrypoint ends its runs with finish, so that they are reported the same way.
+func (r *workloadRunner) finish(p interface{}, err error) (*Results, error) {
+	if p != nil {
+		err = r.recordPanic(p)
+	} else if err != nil && r.results.HarnessError == "" {
+		// a panic of a worker or an invalid operation was recorded when it ended the loop
+		r.recordError(err)
+	}
+	if sinkErr := r.writeResults(); sinkErr != nil && err == nil {
+		err = harnessError(sinkErr)
+	}
+	return &r.results, err
+}
+
+// RunWithOptions is like Run, but configured by opts.
+func RunWithOptions(ctx context.Context, uri string, spec []byte, opts Options, sinks ...Sink) (results *Results, err error) {
+	runner := &workloadRunner{
+		uri:         uri,
+		clientOpts:  options.Client().ApplyURI(uri),
+		tailers:     make(map[string]*tailer),
+		hostClients: make(map[string]*mongo.Client),
+		clients:     make(map[string]*mongo.Client),
+		collections: make(map[string]*mongo.Collection),
+
+		clientEncryptions: make(map[string]*clientEncryption),
+		collectionClients: make(map[string]string),
+		outputCollections: make(map[string]*mongo.Collection),
+		declaredIndexes:   make(map[string]*declaredIndex),
+		mongos:            newMongosTracker(),
+		pinning:           newPinningTracker(),
+		routing:           newRoutingTracker(),
+		latency:           newLatencyTracker(),
+		topology:          newTopologyTracker(),
+		sdam:              newSDAMTracker(),
+		opStats:           make(map[string]*OperationStats),
+		runErrors:         new(int64),
+		sinks:             sinks,
+		testName:          opts.TestName,
+		limits:            opts.limits(),
+
+		flushInterval: opts.FlushInterval,
+		lastFlush:     time.Now(),
+
+		checkpointPath: opts.CheckpointFile,
+		heartbeatPath:  opts.HeartbeatFile,
+		serverless:     opts.Serverless,
+		kms:            opts.KMS,
+
+		onlineArchiveURI: opts.OnlineArchiveURI,
+		captureCommands:  opts.CaptureCommands,
+	}
+	// the results are written however the run ends, e.g. when the workload is invalid or the cluster
+	// is unreachable, with the error that ended it recorded in its category
+	defer func() {
+		results, err = runner.finish(recover(), err)
+	}()
+
+	workload, err := parseWorkload(spec, opts.TestName)
+	if err != nil {
+		return nil, harnessError(err)
+	}
+	if err = validateWorkload(workload); err != nil {
+		return nil, harnessError(err)
+	}
+
+	runner.clientOpts.SetMonitor(runner.commandMonitor())
+	runner.clientOpts.SetServerMonitor(runner.serverMonitor())
+	runner.clientOpts.SetPoolMonitor(runner.poolMonitor())
+	if opts.DisableOCSPEndpointCheck {
+		runner.clientOpts.SetDisableOCSPEndpointCheck(true)
+	}
+	hostMap := &hostRewritingDialer{hosts: opts.HostMap}
+	var faults *toxiproxyDialer
+	switch {
+	case opts.ToxiproxyURL != "":
+		faults = newToxiproxyDialer(opts.ToxiproxyURL, hostMap.rewrite)
+		runner.clientOpts.SetDialer(faults)
+		defer faults.close()
+	case len(workload.Faults) > 0:
+		return nil, harnessError(errors.New("the workload has faults but no toxiproxy server was given"))
+	case len(opts.HostMap) > 0:
+		runner.clientOpts.SetDialer(hostMap)
+	}
+	var dnsFaults *dnsFaultDialer
+	if len(workload.DNSFaults) > 0 {
+		next := runner.clientOpts.Dialer
+		if next == nil {
+			next = &net.Dialer{}
+		}
+		dnsFaults = &dnsFaultDialer{next: next, faults: workload.DNSFaults}
+		runner.clientOpts.SetDialer(dnsFaults)
+	}
+
+	seed := opts.Seed
+	if seed == 0 && workload.WeightedSelection != nil {
+		seed = workload.WeightedSelection.Seed
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	runner.results.Seed = seed
+	runner.rng = rand.New(rand.NewSource(seed))
+	runner.text = newTextGenerator(seed)
+
+	if workload.EndpointFailover != nil {
+		if len(opts.FailoverURIs) == 0 {
+			return nil, harnessError(errors.New("the workload has an endpoint failover but no failover connection strings were given"))
+		}
+		// the workload client is replaced when the endpoint switches, which a client encryption
+		// entity would go on using
+		for _, entity := range workload.ClientEncryptions {
+			if entity.KeyVaultClient == "" {
+				return nil, harnessError(fmt.Errorf("client encryption entity %q must name a keyVaultClient to be combined with an endpoint failover", entity.ID))
+			}
+		}
+		runner.endpoints = &endpointSwitcher{
+			config: workload.EndpointFailover,
+			uris:   append([]string{uri}, opts.FailoverURIs...),
+		}
+	}
+
+	if opts.PhaseFile != "" {
+		runner.phases = &phaseTracker{path: opts.PhaseFile}
+	}
+
+	runner.verifier, err = newVerifier(workload.Verifier)
+	if err != nil {
+		return nil, harnessError(err)
+	}
+
+	client, err := mongo.Connect(ctx, runner.clientOpts)
+	if err != nil {
+		return nil, setupError(err)
+	}
+	// the workload client is replaced when the endpoint failover switches connection strings
+	defer func() { _ = runner.client.Disconnect(context.Background()) }()
+
+	runner.client = client
+	runner.coll = client.Database(workload.Database).Collection(workload.Collection)
+	runner.results.Clients = make(map[string]*ClientStats)
+	defer runner.disconnectClients()
+	if err = runner.createClientEntities(ctx, uri, workload.Clients); err != nil {
+		return nil, setupError(err)
+	}
+	if err = runner.createCollectionEntities(workload.Collections); err != nil {
+		return nil, setupError(err)
+	}
+	defer runner.closeClientEncryptions()
+	if err = runner.createClientEncryptionEntities(workload.ClientEncryptions); err != nil {
+		return nil, setupError(err)
+	}
+	defer runner.disableFailPoints()
+	defer runner.dropOutputCollections()
+
+	var comparer *writeConcernComparer
+	if workload.WriteConcernComparison != nil {
+		comparisonClient, err := mongo.Connect(ctx, runner.unmonitoredClientOptions())
+		if err != nil {
+			return nil, setupError(err)
+		}
+		defer func() { _ = comparisonClient.Disconnect(context.Background()) }()
+
+		coll := comparisonClient.Database(workload.Database).Collection(workload.Collection)
+		comparer, err = newWriteConcernComparer(workload.WriteConcernComparison, coll)
+		if err != nil {
+			return nil, harnessError(err)
+		}
+	}
+
+	if opts.ControlURI != "" {
+		controlOpts := options.Client().ApplyURI(opts.ControlURI)
+		if opts.DisableOCSPEndpointCheck {
+			controlOpts.SetDisableOCSPEndpointCheck(true)
+		}
+		controlClient, err := mongo.Connect(ctx, controlOpts)
+		if err != nil {
+			return nil, setupError(fmt.Errorf("connecting to the control cluster failed: %v", err))
+		}
+		defer func() { _ = controlClient.Disconnect(context.Background()) }()
+
+		runner.mirror = newMirror(controlClient)
+	}
+
+	var linearizability *linearizabilityChecker
+	if workload.LinearizabilityCheck != nil {
+		registerClient, err := mongo.Connect(ctx, runner.unmonitoredClientOptions())
+		if err != nil {
+			return nil, setupError(err)
+		}
+		defer func() { _ = registerClient.Disconnect(context.Background()) }()
+
+		coll := registerClient.Database(workload.Database).Collection(workload.Collection)
+		linearizability, err = newLinearizabilityChecker(workload.LinearizabilityCheck, coll, runner.results.Seed)
+		if err != nil {
+			return nil, harnessError(err)
+		}
+	}
+
+	var clockSkew *clockSkewSampler
+	if opts.ClockSkewInterval > 0 {
+		clockClient, err := mongo.Connect(ctx, runner.unmonitoredClientOptions())
+		if err != nil {
+			return nil, setupError(err)
+		}
+		defer func() { _ = clockClient.Disconnect(context.Background()) }()
+
+		clockSkew = &clockSkewSampler{client: clockClient, interval: opts.ClockSkewInterval}
+	}
+
+	var load *loadGenerator
+	if opts.SyntheticLoad != nil {
+		loadClient, err := mongo.Connect(ctx, runner.unmonitoredClientOptions())
+		if err != nil {
+			return nil, setupError(err)
+		}
+		defer func() { _ = loadClient.Disconnect(context.Background()) }()
+
+		coll := loadClient.Database(workload.Database).Collection(workload.Collection + loadCollectionSuffix)
+		defer func() { _ = coll.Drop(context.Background()) }()
+		load = newLoadGenerator(opts.SyntheticLoad, coll, runner.results.Seed)
+	}
+
+	reason, err := runner.applyRequirements(ctx, workload)
+	if err != nil {
+		return nil, setupError(fmt.Errorf("checking runOnRequirements failed: %v", err))
+	}
+	if reason != "" {
+		runner.notifyConnected()
+		return runner.skip(ctx, reason)
+	}
+
+	// a resumed run continues on the data and the state the hooks left behind before the crash
+	runner.trackOperations(workload.Operations)
+	resumed, err := runner.restoreCheckpoint()
+	if err != nil {
+		return nil, setupError(err)
+	}
+	if !resumed {
+		err = runner.insertInitialData(workload.InitialData)
+		if err != nil {
+			return nil, setupError(fmt.Errorf("inserting initial data failed: %v", err))
+		}
+	}
+
+	concurrency := workload.Concurrency
+	if opts.Concurrency > 0 {
+		concurrency = opts.Concurrency
+	}
+	if concurrency > 1 {
+		if err = checkConcurrency(workload, opts); err != nil {
+			return nil, harnessError(err)
+		}
+	}
+	for id := 1; id < concurrency; id++ {
+		worker, err := runner.newWorker(ctx, id, workload)
+		if err != nil {
+			return nil, setupError(fmt.Errorf("starting worker %d failed: %v", id, err))
+		}
+		defer worker.close()
+		runner.workers = append(runner.workers, worker)
+	}
+
+	if !resumed {
+		runner.runHooks("beforeLoop", workload.Hooks.BeforeLoop)
+	}
+	loopCtx, stopLoop := context.WithCancel(ctx)
+	defer stopLoop()
+	if faults != nil {
+		faults.runFaults(loopCtx, workload.Faults)
+	}
+	if dnsFaults != nil {
+		dnsFaults.begin()
+	}
+	var churn *churner
+	churnDone := make(chan struct{})
+	if workload.ClientChurn != nil && workload.ClientChurn.Rate > 0 {
+		churn = &churner{
+			config:  workload.ClientChurn,
+			newOpts: runner.unmonitoredClientOptions,
+		}
+		go func() {
+			churn.run(loopCtx)
+			close(churnDone)
+		}()
+	}
+	comparisonDone := make(chan struct{})
+	if comparer != nil {
+		go func() {
+			comparer.run(loopCtx, runner)
+			close(comparisonDone)
+		}()
+	}
+	linearizabilityDone := make(chan error, 1)
+	if linearizability != nil {
+		go func() {
+			linearizabilityDone <- linearizability.run(loopCtx)
+		}()
+	}
+	clockSkewDone := make(chan struct{})
+	if clockSkew != nil {
+		go func() {
+			clockSkew.run(loopCtx)
+			close(clockSkewDone)
+		}()
+	}
+	loadDone := make(chan struct{})
+	if load != nil {
+		go func() {
+			load.run(loopCtx)
+			close(loadDone)
+		}()
+	}
+	if runner.endpoints != nil {
+		runner.endpoints.start = time.Now()
+		runner.results.Endpoints = []EndpointPhase{{URI: redactURI(uri), Start: now(), Reason: "initial"}}
+	}
+	if opts.MaxHeapGrowthMBPerHour > 0 {
+		runner.heap = newHeapTracker(opts)
+	}
+	// the first interval starts with the counts restored from a checkpoint
+	runner.interval = intervalCounts{
+		start:        time.Now(),
+		numSuccesses: runner.results.NumSuccesses,
+		numErrors:    runner.results.NumErrors,
+		numFailures:  runner.results.NumFailures,
+	}
+	bucketSize := opts.MetricsBucketSize
+	if bucketSize <= 0 {
+		bucketSize = 10 * time.Second
+	}
+	runner.metrics = newMetricsRecorder(bucketSize, time.Now())
+	pacing := workload.Pacing
+	if opts.Pacing != nil {
+		pacing = opts.Pacing
+	}
+	runner.pacer = newPacer(pacing, time.Now())
+	runner.publishProgress(0)
+	progressDone := make(chan struct{})
+	if runner.flushInterval > 0 {
+		go func() {
+			runner.reportProgress(loopCtx)
+			close(progressDone)
+		}()
+	} else {
+		close(progressDone)
+	}
+	runner.notifyConnected()
+	// a worker that breaches the error budget stops the others
+	workersCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+	workersDone := runner.runWorkers(runner.workers, workersCtx.Done(), stopWorkers, workload)
+	runner.runLoop(workersCtx.Done(), opts.Reloads, workload)
+	stopWorkers()
+	<-workersDone
+	runner.results.Metrics = runner.metrics.summary(time.Now())
+	runner.results.Throughput = runner.pacer.summary(time.Now())
+	runner.endPhase(time.Now())
+	runner.checkHeapGrowth()
+	if runner.endpoints != nil {
+		runner.results.Endpoints[len(runner.results.Endpoints)-1].End = now()
+	}
+
+	// stop the client churn, the comparison, the linearizability check, the clock skew sampler, the
+	// synthetic load, the tailers and the faults before verifying the outcome
+	stopLoop()
+	<-progressDone
+	runner.stopTailers()
+	runner.endTransaction()
+	for _, worker := range runner.workers {
+		runner.mergeWorker(worker)
+	}
+	if churn != nil {
+		<-churnDone
+		runner.results.ClientChurn = churn.summary()
+	}
+	if comparer != nil {
+		<-comparisonDone
+		runner.results.WriteConcernComparison = comparer.summary()
+	}
+	if linearizability != nil {
+		if err := <-linearizabilityDone; err != nil {
+			runner.recordError(err)
+		}
+		stats := linearizability.summary()
+		if stats.Result == "violation" {
+			runner.recordFailure("register history is not linearizable: " + stats.Violation)
+		}
+		runner.results.Linearizability = stats
+	}
+	if clockSkew != nil {
+		<-clockSkewDone
+		runner.results.ClockSkew = clockSkew.summary()
+	}
+	if load != nil {
+		<-loadDone
+		runner.results.SyntheticLoad = load.summary()
+	}
+	if faults != nil {
+		faults.clearFaults()
+	}
+	runner.results.Mongos = runner.mongos.summary()
+	runner.results.Routing = runner.routing.summary()
+	runner.results.Latency = runner.latency.summary()
+	runner.results.Topology = runner.topology.summary()
+	runner.results.SDAMEvents, runner.results.Failover = runner.sdam.summary(time.Now())
+	if dnsFaults != nil {
+		dnsFaults.end()
+	}
+	runner.results.Indexes = runner.verifyIndexes()
+	if runner.mirror != nil {
+		runner.results.DualCluster = runner.mirror.compare(runner)
+	}
+	runner.results.OutcomeFailures = runner.verifyOutcome(workload.Outcome)
+	runner.runHooks("afterLoop", workload.Hooks.AfterLoop)
+	if runner.results.HarnessError != "" {
+		// an operation was invalid or a worker panicked
+		return &runner.results, harnessError(errors.New(runner.results.HarnessError))
+	}
+	return &runner.results, nil
+}
@@ -0,0 +1,246 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// enabledFailPoint identifies a fail point that must be disabled when the workload finishes.
+type enabledFailPoint struct {
+	name string
+	// address of the server the fail point was enabled on, or empty if it was enabled through
+	// the workload client
+	host string
+}
+
+func (r *workloadRunner) executeTestRunnerOperation(op *operation) (bool, error) {
+	switch op.Name {
+	case "failPoint":
+		return r.executeFailPoint(op.Arguments)
+	case "targetedFailPoint":
+		return r.executeTargetedFailPoint(op.Arguments)
+	case "assertCollectionExists":
+		return r.executeAssertCollectionExists(op.Arguments)
+	case "assertIndexExists":
+		return r.executeAssertIndexExists(op.Arguments)
+	case "assertDocumentCount":
+		return r.executeAssertDocumentCount(op.Arguments)
+	}
+	return false, harnessError(errors.New("unrecognized testRunner operation: " + op.Name))
+}
+
+// executeFailPoint configures a fail point on the server using the configureFailPoint command.
+// The fail point is disabled when the workload finishes.
+func (r *workloadRunner) executeFailPoint(args bson.Raw) (bool, error) {
+	var failPoint bson.Raw
+
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "failPoint":
+			failPoint = val.Document()
+		case "client":
+			// the workload uses a single client, so the client argument is accepted for
+			// compatibility with the unified test format and otherwise ignored
+		default:
+			return false, harnessError(fmt.Errorf("unrecognized failPoint option: %v", key))
+		}
+	}
+
+	return r.configureFailPoint(r.client, "", failPoint)
+}
+
+// executeTargetedFailPoint configures a fail point on a single server, which is useful to disrupt
+// one mongos in a sharded cluster. The server is given by the host argument; if it is omitted, the
+// server that executed the most recent command is used, which mirrors the behavior of a session
+// pinned to a mongos.
+func (r *workloadRunner) executeTargetedFailPoint(args bson.Raw) (bool, error) {
+	var failPoint bson.Raw
+	var host string
+
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "failPoint":
+			failPoint = val.Document()
+		case "host":
+			host = val.StringValue()
+		case "session":
+			// session entities are not supported by this executor, so the target is determined
+			// from the most recent command instead, which in a transaction is the pinned server
+		default:
+			return false, harnessError(fmt.Errorf("unrecognized targetedFailPoint option: %v", key))
+		}
+	}
+
+	if host == "" {
+		host = r.lastCommandAddress()
+	}
+	if host == "" {
+		return false, errors.New("targetedFailPoint requires a host argument when no command has been executed")
+	}
+
+	client, err := r.hostClient(host)
+	if err != nil {
+		return false, err
+	}
+	return r.configureFailPoint(client, host, failPoint)
+}
+
+func (r *workloadRunner) configureFailPoint(client *mongo.Client, host string, failPoint bson.Raw) (bool, error) {
+	if failPoint == nil {
+		return false, errors.New("fail point operations require a failPoint argument")
+	}
+	name, ok := failPoint.Lookup("configureFailPoint").StringValueOK()
+	if !ok {
+		return false, errors.New("failPoint document must contain a configureFailPoint string")
+	}
+
+	err := client.Database("admin").RunCommand(context.Background(), failPoint).Err()
+	if err != nil {
+		return false, err
+	}
+	r.recordFailPoint(enabledFailPoint{name: name, host: host})
+	return true, nil
+}
+
+func (r *workloadRunner) recordFailPoint(fp enabledFailPoint) {
+	for _, enabled := range r.failPoints {
+		if enabled == fp {
+			return
+		}
+	}
+	r.failPoints = append(r.failPoints, fp)
+}
+
+// hostClient returns a client directly connected to the server at host. The client uses the same
+// credentials, TLS configuration and dialer as the workload client.
+func (r *workloadRunner) hostClient(host string) (*mongo.Client, error) {
+	if client, ok := r.hostClients[host]; ok {
+		return client, nil
+	}
+
+	opts := options.Client().SetHosts([]string{host}).SetDirect(true)
+	if r.clientOpts.Auth != nil {
+		opts.SetAuth(*r.clientOpts.Auth)
+	}
+	if r.clientOpts.TLSConfig != nil {
+		opts.SetTLSConfig(r.clientOpts.TLSConfig)
+	}
+	if r.clientOpts.Dialer != nil {
+		opts.SetDialer(r.clientOpts.Dialer)
+	}
+	if r.clientOpts.DisableOCSPEndpointCheck != nil {
+		opts.SetDisableOCSPEndpointCheck(*r.clientOpts.DisableOCSPEndpointCheck)
+	}
+	client, err := mongo.Connect(context.Background(), opts)
+	if err != nil {
+		return nil, err
+	}
+	r.hostClients[host] = client
+	return client, nil
+}
+
+// disableFailPoints turns off every fail point enabled by the workload. Errors are reported to
+// stderr rather than returned since this runs while the executor is shutting down.
+func (r *workloadRunner) disableFailPoints() {
+	for _, fp := range r.failPoints {
+		client := r.client
+		if fp.host != "" {
+			client = r.hostClients[fp.host]
+		}
+		cmd := bson.D{{Key: "configureFailPoint", Value: fp.name}, {Key: "mode", Value: "off"}}
+		err := client.Database("admin").RunCommand(context.Background(), cmd).Err()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to disable fail point %q on %q: %v\n", fp.name, fp.host, err)
+		}
+	}
+	for _, client := range r.hostClients {
+		_ = client.Disconnect(context.Background())
+	}
+}
+
+// commandMonitor returns a monitor that records every command event and the address of the server
+// executing each command, and checks pinning in load-balanced mode.
+func (r *workloadRunner) commandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			address := addressFromConnectionID(evt.ConnectionID)
+			started := Event{
+				Name:        "CommandStartedEvent",
+				CommandName: evt.CommandName,
+				RequestID:   evt.RequestID,
+				Address:     address,
+			}
+			if r.captureCommands {
+				if command, err := bson.MarshalExtJSON(evt.Command, true, false); err == nil {
+					started.DatabaseName = evt.DatabaseName
+					started.Command = command
+				}
+			}
+			r.recordEvent(started)
+			r.pinning.commandStarted(evt)
+			r.routing.commandStarted(ctx, evt, address)
+			r.latency.commandStarted()
+			if evt.CommandName == "configureFailPoint" {
+				return
+			}
+			r.lastAddressMu.Lock()
+			r.lastAddress = address
+			r.lastAddressMu.Unlock()
+		},
+		Succeeded: func(_ context.Context, evt *event.CommandSucceededEvent) {
+			address := addressFromConnectionID(evt.ConnectionID)
+			r.recordEvent(Event{
+				Name:        "CommandSucceededEvent",
+				CommandName: evt.CommandName,
+				RequestID:   evt.RequestID,
+				Address:     address,
+			})
+			r.mongos.commandFinished(address, true)
+			r.pinning.commandSucceeded(evt)
+			r.latency.commandFinished(evt.DurationNanos)
+		},
+		Failed: func(_ context.Context, evt *event.CommandFailedEvent) {
+			address := addressFromConnectionID(evt.ConnectionID)
+			r.recordEvent(Event{
+				Name:        "CommandFailedEvent",
+				CommandName: evt.CommandName,
+				RequestID:   evt.RequestID,
+				Address:     address,
+			})
+			r.mongos.commandFinished(address, false)
+			r.pinning.commandFailed(evt)
+			r.latency.commandFinished(evt.DurationNanos)
+		},
+	}
+}
+
+func (r *workloadRunner) lastCommandAddress() string {
+	r.lastAddressMu.Lock()
+	defer r.lastAddressMu.Unlock()
+	return r.lastAddress
+}
+
+// addressFromConnectionID extracts the server address from a connection ID of the form
+// "host:port[-N]".
+func addressFromConnectionID(connID string) string {
+	if idx := strings.LastIndex(connID, "["); idx != -1 {
+		return connID[:idx]
+	}
+	return connID
+}
@@ -0,0 +1,148 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"time"
+)
+
+const (
+	// defaultHeapSampleInterval is the interval between heap samples if Options.HeapSampleInterval
+	// is zero.
+	defaultHeapSampleInterval = 10 * time.Second
+	// heapWarmup is the time after the start of the loop during which samples are not used, since
+	// the heap grows while connection pools fill up and caches warm.
+	heapWarmup = time.Minute
+	// minHeapSamples is the number of samples after the warm-up needed to compute a growth rate.
+	minHeapSamples = 10
+)
+
+// HeapStats reports the size of the live heap of the executor over the run, sampled at iteration
+// boundaries, and its growth rate, which is the slope of a least squares fit of the samples taken
+// after the warm-up.
+type HeapStats struct {
+	NumSamples int     `json:"numSamples"`
+	StartMB    float64 `json:"startMB"`
+	EndMB      float64 `json:"endMB"`
+	PeakMB     float64 `json:"peakMB"`
+	// zero if there were too few samples after the warm-up
+	GrowthMBPerHour float64 `json:"growthMBPerHour"`
+	// set if the growth rate exceeded Options.MaxHeapGrowthMBPerHour
+	Leak bool `json:"leak,omitempty"`
+	// path of the heap profile written when a leak was detected
+	Profile string `json:"profile,omitempty"`
+}
+
+// heapSample is the live heap in MB at a time in hours since the loop started.
+type heapSample struct {
+	hours float64
+	mb    float64
+}
+
+// heapTracker samples the live heap of the executor, see Options.MaxHeapGrowthMBPerHour.
+type heapTracker struct {
+	maxGrowth   float64
+	interval    time.Duration
+	profilePath string
+
+	start      time.Time
+	lastSample time.Time
+	stats      HeapStats
+	samples    []heapSample
+}
+
+func newHeapTracker(opts Options) *heapTracker {
+	interval := opts.HeapSampleInterval
+	if interval <= 0 {
+		interval = defaultHeapSampleInterval
+	}
+	return &heapTracker{
+		maxGrowth:   opts.MaxHeapGrowthMBPerHour,
+		interval:    interval,
+		profilePath: opts.HeapProfileFile,
+		start:       time.Now(),
+	}
+}
+
+// maybeSampleHeap samples the live heap if the last sample is older than the sample interval. The
+// garbage collector is run first, so that the sample does not depend on when it last ran.
+func (r *workloadRunner) maybeSampleHeap() {
+	t := r.heap
+	if t == nil || time.Since(t.lastSample) < t.interval {
+		return
+	}
+	t.lastSample = time.Now()
+
+	runtime.GC()
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	mb := float64(mem.HeapAlloc) / (1 << 20)
+
+	if t.stats.NumSamples == 0 {
+		t.stats.StartMB = mb
+	}
+	t.stats.NumSamples++
+	t.stats.EndMB = mb
+	if mb > t.stats.PeakMB {
+		t.stats.PeakMB = mb
+	}
+	if elapsed := time.Since(t.start); elapsed >= heapWarmup {
+		t.samples = append(t.samples, heapSample{hours: elapsed.Hours(), mb: mb})
+	}
+}
+
+// checkHeapGrowth computes the growth rate of the heap and, if it exceeds the limit, records a
+// failure and writes a heap profile for finding what was retained.
+func (r *workloadRunner) checkHeapGrowth() {
+	t := r.heap
+	if t == nil {
+		return
+	}
+	r.results.Heap = &t.stats
+	if len(t.samples) < minHeapSamples {
+		return
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	for _, s := range t.samples {
+		sumX += s.hours
+		sumY += s.mb
+		sumXY += s.hours * s.mb
+		sumXX += s.hours * s.hours
+	}
+	n := float64(len(t.samples))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return
+	}
+	t.stats.GrowthMBPerHour = (n*sumXY - sumX*sumY) / denominator
+	if t.stats.GrowthMBPerHour <= t.maxGrowth {
+		return
+	}
+
+	t.stats.Leak = true
+	r.recordFailure(fmt.Sprintf("the heap of the executor grew by %.1f MB per hour, the limit is %v",
+		t.stats.GrowthMBPerHour, t.maxGrowth))
+	if t.profilePath == "" {
+		return
+	}
+	if err := writeHeapProfile(t.profilePath); err != nil {
+		fmt.Fprintf(os.Stderr, "writing heap profile failed: %v\n", err)
+		return
+	}
+	t.stats.Profile = t.profilePath
+}
+
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = pprof.WriteHeapProfile(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
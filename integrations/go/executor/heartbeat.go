@@ -0,0 +1,43 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// heartbeatInterval is how often the heartbeat file is rewritten, see Options.HeartbeatFile.
+const heartbeatInterval = time.Second
+
+// heartbeat is the contents of the heartbeat file.
+type heartbeat struct {
+	// seconds since the Unix epoch
+	Time float64 `json:"time"`
+	RecordTime
+	Iteration int `json:"iteration"`
+}
+
+// maybeHeartbeat rewrites the heartbeat file if the last heartbeat is older than
+// heartbeatInterval. It is called at iteration boundaries, so a heartbeat that stops advancing
+// means an operation has not returned. Errors are reported to stderr since a missed heartbeat is
+// what the watchdog is looking for anyway.
+func (r *workloadRunner) maybeHeartbeat(iteration int) {
+	if r.heartbeatPath == "" || time.Since(r.lastHeartbeat) < heartbeatInterval {
+		return
+	}
+	r.lastHeartbeat = time.Now()
+
+	t, rt := stamp()
+	data, err := json.Marshal(heartbeat{Time: t, RecordTime: rt, Iteration: iteration})
+	if err == nil {
+		tmp := r.heartbeatPath + ".tmp"
+		if err = ioutil.WriteFile(tmp, data, 0644); err == nil {
+			err = os.Rename(tmp, r.heartbeatPath)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "writing heartbeat failed: %v\n", err)
+	}
+}
@@ -0,0 +1,50 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// workloadHooks are run once before the operation loop starts and once after it ends.
+type workloadHooks struct {
+	BeforeLoop []*hook `bson:"beforeLoop"`
+	AfterLoop  []*hook `bson:"afterLoop"`
+}
+
+// hook is either a shell command or a list of operations executed with the workload client.
+type hook struct {
+	Command    string
+	Operations []*operation
+}
+
+// runHooks executes hooks in order. Hook errors and failed operations are reported to stderr and
+// counted as workload errors, since a workload whose setup or teardown did not complete cannot be
+// trusted to have run as intended.
+func (r *workloadRunner) runHooks(stage string, hooks []*hook) {
+	for i, h := range hooks {
+		if h.Command != "" {
+			cmd := exec.Command("sh", "-c", h.Command)
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				r.hookError(fmt.Errorf("%s hook %d: command %q failed: %v", stage, i, h.Command, err))
+			}
+		}
+
+		for _, op := range h.Operations {
+			pass, err := r.runOperation(op)
+			switch {
+			case err != nil:
+				r.hookError(fmt.Errorf("%s hook %d: %s failed: %v", stage, i, op.Name, err))
+			case !pass:
+				r.hookError(fmt.Errorf("%s hook %d: %s returned an unexpected result", stage, i, op.Name))
+			}
+		}
+	}
+}
+
+func (r *workloadRunner) hookError(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	r.recordError(err)
+}
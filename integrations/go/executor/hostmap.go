@@ -0,0 +1,53 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// hostRewritingDialer dials a different address than the one the driver asks for. It lets an
+// executor outside a Kubernetes cluster reach servers that advertise in-cluster DNS names, since
+// the addresses discovered from the topology, and therefore replica set reconfigs, are still
+// followed and only the connection itself is redirected.
+type hostRewritingDialer struct {
+	// keyed by "host:port" or, to map every port of a host, by "host"
+	hosts  map[string]string
+	dialer net.Dialer
+}
+
+func (d *hostRewritingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return d.dialer.DialContext(ctx, network, d.rewrite(address))
+}
+
+func (d *hostRewritingDialer) rewrite(address string) string {
+	if mapped, ok := d.hosts[address]; ok {
+		return mapped
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	if mapped, ok := d.hosts[host]; ok {
+		return net.JoinHostPort(mapped, port)
+	}
+	return address
+}
+
+// ParseHostMap parses a comma-separated list of from=to pairs into a map suitable for
+// Options.HostMap, e.g. "mongo-0.mongo.svc:27017=localhost:30000,mongo-1.mongo.svc=10.0.0.5".
+func ParseHostMap(s string) (map[string]string, error) {
+	hosts := make(map[string]string)
+	if s == "" {
+		return hosts, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		idx := strings.Index(pair, "=")
+		if idx <= 0 || idx == len(pair)-1 {
+			return nil, fmt.Errorf("invalid host mapping %q, expected from=to", pair)
+		}
+		hosts[strings.TrimSpace(pair[:idx])] = strings.TrimSpace(pair[idx+1:])
+	}
+	return hosts, nil
+}
@@ -0,0 +1,178 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// IndexStats reports the indexes declared by the initial data of the workload or created by its
+// createIndex operations that no longer exist after the loop (missing) or exist with different keys
+// or uniqueness (stale), e.g. because a node replaced during maintenance was resynced without them.
+// Each of them is also recorded as a failure.
+type IndexStats struct {
+	NumChecked int      `json:"numChecked"`
+	Missing    []string `json:"missing,omitempty"`
+	Stale      []string `json:"stale,omitempty"`
+}
+
+// declaredIndex is an index the workload expects to exist until the end of the run.
+type declaredIndex struct {
+	db     string
+	coll   string
+	name   string
+	keys   bson.Raw
+	unique bool
+}
+
+func init() {
+	registerCollectionOperation("createIndex", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		return true, r.executeCreateIndex(ctx, coll, op.Arguments)
+	})
+}
+
+// executeCreateIndex creates the index described by the keys, name and unique arguments. Creating
+// an index that exists with the same options succeeds, so the operation can be repeated by every
+// iteration of the workload.
+func (r *workloadRunner) executeCreateIndex(ctx context.Context, coll *mongo.Collection, args bson.Raw) error {
+	var index indexSpec
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "keys":
+			index.Keys = val.Document()
+		case "name":
+			index.Name = val.StringValue()
+		case "unique":
+			index.Unique = val.Boolean()
+		default:
+			return harnessError(fmt.Errorf("unrecognized createIndex option: %v", key))
+		}
+	}
+
+	opts := options.Index().SetUnique(index.Unique)
+	if index.Name != "" {
+		opts.SetName(index.Name)
+	}
+	name, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: index.Keys, Options: opts})
+	if err != nil {
+		return err
+	}
+	index.Name = name
+	r.declareIndex(coll.Database().Name(), coll.Name(), &index)
+	return nil
+}
+
+// declareIndex records that index, whose name must be set, must exist on db.coll when the loop
+// ends. A later declaration of the same name replaces an earlier one.
+func (r *workloadRunner) declareIndex(db, coll string, index *indexSpec) {
+	ns := db + "." + coll + "." + index.Name
+	r.declaredIndexes[ns] = &declaredIndex{db: db, coll: coll, name: index.Name, keys: index.Keys, unique: index.Unique}
+}
+
+// verifyIndexes lists the indexes of each namespace with declared indexes from the primary, records
+// a failure for each declared index that is missing or stale and returns the statistics, or nil if
+// the workload declared no index.
+func (r *workloadRunner) verifyIndexes() *IndexStats {
+	if len(r.declaredIndexes) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(r.declaredIndexes))
+	for name := range r.declaredIndexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	stats := &IndexStats{}
+	listed := make(map[string]map[string]bson.Raw)
+	for _, name := range names {
+		declared := r.declaredIndexes[name]
+		ns := declared.db + "." + declared.coll
+		indexes, ok := listed[ns]
+		if !ok {
+			var err error
+			if indexes, err = r.listIndexes(declared.db, declared.coll); err != nil {
+				// the indexes of the namespace are not checked
+				r.recordError(fmt.Errorf("listing the indexes of %s failed: %v", ns, err))
+			}
+			listed[ns] = indexes
+		}
+		if indexes == nil {
+			continue
+		}
+
+		stats.NumChecked++
+		actual, ok := indexes[declared.name]
+		unique, _ := actual.Lookup("unique").BooleanOK()
+		switch {
+		case !ok:
+			stats.Missing = append(stats.Missing, name)
+			r.recordFailure(fmt.Sprintf("index %s is missing from %s", declared.name, ns))
+		case !keysEqual(declared.keys, actual.Lookup("key").Document()) || declared.unique != unique:
+			stats.Stale = append(stats.Stale, name)
+			r.recordFailure(fmt.Sprintf("index %s of %s is %s, expected keys %s with unique %v",
+				declared.name, ns, actual, declared.keys, declared.unique))
+		}
+	}
+	return stats
+}
+
+// listIndexes returns the indexes of db.coll keyed by name. A collection that does not exist has
+// no indexes.
+func (r *workloadRunner) listIndexes(db, coll string) (map[string]bson.Raw, error) {
+	collOpts := options.Collection().SetReadPreference(readpref.Primary())
+	cursor, err := r.client.Database(db).Collection(coll, collOpts).Indexes().List(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	var docs []bson.Raw
+	if err := cursor.All(context.Background(), &docs); err != nil {
+		return nil, err
+	}
+	indexes := make(map[string]bson.Raw, len(docs))
+	for _, doc := range docs {
+		indexes[doc.Lookup("name").StringValue()] = doc
+	}
+	return indexes, nil
+}
+
+// keysEqual reports whether two index key patterns have the same fields in the same order with the
+// same directions or types. Numeric directions are compared by value, since the server may return
+// them as a different numeric type than the workload declared them with. The server lists text
+// indexes with the key pattern {_fts: "text", _ftsx: 1} instead of the declared one, so they only
+// need to be text indexes.
+func keysEqual(expected, actual bson.Raw) bool {
+	expectedElems, _ := expected.Elements()
+	for _, elem := range expectedElems {
+		if text, ok := elem.Value().StringValueOK(); ok && text == "text" {
+			fts, _ := actual.Lookup("_fts").StringValueOK()
+			return fts == "text"
+		}
+	}
+	actualElems, _ := actual.Elements()
+	if len(expectedElems) != len(actualElems) {
+		return false
+	}
+	for i := range expectedElems {
+		if expectedElems[i].Key() != actualElems[i].Key() {
+			return false
+		}
+		e, a := expectedElems[i].Value(), actualElems[i].Value()
+		if en, ok := numericValue(e); ok {
+			if an, ok := numericValue(a); !ok || en != an {
+				return false
+			}
+		} else if !e.Equal(a) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// collectionData describes the contents of a collection to create before the workload starts.
+type collectionData struct {
+	// defaults to the workload collection
+	CollectionName string `bson:"collectionName"`
+	// defaults to the workload database
+	DatabaseName string `bson:"databaseName"`
+	Documents    []bson.Raw
+	Indexes      []*indexSpec
+}
+
+type indexSpec struct {
+	Keys   bson.Raw
+	Name   string
+	Unique bool
+}
+
+// insertInitialData seeds the cluster before the operation loop starts. Each listed collection
+// is dropped, its indexes are built and its documents are inserted with a majority write concern
+// so that they are visible to the workload regardless of the read preference it uses. The control
+// cluster of a dual-cluster comparison is seeded the same way.
+func (r *workloadRunner) insertInitialData(initialData []*collectionData) error {
+	majority := options.Collection().SetWriteConcern(writeconcern.New(writeconcern.WMajority()))
+
+	for _, data := range initialData {
+		dbName := data.DatabaseName
+		if dbName == "" {
+			dbName = r.coll.Database().Name()
+		}
+		collName := data.CollectionName
+		if collName == "" {
+			collName = r.coll.Name()
+		}
+
+		names, err := seedCollection(r.client.Database(dbName).Collection(collName, majority), data)
+		if err != nil {
+			return err
+		}
+		for i, index := range data.Indexes {
+			r.declareIndex(dbName, collName, &indexSpec{Keys: index.Keys, Name: names[i], Unique: index.Unique})
+		}
+
+		if r.mirror != nil {
+			control := r.mirror.client.Database(dbName).Collection(collName, majority)
+			if _, err := seedCollection(control, data); err != nil {
+				return fmt.Errorf("seeding the control cluster: %v", err)
+			}
+			r.mirror.namespaces[dbName+"."+collName] = [2]string{dbName, collName}
+		}
+	}
+	return nil
+}
+
+// seedCollection drops coll, builds the indexes of data and inserts its documents. It returns the
+// names of the indexes.
+func seedCollection(coll *mongo.Collection, data *collectionData) ([]string, error) {
+	dbName, collName := coll.Database().Name(), coll.Name()
+	if err := coll.Drop(context.Background()); err != nil {
+		return nil, fmt.Errorf("dropping %s.%s: %v", dbName, collName, err)
+	}
+
+	var names []string
+	if len(data.Indexes) > 0 {
+		models := make([]mongo.IndexModel, 0, len(data.Indexes))
+		for _, index := range data.Indexes {
+			opts := options.Index().SetUnique(index.Unique)
+			if index.Name != "" {
+				opts.SetName(index.Name)
+			}
+			models = append(models, mongo.IndexModel{Keys: index.Keys, Options: opts})
+		}
+		var err error
+		if names, err = coll.Indexes().CreateMany(context.Background(), models); err != nil {
+			return nil, fmt.Errorf("creating indexes on %s.%s: %v", dbName, collName, err)
+		}
+	}
+
+	if len(data.Documents) > 0 {
+		docs := make([]interface{}, 0, len(data.Documents))
+		for _, doc := range data.Documents {
+			docs = append(docs, doc)
+		}
+		if _, err := coll.InsertMany(context.Background(), docs); err != nil {
+			return nil, fmt.Errorf("inserting documents into %s.%s: %v", dbName, collName, err)
+		}
+	}
+	return names, nil
+}
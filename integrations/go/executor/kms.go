@@ -0,0 +1,130 @@
+package executor
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+// KMSConfig holds the credentials and endpoints of the KMS providers that encrypted workloads use
+// for their data keys, and the TLS configuration of the providers that need their own, i.e. KMIP.
+type KMSConfig struct {
+	// credentials per provider name, in the form the driver takes them for AutoEncryptionOptions
+	// and ClientEncryptionOptions
+	Providers map[string]map[string]interface{}
+	// TLS configuration per provider name
+	TLSConfig map[string]*tls.Config
+}
+
+// ProviderNames returns the names of the configured providers in alphabetical order.
+func (c *KMSConfig) ProviderNames() []string {
+	names := make([]string, 0, len(c.Providers))
+	for name := range c.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// kmsEnvVar maps an environment variable to a field of the credentials of a KMS provider.
+type kmsEnvVar struct {
+	provider string
+	field    string
+	name     string
+	// the provider is not configured unless every required variable is set
+	required bool
+}
+
+// kmsEnvVars are the environment variables KMSConfigFromEnv reads. The names are the ones the
+// drivers-evergreen-tools CSFLE scripts use, so that CI secrets can be passed through unchanged.
+var kmsEnvVars = []kmsEnvVar{
+	{provider: "aws", field: "accessKeyId", name: "FLE_AWS_KEY", required: true},
+	{provider: "aws", field: "secretAccessKey", name: "FLE_AWS_SECRET", required: true},
+	{provider: "aws", field: "sessionToken", name: "FLE_AWS_SESSION_TOKEN"},
+	{provider: "azure", field: "tenantId", name: "FLE_AZURE_TENANTID", required: true},
+	{provider: "azure", field: "clientId", name: "FLE_AZURE_CLIENTID", required: true},
+	{provider: "azure", field: "clientSecret", name: "FLE_AZURE_CLIENTSECRET", required: true},
+	{provider: "azure", field: "identityPlatformEndpoint", name: "FLE_AZURE_IDENTITY_PLATFORM_ENDPOINT"},
+	{provider: "gcp", field: "email", name: "FLE_GCP_EMAIL", required: true},
+	{provider: "gcp", field: "privateKey", name: "FLE_GCP_PRIVATEKEY", required: true},
+	{provider: "gcp", field: "endpoint", name: "FLE_GCP_ENDPOINT"},
+	{provider: "kmip", field: "endpoint", name: "FLE_KMIP_ENDPOINT", required: true},
+	// base64-encoded 96-byte master key
+	{provider: "local", field: "key", name: "FLE_LOCAL_KEY", required: true},
+}
+
+// KMSConfigFromEnv returns the KMS providers configured by environment variables, see kmsEnvVars.
+// A provider is configured if all of its required variables are set; setting only some of them is
+// an error. The KMIP server is reached over TLS with the CA file in FLE_KMIP_CA_FILE and the client
+// certificate and key in FLE_KMIP_CERT_FILE, which default to the system roots and no client
+// certificate.
+func KMSConfigFromEnv() (*KMSConfig, error) {
+	config := &KMSConfig{
+		Providers: make(map[string]map[string]interface{}),
+		TLSConfig: make(map[string]*tls.Config),
+	}
+	missing := make(map[string][]string)
+	for _, v := range kmsEnvVars {
+		value := os.Getenv(v.name)
+		if value == "" {
+			if v.required {
+				missing[v.provider] = append(missing[v.provider], v.name)
+			}
+			continue
+		}
+		if config.Providers[v.provider] == nil {
+			config.Providers[v.provider] = make(map[string]interface{})
+		}
+		config.Providers[v.provider][v.field] = value
+	}
+	if local, ok := config.Providers["local"]; ok {
+		key, err := base64.StdEncoding.DecodeString(local["key"].(string))
+		if err != nil {
+			return nil, fmt.Errorf("FLE_LOCAL_KEY is not base64: %v", err)
+		}
+		local["key"] = key
+	}
+	for provider, names := range missing {
+		if _, ok := config.Providers[provider]; ok {
+			return nil, fmt.Errorf("the %s KMS provider is partially configured, %v must be set", provider, names)
+		}
+	}
+
+	if _, ok := config.Providers["kmip"]; ok {
+		tlsConfig, err := kmipTLSConfig(os.Getenv("FLE_KMIP_CA_FILE"), os.Getenv("FLE_KMIP_CERT_FILE"))
+		if err != nil {
+			return nil, err
+		}
+		config.TLSConfig["kmip"] = tlsConfig
+	}
+	return config, nil
+}
+
+// kmipTLSConfig returns the TLS configuration for connecting to a KMIP server, trusting the
+// certificates in caFile and presenting the certificate and key in certFile. Either may be empty.
+func kmipTLSConfig(caFile, certFile string) (*tls.Config, error) {
+	config := &tls.Config{}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading the KMIP CA file failed: %v", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("the KMIP CA file has no PEM certificates")
+		}
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, certFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading the KMIP client certificate failed: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
@@ -0,0 +1,34 @@
+package executor
+
+import (
+	"context"
+)
+
+// Operations may carry a label, e.g. "checkout-read", that joins the artifacts of the executor to
+// the server logs: the label is attached to the errors and failures the operation causes, the
+// latency of labelled operations is reported under their label instead of their name, and the
+// commands of collection operations are sent with the label as their $comment.
+
+type labelKey struct{}
+
+// withOperationLabel returns a context carrying label, or ctx itself if label is empty.
+func withOperationLabel(ctx context.Context, label string) context.Context {
+	if label == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, labelKey{}, label)
+}
+
+// operationLabel returns the label carried by ctx, if any.
+func operationLabel(ctx context.Context) string {
+	label, _ := ctx.Value(labelKey{}).(string)
+	return label
+}
+
+// latencyKey is the key the latency of op is reported under.
+func (op *operation) latencyKey() string {
+	if op.Label != "" {
+		return op.Label
+	}
+	return op.Name
+}
@@ -0,0 +1,148 @@
+package executor
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// OperationLatency separates the time operations spent waiting for a server from the time the
+// server spent executing their commands, so that server selection stalls during elections are not
+// hidden in the overall operation latency.
+type OperationLatency struct {
+	// time from the start of an operation until its first command was sent, which includes
+	// checking out a connection. Operations that never sent a command, e.g. because server
+	// selection timed out, count their whole duration.
+	ServerSelectionMS LatencySummary `json:"serverSelectionMS"`
+	// total duration of the commands of an operation as reported by command events
+	CommandMS LatencySummary `json:"commandMS"`
+}
+
+// latencyTracker measures the operation run by the operation loop. Command events may arrive from
+// other goroutines, and only those that arrive while an operation is in flight are attributed to it.
+type latencyTracker struct {
+	mu sync.Mutex
+	// zero when no operation is in flight
+	start       time.Time
+	selection   time.Duration
+	selected    bool
+	commandTime time.Duration
+
+	// latencies in milliseconds keyed by operation name
+	selections map[string][]float64
+	commands   map[string][]float64
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{
+		selections: make(map[string][]float64),
+		commands:   make(map[string][]float64),
+	}
+}
+
+func (t *latencyTracker) operationStarted() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.start = time.Now()
+	t.selected = false
+	t.commandTime = 0
+}
+
+// operationFinished records the latencies of the operation in flight under name and returns them
+// in milliseconds.
+func (t *latencyTracker) operationFinished(name string) (selection, command float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.selected {
+		t.selection = time.Since(t.start)
+	}
+	selection, command = milliseconds(t.selection), milliseconds(t.commandTime)
+	t.selections[name] = append(t.selections[name], selection)
+	t.commands[name] = append(t.commands[name], command)
+	t.start = time.Time{}
+	return selection, command
+}
+
+// record adds latencies in milliseconds measured by another tracker under name.
+func (t *latencyTracker) record(name string, selection, command float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.selections[name] = append(t.selections[name], selection)
+	t.commands[name] = append(t.commands[name], command)
+}
+
+func (t *latencyTracker) commandStarted() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.start.IsZero() || t.selected {
+		return
+	}
+	t.selected = true
+	t.selection = time.Since(t.start)
+}
+
+func (t *latencyTracker) commandFinished(durationNanos int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.start.IsZero() {
+		return
+	}
+	t.commandTime += time.Duration(durationNanos)
+}
+
+// merge adds the latencies recorded by other, which must no longer be in use.
+func (t *latencyTracker) merge(other *latencyTracker) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for name, selections := range other.selections {
+		t.selections[name] = append(t.selections[name], selections...)
+		t.commands[name] = append(t.commands[name], other.commands[name]...)
+	}
+}
+
+// summary returns the latencies of the operations run so far, keyed by operation name.
+func (t *latencyTracker) summary() map[string]*OperationLatency {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.selections) == 0 {
+		return nil
+	}
+	latencies := make(map[string]*OperationLatency, len(t.selections))
+	for name, selections := range t.selections {
+		latencies[name] = &OperationLatency{
+			ServerSelectionMS: summarizeLatencies(selections),
+			CommandMS:         summarizeLatencies(t.commands[name]),
+		}
+	}
+	return latencies
+}
+
+// summarizeLatencies returns the percentiles of latencies, which may be empty.
+func summarizeLatencies(latencies []float64) LatencySummary {
+	sorted := append([]float64(nil), latencies...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n == 0 {
+		return LatencySummary{}
+	}
+	percentile := func(p float64) float64 {
+		return sorted[int(p*float64(n-1))]
+	}
+	return LatencySummary{
+		P50: percentile(0.50),
+		P95: percentile(0.95),
+		P99: percentile(0.99),
+		Max: sorted[n-1],
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,395 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// registerDocumentID is the _id of the document used as the register of a linearizability check.
+const registerDocumentID = "linearizabilityRegister"
+
+// linearizabilityCheck configures concurrent clients that read and write a single document, a
+// register, alongside the workload. The history of their operations is checked for
+// linearizability once the workload stops, in the manner of Porcupine: the checker searches for an
+// order of the operations that is consistent with both their real-time order and the sequential
+// behavior of a register.
+type linearizabilityCheck struct {
+	// number of clients reading and writing the register; defaults to 4
+	Concurrency int
+	// fraction of the operations that are reads; defaults to 0.5
+	ReadRatio float64 `bson:"readRatio"`
+	// read concern of the reads; defaults to "linearizable"
+	ReadConcern string `bson:"readConcern"`
+	// maximum number of operations recorded; defaults to 10000
+	MaxOperations int `bson:"maxOperations"`
+	// time the checker may take before giving up; defaults to 60
+	TimeoutSeconds float64 `bson:"timeoutSeconds"`
+}
+
+// LinearizabilityStats reports the outcome of a linearizability check.
+type LinearizabilityStats struct {
+	NumOperations int `json:"numOperations"`
+	// operations whose outcome is unknown, e.g. writes that failed with a network error, which may
+	// or may not have taken effect
+	NumAmbiguous int `json:"numAmbiguous"`
+	// one of "linearizable", "violation" or "unknown" if the checker timed out
+	Result string `json:"result"`
+	// the operation the longest linearizable prefix of the history could not be extended with
+	Violation string `json:"violation,omitempty"`
+}
+
+// registerOp is an operation on the register recorded in the history. Times are nanoseconds since
+// the check started.
+type registerOp struct {
+	client  int
+	isWrite bool
+	// the value written, or the value returned by a read
+	value int64
+	call  int64
+	// math.MaxInt64 for writes whose outcome is unknown
+	ret int64
+}
+
+func (op registerOp) String() string {
+	kind := "read"
+	if op.isWrite {
+		kind = "write"
+	}
+	ret := "never returned"
+	if op.ret != math.MaxInt64 {
+		ret = fmt.Sprintf("returned at %.3fs", float64(op.ret)/1e9)
+	}
+	return fmt.Sprintf("%s of %d by client %d, called at %.3fs, %s",
+		kind, op.value, op.client, float64(op.call)/1e9, ret)
+}
+
+// linearizabilityChecker runs the clients of a linearizability check and checks their history.
+type linearizabilityChecker struct {
+	config *linearizabilityCheck
+	coll   *mongo.Collection
+	seed   int64
+	start  time.Time
+
+	mu      sync.Mutex
+	history []registerOp
+	wg      sync.WaitGroup
+}
+
+// newLinearizabilityChecker applies the defaults to config and prepares coll, which must belong to a
+// client that is not monitored, for reading and writing the register. The choices between reads
+// and writes of the clients are derived from seed.
+func newLinearizabilityChecker(config *linearizabilityCheck, coll *mongo.Collection, seed int64) (*linearizabilityChecker, error) {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 4
+	}
+	if config.ReadRatio <= 0 {
+		config.ReadRatio = 0.5
+	}
+	if config.ReadConcern == "" {
+		config.ReadConcern = "linearizable"
+	}
+	if config.MaxOperations <= 0 {
+		config.MaxOperations = 10000
+	}
+	if config.TimeoutSeconds <= 0 {
+		config.TimeoutSeconds = 60
+	}
+
+	clone, err := coll.Clone(options.Collection().
+		SetReadPreference(readpref.Primary()).
+		SetReadConcern(readconcern.New(readconcern.Level(config.ReadConcern))).
+		SetWriteConcern(writeconcern.New(writeconcern.WMajority())))
+	if err != nil {
+		return nil, err
+	}
+	return &linearizabilityChecker{config: config, coll: clone, seed: seed}, nil
+}
+
+// run resets the register to 0 and reads and writes it with every client concurrently until ctx is
+// done or the history is full.
+func (c *linearizabilityChecker) run(ctx context.Context) error {
+	c.start = time.Now()
+	reset := registerOp{client: -1, isWrite: true}
+	if err := c.write(&reset); err != nil {
+		return fmt.Errorf("resetting the linearizability register failed: %v", err)
+	}
+	c.history = append(c.history, reset)
+
+	for i := 0; i < c.config.Concurrency; i++ {
+		client := i
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.runClient(ctx, client)
+		}()
+	}
+	c.wg.Wait()
+	return nil
+}
+
+func (c *linearizabilityChecker) runClient(ctx context.Context, client int) {
+	rng := rand.New(rand.NewSource(c.seed + int64(client) + 1))
+	for n := int64(1); ; n++ {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		op := registerOp{client: client}
+		var err error
+		if rng.Float64() < c.config.ReadRatio {
+			err = c.read(&op)
+		} else {
+			op.isWrite = true
+			// values are unique across clients
+			op.value = n*int64(c.config.Concurrency) + int64(client) + 1
+			err = c.write(&op)
+		}
+
+		switch {
+		case err == nil:
+		case op.isWrite:
+			// the write may still have taken effect
+			op.ret = math.MaxInt64
+		default:
+			continue
+		}
+
+		c.mu.Lock()
+		full := len(c.history) >= c.config.MaxOperations
+		if !full {
+			c.history = append(c.history, op)
+		}
+		c.mu.Unlock()
+		if full {
+			return
+		}
+	}
+}
+
+// the operations are not run with the context of the check so that stopping the check does not
+// leave operations with an unknown outcome
+func (c *linearizabilityChecker) write(op *registerOp) error {
+	filter := bson.D{{Key: "_id", Value: registerDocumentID}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "value", Value: op.value}}}}
+	op.call = int64(time.Since(c.start))
+	_, err := c.coll.UpdateOne(context.Background(), filter, update, options.Update().SetUpsert(true))
+	op.ret = int64(time.Since(c.start))
+	return err
+}
+
+func (c *linearizabilityChecker) read(op *registerOp) error {
+	filter := bson.D{{Key: "_id", Value: registerDocumentID}}
+	var doc bson.Raw
+	op.call = int64(time.Since(c.start))
+	err := c.coll.FindOne(context.Background(), filter).Decode(&doc)
+	op.ret = int64(time.Since(c.start))
+	if err != nil {
+		return err
+	}
+	op.value = doc.Lookup("value").AsInt64()
+	return nil
+}
+
+// summary checks the history recorded so far.
+func (c *linearizabilityChecker) summary() *LinearizabilityStats {
+	c.mu.Lock()
+	history := append([]registerOp(nil), c.history...)
+	c.mu.Unlock()
+
+	stats := &LinearizabilityStats{NumOperations: len(history)}
+	for _, op := range history {
+		if op.ret == math.MaxInt64 {
+			stats.NumAmbiguous++
+		}
+	}
+	timeout := time.Duration(c.config.TimeoutSeconds * float64(time.Second))
+	ok, violation, finished := checkRegisterHistory(history, time.Now().Add(timeout))
+	switch {
+	case !finished:
+		stats.Result = "unknown"
+	case ok:
+		stats.Result = "linearizable"
+	default:
+		stats.Result = "violation"
+		stats.Violation = violation.String()
+	}
+	return stats
+}
+
+// historyEntry is the call or the return of an operation in the doubly linked list the checker
+// searches.
+type historyEntry struct {
+	isCall bool
+	op     int
+	time   int64
+	// the return entry of a call entry
+	match      *historyEntry
+	prev, next *historyEntry
+}
+
+// lift removes the call entry e and its return entry from the list.
+func (e *historyEntry) lift() {
+	e.prev.next = e.next
+	if e.next != nil {
+		e.next.prev = e.prev
+	}
+	m := e.match
+	m.prev.next = m.next
+	if m.next != nil {
+		m.next.prev = m.prev
+	}
+}
+
+// unlift reinserts the call entry e and its return entry, which were removed by lift.
+func (e *historyEntry) unlift() {
+	m := e.match
+	m.prev.next = m
+	if m.next != nil {
+		m.next.prev = m
+	}
+	e.prev.next = e
+	if e.next != nil {
+		e.next.prev = e
+	}
+}
+
+type bitset []uint64
+
+func (b bitset) set(i int)   { b[i/64] |= 1 << uint(i%64) }
+func (b bitset) clear(i int) { b[i/64] &^= 1 << uint(i%64) }
+
+func (b bitset) hash() uint64 {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+	for _, w := range b {
+		for i := range buf {
+			buf[i] = byte(w >> uint(8*i))
+		}
+		_, _ = h.Write(buf)
+	}
+	return h.Sum64()
+}
+
+func (b bitset) equals(other bitset) bool {
+	for i := range b {
+		if b[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type cacheEntry struct {
+	linearized bitset
+	state      int64
+}
+
+// checkRegisterHistory reports whether history is linearizable with respect to a register that
+// starts at 0, using the algorithm of Wing and Gong with the memoization of Lowe. If it is not, the
+// operation that the longest linearizable prefix found could not be extended with is returned. The
+// search gives up at deadline, in which case finished is false.
+func checkRegisterHistory(history []registerOp, deadline time.Time) (ok bool, violation registerOp, finished bool) {
+	entries := make([]*historyEntry, 0, 2*len(history))
+	for i, op := range history {
+		ret := &historyEntry{op: i, time: op.ret}
+		entries = append(entries, &historyEntry{isCall: true, op: i, time: op.call, match: ret}, ret)
+	}
+	// calls sort before returns at the same time, which treats the operations as concurrent
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].time != entries[j].time {
+			return entries[i].time < entries[j].time
+		}
+		return entries[i].isCall && !entries[j].isCall
+	})
+	head := &historyEntry{}
+	prev := head
+	for _, e := range entries {
+		e.prev = prev
+		prev.next = e
+		prev = e
+	}
+
+	type frame struct {
+		entry *historyEntry
+		state int64
+	}
+	var stack []frame
+	var state int64
+	linearized := make(bitset, (len(history)+63)/64)
+	cache := make(map[uint64][]cacheEntry)
+	deepest := -1
+
+	entry := head.next
+	for steps := 0; head.next != nil; steps++ {
+		if steps%10000 == 0 && time.Now().After(deadline) {
+			return false, registerOp{}, false
+		}
+
+		if entry.isCall {
+			op := history[entry.op]
+			newState := state
+			legal := true
+			if op.isWrite {
+				newState = op.value
+			} else {
+				legal = op.value == state
+			}
+			if legal {
+				linearized.set(entry.op)
+				key := linearized.hash()
+				seen := false
+				for _, c := range cache[key] {
+					if c.state == newState && c.linearized.equals(linearized) {
+						seen = true
+						break
+					}
+				}
+				if !seen {
+					cache[key] = append(cache[key], cacheEntry{
+						linearized: append(bitset(nil), linearized...),
+						state:      newState,
+					})
+					stack = append(stack, frame{entry: entry, state: state})
+					state = newState
+					entry.lift()
+					entry = head.next
+					continue
+				}
+				linearized.clear(entry.op)
+			}
+			entry = entry.next
+			continue
+		}
+
+		// a return entry was reached before its call could be linearized, so backtrack
+		if len(stack) > deepest {
+			deepest = len(stack)
+			violation = history[entry.op]
+		}
+		if len(stack) == 0 {
+			return false, violation, true
+		}
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		state = top.state
+		linearized.clear(top.entry.op)
+		top.entry.unlift()
+		entry = top.entry.next
+	}
+	return true, registerOp{}, true
+}
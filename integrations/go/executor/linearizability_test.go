@@ -0,0 +1,120 @@
+package executor
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func registerWrite(client int, value, call, ret int64) registerOp {
+	return registerOp{client: client, isWrite: true, value: value, call: call, ret: ret}
+}
+
+func registerRead(client int, value, call, ret int64) registerOp {
+	return registerOp{client: client, value: value, call: call, ret: ret}
+}
+
+func TestCheckRegisterHistory(t *testing.T) {
+	testCases := []struct {
+		name    string
+		history []registerOp
+		ok      bool
+		// index in history of the violation reported if the history is not linearizable
+		violation int
+	}{
+		{
+			name: "empty",
+			ok:   true,
+		},
+		{
+			name:    "read of the initial value",
+			history: []registerOp{registerRead(0, 0, 0, 10)},
+			ok:      true,
+		},
+		{
+			name:    "sequential write and read",
+			history: []registerOp{registerWrite(0, 1, 0, 10), registerRead(1, 1, 20, 30)},
+			ok:      true,
+		},
+		{
+			name:    "read concurrent with a write sees the old value",
+			history: []registerOp{registerWrite(0, 1, 0, 100), registerRead(1, 0, 10, 20)},
+			ok:      true,
+		},
+		{
+			name:    "read concurrent with a write sees the new value",
+			history: []registerOp{registerWrite(0, 1, 0, 100), registerRead(1, 1, 10, 20)},
+			ok:      true,
+		},
+		{
+			name:    "calls and returns at the same time are concurrent",
+			history: []registerOp{registerWrite(0, 1, 0, 10), registerRead(1, 0, 10, 20)},
+			ok:      true,
+		},
+		{
+			name: "concurrent writes in either order",
+			history: []registerOp{
+				registerWrite(0, 1, 0, 50), registerWrite(1, 2, 0, 50),
+				registerRead(2, 1, 60, 70), registerRead(3, 1, 80, 90),
+			},
+			ok: true,
+		},
+		{
+			name:    "ambiguous write that took effect",
+			history: []registerOp{registerWrite(0, 1, 0, math.MaxInt64), registerRead(1, 1, 20, 30)},
+			ok:      true,
+		},
+		{
+			name:    "ambiguous write that did not take effect",
+			history: []registerOp{registerWrite(0, 1, 0, math.MaxInt64), registerRead(1, 0, 20, 30)},
+			ok:      true,
+		},
+		{
+			name:      "stale read after a write",
+			history:   []registerOp{registerWrite(0, 1, 0, 10), registerRead(1, 0, 20, 30)},
+			violation: 1,
+		},
+		{
+			name:      "read of a value never written",
+			history:   []registerOp{registerWrite(0, 1, 0, 10), registerRead(1, 2, 20, 30)},
+			violation: 1,
+		},
+		{
+			name: "read goes back to the old value during a write",
+			history: []registerOp{
+				registerWrite(0, 1, 0, 100), registerRead(1, 1, 10, 20), registerRead(2, 0, 30, 40),
+			},
+			violation: 2,
+		},
+		{
+			name: "reads disagree on the order of concurrent writes",
+			history: []registerOp{
+				registerWrite(0, 1, 0, 50), registerWrite(1, 2, 0, 50),
+				registerRead(2, 2, 60, 70), registerRead(3, 1, 80, 90),
+			},
+			violation: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, violation, finished := checkRegisterHistory(tc.history, time.Now().Add(time.Minute))
+			if !finished {
+				t.Fatal("the check did not finish")
+			}
+			if ok != tc.ok {
+				t.Fatalf("expected linearizable %v, got %v", tc.ok, ok)
+			}
+			if !ok && violation != tc.history[tc.violation] {
+				t.Fatalf("expected violation %v, got %v", tc.history[tc.violation], violation)
+			}
+		})
+	}
+}
+
+func TestCheckRegisterHistoryDeadline(t *testing.T) {
+	history := []registerOp{registerWrite(0, 1, 0, 10), registerRead(1, 1, 20, 30)}
+	if _, _, finished := checkRegisterHistory(history, time.Now().Add(-time.Second)); finished {
+		t.Fatal("expected the check to give up after its deadline")
+	}
+}
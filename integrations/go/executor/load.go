@@ -0,0 +1,176 @@
+package executor
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// loadCollectionSuffix is appended to the collection of the workload to name the collection the
+// synthetic load runs against, so that the load does not disturb the outcome of the workload.
+const loadCollectionSuffix = "_load"
+
+// SyntheticLoad configures the throughput mode of the executor: workers that run reads and writes
+// as fast as they can, or at a target rate, alongside the workload, to put the cluster under
+// enough load to trigger e.g. compute auto-scaling. The reads and updates select documents by an
+// unindexed field, so that each of them scans the load collection and costs the server CPU.
+type SyntheticLoad struct {
+	// number of workers; defaults to 16
+	Concurrency int `json:"concurrency"`
+	// target operations per second across the workers; zero means as fast as possible
+	OpsPerSecond float64 `json:"opsPerSecond"`
+	// fraction of the operations that are reads; defaults to 0.8
+	ReadRatio float64 `json:"readRatio"`
+	// size of the documents inserted; defaults to 4096
+	DocumentSizeBytes int `json:"documentSizeBytes"`
+	// number of documents after which writes update documents instead of inserting them;
+	// defaults to 100000
+	MaxDocuments int64 `json:"maxDocuments"`
+}
+
+// LoadStats summarizes the operations of the synthetic load. Their errors are counted here rather
+// than as errors of the workload, since the load only exists to stress the cluster.
+type LoadStats struct {
+	NumOperations int     `json:"numOperations"`
+	NumErrors     int     `json:"numErrors"`
+	OpsPerSecond  float64 `json:"opsPerSecond"`
+	LastError     string  `json:"lastError,omitempty"`
+}
+
+// loadGenerator runs the workers of a synthetic load.
+type loadGenerator struct {
+	// first for 64-bit alignment of the atomic operations on 32-bit platforms
+	numDocuments int64
+
+	config *SyntheticLoad
+	coll   *mongo.Collection
+	seed   int64
+	start  time.Time
+	end    time.Time
+
+	mu    sync.Mutex
+	stats LoadStats
+	wg    sync.WaitGroup
+}
+
+// newLoadGenerator applies the defaults to config. coll must belong to a client that is not
+// monitored. The choices of the workers are derived from seed.
+func newLoadGenerator(config *SyntheticLoad, coll *mongo.Collection, seed int64) *loadGenerator {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 16
+	}
+	if config.ReadRatio <= 0 {
+		config.ReadRatio = 0.8
+	}
+	if config.DocumentSizeBytes <= 0 {
+		config.DocumentSizeBytes = 4096
+	}
+	if config.MaxDocuments <= 0 {
+		config.MaxDocuments = 100000
+	}
+	return &loadGenerator{config: config, coll: coll, seed: seed}
+}
+
+// run runs the workers until ctx is done and waits for their last operations.
+func (g *loadGenerator) run(ctx context.Context) {
+	var tokens <-chan time.Time
+	if g.config.OpsPerSecond > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / g.config.OpsPerSecond))
+		defer ticker.Stop()
+		tokens = ticker.C
+	}
+
+	g.start = time.Now()
+	for i := 0; i < g.config.Concurrency; i++ {
+		g.wg.Add(1)
+		go func(worker int) {
+			defer g.wg.Done()
+			rng := rand.New(rand.NewSource(g.seed + int64(worker)))
+			for {
+				if tokens != nil {
+					select {
+					case <-ctx.Done():
+						return
+					case <-tokens:
+					}
+				} else if ctx.Err() != nil {
+					return
+				}
+				err := g.runOne(ctx, rng)
+				// operations interrupted by the end of the run are not counted
+				if err != nil && ctx.Err() != nil {
+					return
+				}
+				g.record(err)
+			}
+		}(i)
+	}
+	g.wg.Wait()
+	g.end = time.Now()
+}
+
+// runOne runs a read or a write. Documents carry a random integer n, which the reads and the
+// updates select documents by; few documents share a value, so a read scans the whole collection.
+func (g *loadGenerator) runOne(ctx context.Context, rng *rand.Rand) error {
+	n := rng.Int63n(g.config.MaxDocuments)
+	if rng.Float64() < g.config.ReadRatio {
+		cursor, err := g.coll.Find(ctx, bson.D{{Key: "n", Value: n}},
+			options.Find().SetLimit(10))
+		if err != nil {
+			return err
+		}
+		for cursor.Next(ctx) {
+		}
+		err = cursor.Err()
+		_ = cursor.Close(context.Background())
+		return err
+	}
+
+	if atomic.LoadInt64(&g.numDocuments) >= g.config.MaxDocuments {
+		_, err := g.coll.UpdateOne(ctx, bson.D{{Key: "n", Value: n}},
+			bson.D{{Key: "$inc", Value: bson.D{{Key: "updates", Value: 1}}}})
+		return err
+	}
+	doc, err := bson.Marshal(bson.D{{Key: "n", Value: n}})
+	if err != nil {
+		return err
+	}
+	if doc, err = padDocument(doc, g.config.DocumentSizeBytes); err != nil {
+		return err
+	}
+	if _, err = g.coll.InsertOne(ctx, doc); err != nil {
+		return err
+	}
+	atomic.AddInt64(&g.numDocuments, 1)
+	return nil
+}
+
+// record counts the outcome of an operation.
+func (g *loadGenerator) record(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.stats.NumOperations++
+	if err != nil {
+		g.stats.NumErrors++
+		g.stats.LastError = err.Error()
+	}
+}
+
+// summary returns the statistics of the load once run has returned.
+func (g *loadGenerator) summary() *LoadStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stats := g.stats
+	if elapsed := g.end.Sub(g.start).Seconds(); elapsed > 0 {
+		stats.OpsPerSecond = float64(stats.NumOperations) / elapsed
+	}
+	return &stats
+}
@@ -0,0 +1,243 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Latencies are kept in histograms with logarithmic buckets, so that a run of any length uses a
+// fixed amount of memory. Each bucket spans latencyBucketGrowth times the one before it, which
+// bounds the error of a percentile to that factor.
+const (
+	// upper bound of the first bucket, in milliseconds
+	latencyHistogramMin = 0.01
+	latencyBucketGrowth = 1.05
+)
+
+// Metrics are the end-to-end latencies and throughput of the operation loop, written to
+// metrics.json by the workload executor binary, see MetricsFileSink. Unlike Results.Latency, which
+// splits the latency of an operation by where it was spent, they measure the whole time the
+// application waited, including retries.
+type Metrics struct {
+	// latencies of all the operations of the loop
+	LatencyMS LatencySummary `json:"latencyMS"`
+	// latencies per operation name, or per label for labelled operations
+	Operations map[string]*OperationMetrics `json:"operations"`
+	// width of the buckets of Throughput
+	BucketSeconds float64             `json:"bucketSeconds"`
+	Throughput    []*ThroughputBucket `json:"throughput"`
+	// longest time without a successful operation, if any operation ran
+	LongestStall *Stall `json:"longestStall,omitempty"`
+}
+
+// OperationMetrics holds the end-to-end latencies of the operations sharing a name.
+type OperationMetrics struct {
+	NumOperations int            `json:"numOperations"`
+	LatencyMS     LatencySummary `json:"latencyMS"`
+}
+
+// ThroughputBucket counts the operations that finished within a bucket of time.
+type ThroughputBucket struct {
+	// seconds since the Unix epoch
+	Start        float64 `json:"start"`
+	NumSuccesses int     `json:"numSuccesses"`
+	// errors and failures
+	NumUnsuccessful int `json:"numUnsuccessful"`
+	// successful operations per second
+	OpsPerSec float64 `json:"opsPerSec"`
+}
+
+// Stall is a window of time in which no operation of the loop succeeded, e.g. while a new primary
+// was being elected.
+type Stall struct {
+	// seconds since the Unix epoch
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	DurationMS float64 `json:"durationMS"`
+}
+
+// latencyHistogram counts latencies in logarithmic buckets.
+type latencyHistogram struct {
+	counts []int
+	total  int
+	max    float64
+}
+
+func (h *latencyHistogram) record(ms float64) {
+	i := 0
+	if ms > latencyHistogramMin {
+		i = int(math.Ceil(math.Log(ms/latencyHistogramMin) / math.Log(latencyBucketGrowth)))
+	}
+	for len(h.counts) <= i {
+		h.counts = append(h.counts, 0)
+	}
+	h.counts[i]++
+	h.total++
+	if ms > h.max {
+		h.max = ms
+	}
+}
+
+// percentile returns the upper bound of the bucket holding the p-th percentile, or the maximum if
+// that is lower.
+func (h *latencyHistogram) percentile(p float64) float64 {
+	rank := int(p*float64(h.total-1)) + 1
+	seen := 0
+	for i, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			return math.Min(latencyHistogramMin*math.Pow(latencyBucketGrowth, float64(i)), h.max)
+		}
+	}
+	return h.max
+}
+
+func (h *latencyHistogram) summary() LatencySummary {
+	if h.total == 0 {
+		return LatencySummary{}
+	}
+	return LatencySummary{
+		P50: h.percentile(0.50),
+		P95: h.percentile(0.95),
+		P99: h.percentile(0.99),
+		Max: h.max,
+	}
+}
+
+// metricsRecorder measures the operations run by the operation loop. The workers of the loop share
+// it, see runWorkers, so that a stall only ends when any of them succeeds.
+type metricsRecorder struct {
+	mu         sync.Mutex
+	bucketSize time.Duration
+	start      time.Time
+
+	all        latencyHistogram
+	operations map[string]*latencyHistogram
+	// buckets of throughput since start, indexed by the number of bucketSize since start
+	buckets []*ThroughputBucket
+
+	lastSuccess time.Time
+	stall       *Stall
+}
+
+func newMetricsRecorder(bucketSize time.Duration, start time.Time) *metricsRecorder {
+	return &metricsRecorder{
+		bucketSize:  bucketSize,
+		start:       start,
+		operations:  make(map[string]*latencyHistogram),
+		lastSuccess: start,
+	}
+}
+
+// record adds an operation that started at start and took latency under name.
+func (m *metricsRecorder) record(name string, start time.Time, latency time.Duration, succeeded bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ms := milliseconds(latency)
+	m.all.record(ms)
+	h, ok := m.operations[name]
+	if !ok {
+		h = &latencyHistogram{}
+		m.operations[name] = h
+	}
+	h.record(ms)
+
+	end := start.Add(latency)
+	bucket := m.bucket(end)
+	if !succeeded {
+		bucket.NumUnsuccessful++
+		return
+	}
+	bucket.NumSuccesses++
+	m.endStall(end)
+}
+
+// bucket returns the throughput bucket t falls into.
+func (m *metricsRecorder) bucket(t time.Time) *ThroughputBucket {
+	i := int(t.Sub(m.start) / m.bucketSize)
+	if i < 0 {
+		i = 0
+	}
+	for len(m.buckets) <= i {
+		start := m.start.Add(time.Duration(len(m.buckets)) * m.bucketSize)
+		m.buckets = append(m.buckets, &ThroughputBucket{Start: float64(start.UnixNano()) / 1e9})
+	}
+	return m.buckets[i]
+}
+
+// endStall ends the window without a successful operation at t and keeps it if it is the longest.
+func (m *metricsRecorder) endStall(t time.Time) {
+	if d := t.Sub(m.lastSuccess); m.stall == nil || milliseconds(d) > m.stall.DurationMS {
+		m.stall = &Stall{
+			Start:      float64(m.lastSuccess.UnixNano()) / 1e9,
+			End:        float64(t.UnixNano()) / 1e9,
+			DurationMS: milliseconds(d),
+		}
+	}
+	if t.After(m.lastSuccess) {
+		m.lastSuccess = t
+	}
+}
+
+// summary returns the metrics of the loop, which ended at end.
+func (m *metricsRecorder) summary(end time.Time) *Metrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metrics := &Metrics{
+		LatencyMS:     m.all.summary(),
+		Operations:    make(map[string]*OperationMetrics, len(m.operations)),
+		BucketSeconds: m.bucketSize.Seconds(),
+		Throughput:    m.buckets,
+	}
+	names := make([]string, 0, len(m.operations))
+	for name := range m.operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		h := m.operations[name]
+		metrics.Operations[name] = &OperationMetrics{NumOperations: h.total, LatencyMS: h.summary()}
+	}
+
+	// the last bucket is usually cut short by the end of the loop
+	for i, bucket := range metrics.Throughput {
+		width := m.bucketSize
+		if i == len(metrics.Throughput)-1 {
+			if rest := end.Sub(m.start) - time.Duration(i)*m.bucketSize; rest > 0 && rest < width {
+				width = rest
+			}
+		}
+		bucket.OpsPerSec = float64(bucket.NumSuccesses) / width.Seconds()
+	}
+	if metrics.Throughput == nil {
+		metrics.Throughput = []*ThroughputBucket{}
+	}
+
+	if m.all.total > 0 {
+		m.endStall(end)
+		metrics.LongestStall = m.stall
+	}
+	return metrics
+}
+
+// MetricsFileSink returns a Sink that writes the Metrics of the operation loop as JSON to the file
+// at path, which astrolabe reads from metrics.json. Nothing is written for workloads that did not
+// run.
+func MetricsFileSink(path string) Sink {
+	return SinkFunc(func(results *Results) error {
+		if results.Metrics == nil {
+			return nil
+		}
+		data, err := json.Marshal(results.Metrics)
+		if err != nil {
+			return fmt.Errorf("marshal metrics failed: %v", err)
+		}
+		return writeFileAtomically(path, data)
+	})
+}
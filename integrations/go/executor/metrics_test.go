@@ -0,0 +1,103 @@
+package executor
+
+import (
+	"math"
+	"testing"
+)
+
+// bucketBound returns the upper bound of bucket i of a latencyHistogram.
+func bucketBound(i int) float64 {
+	return latencyHistogramMin * math.Pow(latencyBucketGrowth, float64(i))
+}
+
+// spreadLatencies returns n latencies in increasing order, the k-th in the middle of bucket 2k+1,
+// so that no two of them share a bucket.
+func spreadLatencies(n int) []float64 {
+	latencies := make([]float64, n)
+	for k := range latencies {
+		latencies[k] = latencyHistogramMin * math.Pow(latencyBucketGrowth, float64(2*k)+0.5)
+	}
+	return latencies
+}
+
+func reversed(latencies []float64) []float64 {
+	r := make([]float64, len(latencies))
+	for i, ms := range latencies {
+		r[len(latencies)-1-i] = ms
+	}
+	return r
+}
+
+func TestLatencyHistogramSummary(t *testing.T) {
+	hundred := spreadLatencies(100)
+	hundredAndOne := spreadLatencies(101)
+
+	testCases := []struct {
+		name      string
+		latencies []float64
+		expected  LatencySummary
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:      "single latency is reported as is",
+			latencies: []float64{5},
+			expected:  LatencySummary{P50: 5, P95: 5, P99: 5, Max: 5},
+		},
+		{
+			name:      "latencies below the minimum share the first bucket",
+			latencies: []float64{0.001, 0.005, 0.01},
+			expected:  LatencySummary{P50: 0.01, P95: 0.01, P99: 0.01, Max: 0.01},
+		},
+		{
+			// ranks 50, 95 and 99
+			name:      "hundred latencies",
+			latencies: hundred,
+			expected: LatencySummary{
+				P50: bucketBound(2*49 + 1),
+				P95: bucketBound(2*94 + 1),
+				P99: bucketBound(2*98 + 1),
+				Max: hundred[99],
+			},
+		},
+		{
+			name:      "order does not matter",
+			latencies: reversed(hundred),
+			expected: LatencySummary{
+				P50: bucketBound(2*49 + 1),
+				P95: bucketBound(2*94 + 1),
+				P99: bucketBound(2*98 + 1),
+				Max: hundred[99],
+			},
+		},
+		{
+			// ranks 51, 96 and 100
+			name:      "hundred and one latencies",
+			latencies: hundredAndOne,
+			expected: LatencySummary{
+				P50: bucketBound(2*50 + 1),
+				P95: bucketBound(2*95 + 1),
+				P99: bucketBound(2*99 + 1),
+				Max: hundredAndOne[100],
+			},
+		},
+		{
+			name:      "percentile in the bucket of the maximum is the maximum",
+			latencies: []float64{1, 2, 2.01},
+			expected:  LatencySummary{P50: 2.01, P95: 2.01, P99: 2.01, Max: 2.01},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var h latencyHistogram
+			for _, ms := range tc.latencies {
+				h.record(ms)
+			}
+			if summary := h.summary(); summary != tc.expected {
+				t.Fatalf("expected %+v, got %+v", tc.expected, summary)
+			}
+		})
+	}
+}
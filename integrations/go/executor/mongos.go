@@ -0,0 +1,122 @@
+package executor
+
+import (
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/description"
+)
+
+// MongosStats describes how the commands of a workload were distributed over a mongos and when the
+// mongos was unavailable, since maintenance of a sharded cluster restarts the routers as well as
+// the shards.
+type MongosStats struct {
+	NumSucceeded int      `json:"numSucceeded"`
+	NumFailed    int      `json:"numFailed"`
+	Outages      []Outage `json:"outages,omitempty"`
+}
+
+// Outage is a period during which the driver did not consider a server usable.
+type Outage struct {
+	// seconds since the Unix epoch
+	Start float64 `json:"start"`
+	// zero if the server had not returned when the workload finished
+	End float64 `json:"end,omitempty"`
+}
+
+// mongosTracker collects MongosStats from SDAM and command events, which arrive concurrently with
+// the operation loop.
+type mongosTracker struct {
+	mu    sync.Mutex
+	hosts map[string]*MongosStats
+}
+
+func newMongosTracker() *mongosTracker {
+	return &mongosTracker{hosts: make(map[string]*MongosStats)}
+}
+
+// serverChanged records the start or end of an outage when a server stops or starts being a
+// usable mongos.
+func (t *mongosTracker) serverChanged(evt *event.ServerDescriptionChangedEvent) {
+	wasMongos := evt.PreviousDescription.Kind == description.Mongos
+	isMongos := evt.NewDescription.Kind == description.Mongos
+	if wasMongos == isMongos {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	addr := evt.Address.String()
+	stats, ok := t.hosts[addr]
+	if !ok {
+		if !isMongos {
+			return
+		}
+		stats = &MongosStats{}
+		t.hosts[addr] = stats
+	}
+
+	n := len(stats.Outages)
+	switch {
+	case !isMongos:
+		stats.Outages = append(stats.Outages, Outage{Start: now()})
+	case n > 0 && stats.Outages[n-1].End == 0:
+		stats.Outages[n-1].End = now()
+	}
+}
+
+// commandFinished counts a command on addr if addr is a known mongos.
+func (t *mongosTracker) commandFinished(addr string, succeeded bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats, ok := t.hosts[addr]
+	if !ok {
+		return
+	}
+	if succeeded {
+		stats.NumSucceeded++
+	} else {
+		stats.NumFailed++
+	}
+}
+
+// summary returns a copy of the stats of every mongos seen, or nil if none was.
+func (t *mongosTracker) summary() map[string]*MongosStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.hosts) == 0 {
+		return nil
+	}
+	summary := make(map[string]*MongosStats, len(t.hosts))
+	for addr, stats := range t.hosts {
+		copied := *stats
+		copied.Outages = append([]Outage(nil), stats.Outages...)
+		summary[addr] = &copied
+	}
+	return summary
+}
+
+// serverMonitor returns a monitor that tracks changes to the servers and topology of the workload
+// client.
+func (r *workloadRunner) serverMonitor() *event.ServerMonitor {
+	return &event.ServerMonitor{
+		ServerOpening: func(evt *event.ServerOpeningEvent) {
+			r.topology.serverOpening(evt)
+		},
+		ServerDescriptionChanged: func(evt *event.ServerDescriptionChangedEvent) {
+			r.mongos.serverChanged(evt)
+			r.topology.serverChanged(evt)
+			r.routing.serverChanged(evt)
+			r.sdam.serverChanged(evt)
+		},
+		TopologyDescriptionChanged: func(evt *event.TopologyDescriptionChangedEvent) {
+			r.sdam.topologyChanged(evt)
+		},
+		ServerClosed: func(evt *event.ServerClosedEvent) {
+			r.topology.serverClosed(evt)
+		},
+	}
+}
@@ -0,0 +1,95 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// collectionOutcome describes the expected state of a collection after the workload finishes. Each
+// of the documents, count and result assertions is optional and checked independently.
+type collectionOutcome struct {
+	// defaults to the workload collection
+	CollectionName string `bson:"collectionName"`
+	// defaults to the workload database
+	DatabaseName string `bson:"databaseName"`
+	// expected contents of the collection, sorted by _id
+	Documents interface{}
+	// expected number of documents matching filter
+	Count  *int64
+	Filter bson.Raw
+	// aggregation pipeline whose output must equal result
+	Pipeline []bson.Raw
+	Result   interface{}
+}
+
+// verifyOutcome checks the final state of the cluster against the outcome declared by the workload
+// and returns the number of assertions that did not hold. Reads are done from the primary with a
+// majority read concern so that every acknowledged write is observed.
+func (r *workloadRunner) verifyOutcome(outcome []*collectionOutcome) int {
+	numFailures := 0
+	collOpts := options.Collection().
+		SetReadPreference(readpref.Primary()).
+		SetReadConcern(readconcern.Majority())
+
+	for _, expected := range outcome {
+		dbName := expected.DatabaseName
+		if dbName == "" {
+			dbName = r.coll.Database().Name()
+		}
+		collName := expected.CollectionName
+		if collName == "" {
+			collName = r.coll.Name()
+		}
+		coll := r.client.Database(dbName).Collection(collName, collOpts)
+		ns := dbName + "." + collName
+
+		fail := func(format string, args ...interface{}) {
+			numFailures++
+			fmt.Fprintf(os.Stderr, "outcome failure for %s: %s\n", ns, fmt.Sprintf(format, args...))
+		}
+
+		if expected.Documents != nil {
+			opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+			cursor, err := coll.Find(context.Background(), emptyDoc, opts)
+			if err != nil {
+				fail("find failed: %v", err)
+			} else if pass, err := verifyCursorResult(cursor, expected.Documents, r.verifier); err != nil {
+				fail("reading the documents failed: %v", err)
+			} else if !pass {
+				fail("collection contents do not match the expected documents")
+			}
+		}
+
+		if expected.Count != nil {
+			filter := expected.Filter
+			if filter == nil {
+				filter = emptyDoc
+			}
+			count, err := coll.CountDocuments(context.Background(), filter)
+			switch {
+			case err != nil:
+				fail("countDocuments failed: %v", err)
+			case !r.verifier.countsMatch(*expected.Count, count):
+				fail("expected %d documents, found %d", *expected.Count, count)
+			}
+		}
+
+		if expected.Pipeline != nil {
+			cursor, err := coll.Aggregate(context.Background(), expected.Pipeline)
+			if err != nil {
+				fail("aggregate failed: %v", err)
+			} else if pass, err := verifyCursorResult(cursor, expected.Result, r.verifier); err != nil {
+				fail("reading the aggregation result failed: %v", err)
+			} else if !pass {
+				fail("aggregation result does not match the expected result")
+			}
+		}
+	}
+	return numFailures
+}
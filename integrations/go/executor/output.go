@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// recordOutputCollection remembers the collection written by an aggregation against coll whose
+// pipeline ends in a $out or $merge stage, so that it can be dropped when the workload finishes.
+func (r *workloadRunner) recordOutputCollection(coll *mongo.Collection, args bson.Raw) {
+	stages, _ := args.Lookup("pipeline").Array().Values()
+	if len(stages) == 0 {
+		return
+	}
+	db, name, ok := outputNamespace(coll.Database().Name(), stages[len(stages)-1].Document())
+	if !ok {
+		return
+	}
+	ns := db + "." + name
+	if _, ok := r.outputCollections[ns]; ok {
+		return
+	}
+	r.outputCollections[ns] = coll.Database().Client().Database(db).Collection(name)
+}
+
+// outputNamespace returns the namespace written by stage if it is a $out or $merge stage. The
+// target may be given as a collection name in db or as a document naming the database and
+// collection.
+func outputNamespace(db string, stage bson.Raw) (string, string, bool) {
+	target, err := stage.LookupErr("$out")
+	if err != nil {
+		merge, err := stage.LookupErr("$merge")
+		if err != nil {
+			return "", "", false
+		}
+		target = merge
+		if into, ok := merge.DocumentOK(); ok {
+			target = into.Lookup("into")
+		}
+	}
+
+	if name, ok := target.StringValueOK(); ok {
+		return db, name, true
+	}
+	if doc, ok := target.DocumentOK(); ok {
+		if targetDB, ok := doc.Lookup("db").StringValueOK(); ok {
+			db = targetDB
+		}
+		name, ok := doc.Lookup("coll").StringValueOK()
+		return db, name, ok
+	}
+	return "", "", false
+}
+
+// dropOutputCollections drops the collections written by $out and $merge stages. Errors are
+// reported to stderr since the cleanup does not affect the results of the workload.
+func (r *workloadRunner) dropOutputCollections() {
+	for ns, coll := range r.outputCollections {
+		if err := coll.Drop(context.Background()); err != nil {
+			fmt.Fprintf(os.Stderr, "dropping output collection %s failed: %v\n", ns, err)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package executor
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Pacing limits how fast the operation loop runs operations, so that the impact of maintenance is
+// measured at a steady load, and small clusters are not saturated by a loop that runs as fast as it
+// can. The rate is shared by all the workers of the loop, see runWorkers.
+type Pacing struct {
+	// target operations per second across the workers; zero means as fast as possible
+	OpsPerSecond float64 `bson:"opsPerSecond"`
+	// operations that may run above the rate after the loop was held up, e.g. by an election;
+	// defaults to 1
+	Burst int `bson:"burst"`
+	// fixed wait of each worker before each of its operations
+	DelayMS int64 `bson:"delayMS"`
+	// if set with OpsPerSecond, the rate ramps up linearly from RampFromOpsPerSecond to
+	// OpsPerSecond over the first RampSeconds of the loop
+	RampSeconds          float64 `bson:"rampSeconds"`
+	RampFromOpsPerSecond float64 `bson:"rampFromOpsPerSecond"`
+}
+
+// ThroughputStats reports the rate at which the operation loop ran operations.
+type ThroughputStats struct {
+	NumOperations   int     `json:"numOperations"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	OpsPerSecond    float64 `json:"opsPerSecond"`
+	// the rate the loop was paced to at its end, if it was paced
+	TargetOpsPerSecond float64 `json:"targetOpsPerSecond,omitempty"`
+	// operations that waited for the rate or the delay, and the total time they waited
+	NumPaced int     `json:"numPaced,omitempty"`
+	PacedMS  float64 `json:"pacedMS,omitempty"`
+}
+
+// minRampOpsPerSecond keeps a ramp that starts from zero from stalling the loop.
+const minRampOpsPerSecond = 1
+
+// pacer is a token bucket that the workers of the loop take a token from before each operation.
+// Tokens are added at the rate of the pacing, and a worker that finds the bucket empty reserves the
+// next token and waits for it. Without a rate, it only counts the operations.
+type pacer struct {
+	config Pacing
+	start  time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	stats  ThroughputStats
+}
+
+// newPacer returns a pacer for a loop that starts at start. config may be nil.
+func newPacer(config *Pacing, start time.Time) *pacer {
+	p := &pacer{start: start, last: start, tokens: 1}
+	if config != nil {
+		p.config = *config
+	}
+	if p.config.Burst <= 0 {
+		p.config.Burst = 1
+	}
+	return p
+}
+
+// rate returns the target operations per second at t, or zero for no limit.
+func (p *pacer) rate(t time.Time) float64 {
+	target := p.config.OpsPerSecond
+	if target <= 0 || p.config.RampSeconds <= 0 {
+		return target
+	}
+	elapsed := t.Sub(p.start).Seconds()
+	if elapsed >= p.config.RampSeconds {
+		return target
+	}
+	from := p.config.RampFromOpsPerSecond
+	rate := from + (target-from)*elapsed/p.config.RampSeconds
+	return math.Max(rate, math.Min(minRampOpsPerSecond, target))
+}
+
+// reserve counts an operation that is about to start at now and returns how long it must wait for
+// the rate.
+func (p *pacer) reserve(now time.Time) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stats.NumOperations++
+	rate := p.rate(now)
+	if rate <= 0 {
+		return 0
+	}
+	p.tokens += now.Sub(p.last).Seconds() * rate
+	if burst := float64(p.config.Burst); p.tokens > burst {
+		p.tokens = burst
+	}
+	p.last = now
+	p.tokens--
+	if p.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-p.tokens / rate * float64(time.Second))
+}
+
+func (p *pacer) paced(wait time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stats.NumPaced++
+	p.stats.PacedMS += milliseconds(wait)
+}
+
+// summary returns the throughput of the loop, which ended at end.
+func (p *pacer) summary(end time.Time) *ThroughputStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := p.stats
+	stats.DurationSeconds = end.Sub(p.start).Seconds()
+	if stats.DurationSeconds > 0 {
+		stats.OpsPerSecond = float64(stats.NumOperations) / stats.DurationSeconds
+	}
+	stats.TargetOpsPerSecond = p.rate(end)
+	return &stats
+}
+
+// paceOperation waits before an operation of the loop for the delay and the rate of the pacing, and
+// reports whether the workload was not stopped in the meantime.
+func (r *workloadRunner) paceOperation() bool {
+	wait := r.pacer.reserve(time.Now())
+	if r.pacer.config.DelayMS > 0 {
+		wait += time.Duration(r.pacer.config.DelayMS) * time.Millisecond
+	}
+	if wait <= 0 {
+		return true
+	}
+	r.pacer.paced(wait)
+	return r.pause(wait)
+}
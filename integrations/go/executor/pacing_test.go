@@ -0,0 +1,133 @@
+package executor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPacerReserve(t *testing.T) {
+	type reservation struct {
+		// time of the reservation since the start of the loop
+		at   time.Duration
+		wait time.Duration
+	}
+	testCases := []struct {
+		name         string
+		config       *Pacing
+		reservations []reservation
+	}{
+		{
+			name:         "no pacing",
+			reservations: []reservation{{0, 0}, {0, 0}, {0, 0}},
+		},
+		{
+			name:   "operations at the same time wait for the next tokens",
+			config: &Pacing{OpsPerSecond: 10},
+			reservations: []reservation{
+				{0, 0},
+				{0, 100 * time.Millisecond},
+				{0, 200 * time.Millisecond},
+			},
+		},
+		{
+			name:   "a reserved token is not refilled twice",
+			config: &Pacing{OpsPerSecond: 10},
+			reservations: []reservation{
+				{0, 0},
+				{0, 100 * time.Millisecond},
+				{100 * time.Millisecond, 100 * time.Millisecond},
+				{400 * time.Millisecond, 0},
+			},
+		},
+		{
+			name:   "operations at the rate do not wait",
+			config: &Pacing{OpsPerSecond: 4},
+			reservations: []reservation{
+				{0, 0},
+				{250 * time.Millisecond, 0},
+				{500 * time.Millisecond, 0},
+				{750 * time.Millisecond, 0},
+			},
+		},
+		{
+			name:   "tokens are capped by the burst",
+			config: &Pacing{OpsPerSecond: 10, Burst: 3},
+			reservations: []reservation{
+				{0, 0},
+				{time.Second, 0},
+				{time.Second, 0},
+				{time.Second, 0},
+				{time.Second, 100 * time.Millisecond},
+			},
+		},
+		{
+			name:   "ramp from zero starts at the minimum rate",
+			config: &Pacing{OpsPerSecond: 10, RampSeconds: 10},
+			reservations: []reservation{
+				{0, 0},
+				{0, time.Second},
+			},
+		},
+		{
+			name:   "ramp halfway",
+			config: &Pacing{OpsPerSecond: 10, RampSeconds: 10},
+			reservations: []reservation{
+				{5 * time.Second, 0},
+				{5 * time.Second, 200 * time.Millisecond},
+			},
+		},
+		{
+			name:   "ramp from a rate",
+			config: &Pacing{OpsPerSecond: 10, RampSeconds: 10, RampFromOpsPerSecond: 2},
+			reservations: []reservation{
+				{0, 0},
+				{0, 500 * time.Millisecond},
+			},
+		},
+		{
+			name:   "after the ramp",
+			config: &Pacing{OpsPerSecond: 10, RampSeconds: 10},
+			reservations: []reservation{
+				{20 * time.Second, 0},
+				{20 * time.Second, 100 * time.Millisecond},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Unix(1600000000, 0)
+			p := newPacer(tc.config, start)
+			for i, r := range tc.reservations {
+				if wait := p.reserve(start.Add(r.at)); wait != r.wait {
+					t.Fatalf("reservation %d at %v: expected to wait %v, got %v", i, r.at, r.wait, wait)
+				}
+			}
+			if n := p.summary(start).NumOperations; n != len(tc.reservations) {
+				t.Fatalf("expected %d operations, got %d", len(tc.reservations), n)
+			}
+		})
+	}
+}
+
+func TestPacerSummary(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	p := newPacer(&Pacing{OpsPerSecond: 10, RampSeconds: 10}, start)
+	for i := 0; i < 20; i++ {
+		p.reserve(start.Add(time.Duration(i) * 100 * time.Millisecond))
+	}
+	p.paced(150 * time.Millisecond)
+	p.paced(50 * time.Millisecond)
+
+	expected := ThroughputStats{
+		NumOperations:      20,
+		DurationSeconds:    4,
+		OpsPerSecond:       5,
+		TargetOpsPerSecond: 4,
+		NumPaced:           2,
+		PacedMS:            200,
+	}
+	if stats := p.summary(start.Add(4 * time.Second)); *stats != expected {
+		t.Fatalf("expected %+v, got %+v", expected, *stats)
+	}
+}
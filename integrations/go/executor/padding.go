@@ -0,0 +1,81 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Documents near the 16MB BSON limit, and batches of them near the 48MB message limit, are built
+// by padding small documents from the workload. This keeps workloads that exercise the driver's
+// splitting of large batches readable, since the padding never appears in the workload itself.
+
+// paddingField is the field added to documents to bring them to a requested size.
+const paddingField = "padding"
+
+// sizes in bytes of the BSON elements added to documents
+const (
+	// type, key "padding", length, terminating NUL
+	paddingElementOverhead = 1 + len(paddingField) + 1 + 4 + 1
+	// type, key "_id", ObjectID
+	objectIDElementSize = 1 + 4 + 12
+)
+
+// padDocument returns doc with a string field appended so that its BSON size, including the _id
+// the driver adds to documents without one, is size bytes. Documents that are already at least
+// that large are returned unchanged.
+func padDocument(doc bson.Raw, size int) (bson.Raw, error) {
+	target := size
+	if _, err := doc.LookupErr("_id"); err != nil {
+		target -= objectIDElementSize
+	}
+	n := target - len(doc) - paddingElementOverhead
+	if n <= 0 {
+		return doc, nil
+	}
+
+	var d bson.D
+	if err := bson.Unmarshal(doc, &d); err != nil {
+		return nil, err
+	}
+	d = append(d, bson.E{Key: paddingField, Value: strings.Repeat("x", n)})
+	return bson.Marshal(d)
+}
+
+// padUpdate returns update changed to also set a string field of n bytes. An update document gets
+// the field added to its $set, or a $set if it has none; an update pipeline gets a $set stage.
+func padUpdate(update interface{}, n int) (interface{}, error) {
+	padding := bson.D{{Key: paddingField, Value: strings.Repeat("x", n)}}
+
+	switch update := update.(type) {
+	case []bson.Raw:
+		stage, err := bson.Marshal(bson.D{{Key: "$set", Value: padding}})
+		if err != nil {
+			return nil, err
+		}
+		return append(update, stage), nil
+	case []byte:
+		return padUpdate(bson.Raw(update), n)
+	case bson.Raw:
+		var d bson.D
+		if err := bson.Unmarshal(update, &d); err != nil {
+			return nil, err
+		}
+		for i, elem := range d {
+			if elem.Key != "$set" {
+				continue
+			}
+			set, ok := elem.Value.(bson.D)
+			if !ok {
+				return nil, harnessError(errors.New("unrecognized $set in update"))
+			}
+			d[i].Value = append(set, padding...)
+			return d, nil
+		}
+		return append(d, bson.E{Key: "$set", Value: padding}), nil
+	default:
+		return nil, harnessError(fmt.Errorf("unrecognized update type: %T", update))
+	}
+}
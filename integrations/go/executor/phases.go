@@ -0,0 +1,135 @@
+package executor
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// phaseCheckInterval is how often the executor looks for a new phase marker. The phase file is
+// checked at iteration boundaries, so phases are split at the first iteration boundary after the
+// marker changed.
+const phaseCheckInterval = time.Second
+
+// PhaseMetrics are the outcomes, latencies and downtime of the operations run during a phase of
+// the test, e.g. before, during or after the maintenance of the cluster, as named by the phase
+// markers of the orchestrator, see Options.PhaseFile.
+type PhaseMetrics struct {
+	Name string `json:"name"`
+	// seconds since the Unix epoch; End is zero for the phase the workload was stopped in until
+	// the results are written
+	Start        float64 `json:"start"`
+	End          float64 `json:"end"`
+	NumSuccesses int     `json:"numSuccesses"`
+	NumErrors    int     `json:"numErrors"`
+	NumFailures  int     `json:"numFailures"`
+	// time during which every operation errored, from the first error after a success until the
+	// next success or the end of the phase
+	DowntimeMS float64                      `json:"downtimeMS"`
+	Latency    map[string]*OperationLatency `json:"latency,omitempty"`
+}
+
+// phaseMarker is the contents of the phase file.
+type phaseMarker struct {
+	Name string `json:"name"`
+}
+
+// phaseTracker splits the outcomes of the operation loop by the phase named in the phase file.
+type phaseTracker struct {
+	path      string
+	lastCheck time.Time
+	modTime   time.Time
+
+	// nil until the phase file names a phase
+	current   *PhaseMetrics
+	latency   *latencyTracker
+	downSince time.Time
+}
+
+// updatePhase starts a new phase if the phase file names a different phase than the current one.
+// Outcomes before the first marker are not attributed to any phase.
+func (r *workloadRunner) updatePhase() {
+	t := r.phases
+	if t == nil || time.Since(t.lastCheck) < phaseCheckInterval {
+		return
+	}
+	t.lastCheck = time.Now()
+
+	info, err := os.Stat(t.path)
+	if err != nil || info.ModTime().Equal(t.modTime) {
+		return
+	}
+	data, err := ioutil.ReadFile(t.path)
+	if err != nil {
+		return
+	}
+	var marker phaseMarker
+	// a marker being rewritten may be read partially; it is read again at the next check
+	if err = json.Unmarshal(data, &marker); err != nil || marker.Name == "" {
+		return
+	}
+	t.modTime = info.ModTime()
+	if t.current != nil && t.current.Name == marker.Name {
+		return
+	}
+
+	now := time.Now()
+	r.endPhase(now)
+	t.current = &PhaseMetrics{
+		Name:  marker.Name,
+		Start: float64(now.UnixNano()) / float64(time.Second),
+	}
+	t.latency = newLatencyTracker()
+	if !t.downSince.IsZero() {
+		t.downSince = now
+	}
+	r.results.Phases = append(r.results.Phases, t.current)
+}
+
+// recordPhaseResult counts the outcome and the latencies of an operation towards the current
+// phase.
+func (r *workloadRunner) recordPhaseResult(op *operation, pass bool, err error, selection, command float64) {
+	t := r.phases
+	if t == nil {
+		return
+	}
+	_, isFailure := err.(*failure)
+	errored := err != nil && !isFailure
+	if errored && t.downSince.IsZero() {
+		t.downSince = time.Now()
+	} else if !errored && !t.downSince.IsZero() {
+		if t.current != nil {
+			t.current.DowntimeMS += milliseconds(time.Since(t.downSince))
+		}
+		t.downSince = time.Time{}
+	}
+
+	phase := t.current
+	if phase == nil {
+		return
+	}
+	switch {
+	case errored:
+		phase.NumErrors++
+	case err == nil && pass:
+		phase.NumSuccesses++
+	default:
+		phase.NumFailures++
+	}
+	t.latency.record(op.latencyKey(), selection, command)
+}
+
+// endPhase closes the current phase, if there is one, at the given time.
+func (r *workloadRunner) endPhase(at time.Time) {
+	t := r.phases
+	if t == nil || t.current == nil {
+		return
+	}
+	if !t.downSince.IsZero() {
+		t.current.DowntimeMS += milliseconds(at.Sub(t.downSince))
+	}
+	t.current.End = float64(at.UnixNano()) / float64(time.Second)
+	t.current.Latency = t.latency.summary()
+	t.current = nil
+}
@@ -0,0 +1,209 @@
+package executor
+
+import (
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// pinningTracker checks that the driver keeps connections, cursors and transactions pinned to a
+// single service when connected in load-balanced mode, e.g. to a serverless instance. Every command
+// of a transaction and every getMore or killCursors of a cursor must be sent to the service that
+// ran the first command, and every command sent over a connection must report the service the
+// connection was established to. Events without a service ID, i.e. those of a client not in
+// load-balanced mode, are ignored.
+type pinningTracker struct {
+	mu sync.Mutex
+	// service of each open connection, keyed by the connection ID of command events
+	connections map[string]primitive.ObjectID
+	// service each transaction is pinned to, keyed by transactionKey
+	transactions map[string]primitive.ObjectID
+	// service each open cursor is pinned to, keyed by cursor ID
+	cursors map[int64]primitive.ObjectID
+	// commands that have started but not finished, keyed by request ID
+	pending map[int64]pendingCommand
+	// violations not yet recorded as failures
+	violations []string
+}
+
+type pendingCommand struct {
+	name        string
+	service     primitive.ObjectID
+	transaction string
+	// the cursor a getMore continues
+	cursorID int64
+}
+
+func newPinningTracker() *pinningTracker {
+	return &pinningTracker{
+		connections:  make(map[string]primitive.ObjectID),
+		transactions: make(map[string]primitive.ObjectID),
+		cursors:      make(map[int64]primitive.ObjectID),
+		pending:      make(map[int64]pendingCommand),
+	}
+}
+
+// poolMonitor returns a monitor that records the service of each connection the driver opens and
+// the pool events for sdam-events.json.
+func (r *workloadRunner) poolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(evt *event.PoolEvent) {
+			r.pinning.poolEvent(evt)
+			r.sdam.poolEvent(evt)
+		},
+	}
+}
+
+func (t *pinningTracker) poolEvent(evt *event.PoolEvent) {
+	if evt.ServiceID == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	connID := fmt.Sprintf("%s[-%d]", evt.Address, evt.ConnectionID)
+	switch evt.Type {
+	case event.ConnectionReady:
+		t.connections[connID] = *evt.ServiceID
+	case event.ConnectionClosed:
+		delete(t.connections, connID)
+	}
+}
+
+func (t *pinningTracker) commandStarted(evt *event.CommandStartedEvent) {
+	if evt.ServiceID == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	service := *evt.ServiceID
+	if connService, ok := t.connections[evt.ConnectionID]; ok && connService != service {
+		t.violate("%s on connection %s reported service %s, but the connection was established to service %s",
+			evt.CommandName, evt.ConnectionID, service.Hex(), connService.Hex())
+	}
+
+	pending := pendingCommand{name: evt.CommandName, service: service}
+	if key, ok := transactionKey(evt); ok {
+		pending.transaction = key
+		if pinned, ok := t.transactions[key]; !ok {
+			t.transactions[key] = service
+		} else if pinned != service {
+			t.violate("%s of transaction %s was sent to service %s, but the transaction is pinned to service %s",
+				evt.CommandName, key, service.Hex(), pinned.Hex())
+		}
+	}
+
+	switch evt.CommandName {
+	case "getMore":
+		pending.cursorID = evt.Command.Lookup("getMore").AsInt64()
+		t.checkCursor(evt.CommandName, pending.cursorID, service)
+	case "killCursors":
+		ids, _ := evt.Command.Lookup("cursors").Array().Values()
+		for _, id := range ids {
+			cursorID := id.AsInt64()
+			t.checkCursor(evt.CommandName, cursorID, service)
+			delete(t.cursors, cursorID)
+		}
+	}
+	t.pending[evt.RequestID] = pending
+}
+
+func (t *pinningTracker) commandSucceeded(evt *event.CommandSucceededEvent) {
+	if evt.ServiceID == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pending, ok := t.pending[evt.RequestID]
+	if !ok {
+		return
+	}
+	delete(t.pending, evt.RequestID)
+
+	switch pending.name {
+	case "commitTransaction", "abortTransaction":
+		delete(t.transactions, pending.transaction)
+	}
+	// the reply reports cursor ID 0 once the cursor is exhausted
+	cursorID, ok := evt.Reply.Lookup("cursor", "id").AsInt64OK()
+	switch {
+	case !ok:
+	case cursorID == 0:
+		delete(t.cursors, pending.cursorID)
+	default:
+		if _, tracked := t.cursors[cursorID]; !tracked {
+			t.cursors[cursorID] = pending.service
+		}
+	}
+}
+
+// commandFailed stops tracking the transaction of a failed command, since the driver unpins a
+// transaction after errors that may have been caused by the service becoming unavailable and the
+// transaction is then retried or aborted on any service.
+func (t *pinningTracker) commandFailed(evt *event.CommandFailedEvent) {
+	if evt.ServiceID == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pending, ok := t.pending[evt.RequestID]
+	if !ok {
+		return
+	}
+	delete(t.pending, evt.RequestID)
+	if pending.transaction != "" {
+		delete(t.transactions, pending.transaction)
+	}
+}
+
+func (t *pinningTracker) checkCursor(command string, cursorID int64, service primitive.ObjectID) {
+	if pinned, ok := t.cursors[cursorID]; ok && pinned != service {
+		t.violate("%s of cursor %d was sent to service %s, but the cursor is pinned to service %s",
+			command, cursorID, service.Hex(), pinned.Hex())
+	}
+}
+
+func (t *pinningTracker) violate(format string, args ...interface{}) {
+	t.violations = append(t.violations, "pinning violation: "+fmt.Sprintf(format, args...))
+}
+
+// recordPinningViolations records the pinning violations found since it was last called as
+// failures and reports whether there were any.
+func (r *workloadRunner) recordPinningViolations() bool {
+	violations := r.pinning.takeViolations()
+	for _, msg := range violations {
+		r.results.PinningViolations++
+		r.recordFailure(msg)
+	}
+	return len(violations) > 0
+}
+
+// takeViolations returns the violations found since it was last called.
+func (t *pinningTracker) takeViolations() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	violations := t.violations
+	t.violations = nil
+	return violations
+}
+
+// transactionKey identifies the transaction a command belongs to by its session ID and
+// transaction number. Commands outside a transaction have no key.
+func transactionKey(evt *event.CommandStartedEvent) (string, bool) {
+	txnNumber, ok := evt.Command.Lookup("txnNumber").AsInt64OK()
+	if !ok {
+		return "", false
+	}
+	if _, err := evt.Command.LookupErr("autocommit"); err != nil {
+		// a retryable write outside a transaction
+		return "", false
+	}
+	lsid := evt.Command.Lookup("lsid", "id")
+	return fmt.Sprintf("%s/%d", lsid.String(), txnNumber), true
+}
@@ -0,0 +1,156 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Progress is a snapshot of the counters of a running workload, taken at an iteration boundary. It
+// lets an observer follow a run, and keeps the counts of a run whose executor is killed before it
+// writes its results.
+type Progress struct {
+	NumErrors     int `json:"numErrors"`
+	NumFailures   int `json:"numFailures"`
+	NumSuccesses  int `json:"numSuccesses"`
+	NumIterations int `json:"numIterations"`
+	// name of the phase of the test, if the executor was given a phase file
+	Phase string `json:"phase,omitempty"`
+	// when the snapshot was taken, in seconds since the Unix epoch
+	UpdatedAt float64 `json:"updatedAt"`
+	// when the snapshot was written, in seconds since the Unix epoch. It keeps advancing while an
+	// operation holds up the loop, so a growing gap to UpdatedAt means the loop is stuck.
+	WrittenAt float64 `json:"writtenAt"`
+}
+
+// ProgressSink is implemented by sinks that report the progress of the workload while it runs.
+// WriteProgress is called whenever records are flushed, see Options.FlushInterval.
+type ProgressSink interface {
+	Sink
+	WriteProgress(progress *Progress) error
+}
+
+// progressSnapshot holds the Progress published by the operation loop, which the progress reporter
+// reads from its own goroutine, and the counters of a worker other than the first, which the first
+// worker adds to its checkpoints.
+type progressSnapshot struct {
+	mu       sync.Mutex
+	progress Progress
+
+	numAppRetries int
+	operations    []OperationStats
+}
+
+// publishProgress takes a snapshot of the counters after iterations iterations of the loop.
+func (r *workloadRunner) publishProgress(iterations int) {
+	progress := Progress{
+		NumErrors:     r.results.NumErrors,
+		NumFailures:   r.results.NumFailures,
+		NumSuccesses:  r.results.NumSuccesses,
+		NumIterations: iterations,
+		UpdatedAt:     now(),
+	}
+	if r.phases != nil && r.phases.current != nil {
+		progress.Phase = r.phases.current.Name
+	}
+	// only the workers other than the first, which have a reloaded channel, are checkpointed by
+	// another worker
+	var operations []OperationStats
+	if r.reloaded != nil {
+		operations = make([]OperationStats, 0, len(r.results.Operations))
+		for _, stats := range r.results.Operations {
+			operations = append(operations, *stats)
+		}
+	}
+
+	r.snapshot.mu.Lock()
+	defer r.snapshot.mu.Unlock()
+	r.snapshot.progress = progress
+	r.snapshot.numAppRetries = r.results.NumAppRetries
+	r.snapshot.operations = operations
+}
+
+// latestProgress returns the last snapshot published by the loop, with the counters of the last
+// snapshots of the other workers added.
+func (r *workloadRunner) latestProgress() Progress {
+	progress := r.ownProgress()
+	for _, w := range r.workers {
+		other := w.ownProgress()
+		progress.NumErrors += other.NumErrors
+		progress.NumFailures += other.NumFailures
+		progress.NumSuccesses += other.NumSuccesses
+		progress.NumIterations += other.NumIterations
+	}
+	return progress
+}
+
+// ownProgress returns the last snapshot published by the loop of r alone.
+func (r *workloadRunner) ownProgress() Progress {
+	r.snapshot.mu.Lock()
+	defer r.snapshot.mu.Unlock()
+	return r.snapshot.progress
+}
+
+// maybeFlush flushes the records and reports the progress of the workload if the last flush is
+// older than the flush interval. It is called at iteration boundaries and by reportProgress, which
+// keeps flushing while an operation that does not return holds up the loop.
+func (r *workloadRunner) maybeFlush() {
+	r.flushMu.Lock()
+	defer r.flushMu.Unlock()
+
+	if r.flushInterval <= 0 || time.Since(r.lastFlush) < r.flushInterval {
+		return
+	}
+	r.flushRecords()
+
+	progress := r.latestProgress()
+	progress.WrittenAt = now()
+	for _, sink := range r.sinks {
+		if ps, ok := sink.(ProgressSink); ok {
+			if err := ps.WriteProgress(&progress); err != nil {
+				fmt.Fprintf(os.Stderr, "reporting progress failed: %v\n", err)
+			}
+		}
+	}
+}
+
+// reportProgress calls maybeFlush once per flush interval until ctx is done.
+func (r *workloadRunner) reportProgress(ctx context.Context) {
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.maybeFlush()
+		}
+	}
+}
+
+// progressFileSink writes the progress of the workload to a file.
+type progressFileSink struct {
+	path string
+}
+
+// ProgressFileSink returns a Sink that replaces the file at path with the latest Progress of the
+// workload at every flush, see Options.FlushInterval. The file is replaced atomically, so a reader
+// always sees a complete snapshot, and it is left in place when the workload finishes.
+func ProgressFileSink(path string) Sink {
+	return progressFileSink{path: path}
+}
+
+func (s progressFileSink) WriteResults(*Results) error {
+	return nil
+}
+
+func (s progressFileSink) WriteProgress(progress *Progress) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return fmt.Errorf("marshal progress failed: %v", err)
+	}
+	return writeFileAtomically(s.path, data)
+}
@@ -0,0 +1,126 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Operations are dispatched through two registries that are populated at build time from init
+// functions. Teams that need operations the executor does not provide (for example, calls to an
+// application service) can add them without modifying the executor by dropping a file next to
+// workload-executor.go that registers them:
+//
+//	// +build realm
+//
+//	package main
+//
+//	import "go-executor/executor"
+//
+//	func init() {
+//		executor.RegisterObjectType("realmApp", executeRealmOperation)
+//	}
+//
+// Guarding such files with a build tag keeps them out of the default build; install-driver.sh
+// passes the tags listed in GO_BUILD_TAGS to go build.
+
+// Operation is an operation of the workload passed to the handlers registered with
+// RegisterCollectionOperation and RegisterObjectType.
+type Operation struct {
+	Object    string
+	Name      string
+	Arguments bson.Raw
+	// expected result of the operation, nil if the workload does not declare one
+	Result interface{}
+	// joins the records and commands of the operation to the server logs
+	Label string
+
+	verifier verifier
+}
+
+// DocumentsMatch reports whether actual satisfies the expected document according to the verifier
+// of the workload.
+func (op *Operation) DocumentsMatch(expected, actual bson.Raw) bool {
+	return op.verifier.documentsMatch(expected, actual)
+}
+
+// CountsMatch reports whether actual satisfies the expected count according to the verifier of the
+// workload.
+func (op *Operation) CountsMatch(expected, actual int64) bool {
+	return op.verifier.countsMatch(expected, actual)
+}
+
+// CollectionOperationHandler executes op against coll and reports whether the result matched the
+// expected result. ctx carries the label of the operation and, for operations run inside a
+// transaction, the transaction's session.
+type CollectionOperationHandler func(ctx context.Context, coll *mongo.Collection, op *Operation) (bool, error)
+
+// ObjectOperationHandler executes op against an object type that is not a collection. client is
+// the client of the workload.
+type ObjectOperationHandler func(ctx context.Context, client *mongo.Client, op *Operation) (bool, error)
+
+// RegisterCollectionOperation makes the named operation available on collection objects. It must
+// be called from an init function and panics if an operation with the same name has already been
+// registered. Workload validation does not check the arguments of registered operations.
+func RegisterCollectionOperation(name string, handler CollectionOperationHandler) {
+	registerCollectionOperation(name, func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		return handler(ctx, coll, r.exportOperation(op))
+	})
+}
+
+// RegisterObjectType makes operations on the named object type available to workloads. It must be
+// called from an init function and panics if an object type with the same name has already been
+// registered.
+func RegisterObjectType(name string, handler ObjectOperationHandler) {
+	registerObjectType(name, func(r *workloadRunner, op *operation) (bool, error) {
+		return handler(withOperationLabel(context.Background(), op.Label), r.client, r.exportOperation(op))
+	})
+}
+
+// exportOperation returns op as passed to registered handlers.
+func (r *workloadRunner) exportOperation(op *operation) *Operation {
+	return &Operation{
+		Object:    op.Object,
+		Name:      op.Name,
+		Arguments: op.Arguments,
+		Result:    op.Result,
+		Label:     op.Label,
+		verifier:  r.verifier,
+	}
+}
+
+// collectionOperationFunc executes op against coll and reports whether the result matched the
+// expected result according to r.verifier. Operations run inside a transaction receive a context
+// carrying the transaction's session.
+type collectionOperationFunc func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error)
+
+// objectOperationFunc executes op against an object type that is not a collection.
+type objectOperationFunc func(r *workloadRunner, op *operation) (bool, error)
+
+// collectionOperations maps operation names to the functions that execute them against the
+// workload collection or a named collection entity.
+var collectionOperations = make(map[string]collectionOperationFunc)
+
+// objectTypes maps the object names operations can target to the functions that execute them.
+var objectTypes = make(map[string]objectOperationFunc)
+
+// registerCollectionOperation is like RegisterCollectionOperation for the operations of the
+// executor, which have access to its runner.
+func registerCollectionOperation(name string, fn collectionOperationFunc) {
+	if _, ok := collectionOperations[name]; ok {
+		str := fmt.Sprintf("collection operation %q registered twice", name)
+		panic(str)
+	}
+	collectionOperations[name] = fn
+}
+
+// registerObjectType is like RegisterObjectType for the object types of the executor.
+func registerObjectType(name string, fn objectOperationFunc) {
+	if _, ok := objectTypes[name]; ok {
+		str := fmt.Sprintf("object type %q registered twice", name)
+		panic(str)
+	}
+	objectTypes[name] = fn
+}
@@ -0,0 +1,57 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// registeredCall is a call of the handler registered for TestRegisterCollectionOperation.
+type registeredCall struct {
+	label string
+	op    *Operation
+}
+
+var registeredCalls = make(chan registeredCall, 1)
+
+func init() {
+	RegisterCollectionOperation("registeredForTest", func(ctx context.Context, coll *mongo.Collection, op *Operation) (bool, error) {
+		registeredCalls <- registeredCall{label: operationLabel(ctx), op: op}
+		return op.DocumentsMatch(op.Result.(bson.Raw), op.Arguments), nil
+	})
+}
+
+func TestRegisterCollectionOperation(t *testing.T) {
+	doc, err := bson.Marshal(bson.D{{Key: "x", Value: 1}})
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	r := &workloadRunner{verifier: defaultVerifier{}}
+	op := &operation{Object: "collection", Name: "registeredForTest", Arguments: doc, Result: bson.Raw(doc), Label: "custom"}
+
+	pass, err := r.executeCollectionOperation(context.Background(), nil, op)
+	if err != nil || !pass {
+		t.Fatalf("expected the operation to pass, got %v, %v", pass, err)
+	}
+	call := <-registeredCalls
+	if call.label != op.Label {
+		t.Fatalf("expected the label %q in the context, got %q", op.Label, call.label)
+	}
+	if call.op.Name != op.Name || call.op.Label != op.Label || !bytes.Equal(call.op.Arguments, doc) {
+		t.Fatalf("expected the handler to receive %+v, got %+v", op, call.op)
+	}
+}
+
+func TestRegisterCollectionOperationTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected registering an operation twice to panic")
+		}
+	}()
+	RegisterCollectionOperation("find", func(context.Context, *mongo.Collection, *Operation) (bool, error) {
+		return true, nil
+	})
+}
@@ -0,0 +1,93 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// Transition records a workload being replaced while the executor was running.
+type Transition struct {
+	// seconds since the Unix epoch
+	Time float64 `json:"time"`
+	RecordTime
+	// number of loop iterations that had started before the workload was replaced
+	Iteration int `json:"iteration"`
+	// names of the operations in the new workload
+	Operations []string `json:"operations"`
+}
+
+// reloadWorkload replaces the operations and the outcome of the running workload with those of
+// spec, and passes the new workload on to the other workers. The remaining fields describe setup
+// that has already happened and are ignored. A spec that cannot be used is reported to stderr and
+// counted as an error, and the current workload keeps running.
+func (r *workloadRunner) reloadWorkload(workload *driverWorkload, spec []byte, iteration int) {
+	next, err := parseWorkload(spec, r.testName)
+	if err == nil {
+		err = validateWorkload(next)
+	}
+	if err == nil {
+		var reason string
+		reason, err = r.applyRequirements(context.Background(), next)
+		if err == nil && reason != "" {
+			err = fmt.Errorf("runOnRequirements not met: %s", reason)
+		}
+	}
+	if err == nil {
+		err = r.checkObjects(next.Operations)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ignoring reloaded workload: %v\n", err)
+		r.recordError(fmt.Errorf("reloading workload failed: %v", err))
+		return
+	}
+
+	r.replaceWorkload(workload, next)
+	for _, w := range r.workers {
+		// a worker that has not reached an iteration boundary since the previous reload skips it
+		select {
+		case <-w.reloaded:
+		default:
+		}
+		w.reloaded <- next
+	}
+
+	names := make([]string, 0, len(next.Operations))
+	for _, op := range next.Operations {
+		names = append(names, op.Name)
+	}
+	t, rt := stamp()
+	r.results.Transitions = append(r.results.Transitions, Transition{
+		Time:       t,
+		RecordTime: rt,
+		Iteration:  iteration,
+		Operations: names,
+	})
+	fmt.Fprintf(os.Stderr, "reloaded workload before iteration %d\n", iteration)
+}
+
+// replaceWorkload replaces the operations and the outcome of workload with those of next, which
+// has been checked by reloadWorkload.
+func (r *workloadRunner) replaceWorkload(workload *driverWorkload, next *driverWorkload) {
+	workload.Operations = next.Operations
+	workload.Outcome = next.Outcome
+	r.trackOperations(next.Operations)
+}
+
+// checkObjects returns an error if an operation uses an object that does not exist, since such an
+// operation would stop the run with a harness error.
+func (r *workloadRunner) checkObjects(operations []*operation) error {
+	for _, op := range operations {
+		if _, ok := objectTypes[op.Object]; ok {
+			continue
+		}
+		if _, ok := r.collections[op.Object]; ok {
+			continue
+		}
+		if _, ok := r.clientEncryptions[op.Object]; ok {
+			continue
+		}
+		return fmt.Errorf("unrecognized object: %v", op.Object)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// replaySkippedCommands are the commands Replay does not run: those the driver runs on its own to
+// connect and authenticate, getMore and killCursors whose cursors do not exist on the new cluster,
+// and those that end transactions, whose commands are replayed outside of a transaction.
+var replaySkippedCommands = map[string]bool{
+	"hello":              true,
+	"isMaster":           true,
+	"ismaster":           true,
+	"saslStart":          true,
+	"saslContinue":       true,
+	"authenticate":       true,
+	"getMore":            true,
+	"killCursors":        true,
+	"endSessions":        true,
+	"commitTransaction":  true,
+	"abortTransaction":   true,
+	"configureFailPoint": true,
+}
+
+// replayStrippedFields are the fields of captured commands that are bound to the session, the
+// transaction or the cluster time of the original run. The driver adds its own where needed.
+var replayStrippedFields = map[string]bool{
+	"lsid":             true,
+	"txnNumber":        true,
+	"startTransaction": true,
+	"autocommit":       true,
+	"recoveryToken":    true,
+	"$clusterTime":     true,
+	"$db":              true,
+	"$readPreference":  true,
+}
+
+// ReplayOptions configures Replay.
+type ReplayOptions struct {
+	// Speed scales the pacing of the original run: 2 replays the commands twice as fast. Zero
+	// selects 1.
+	Speed float64
+}
+
+// ReplayReport describes the outcome of a replay.
+type ReplayReport struct {
+	NumCommands int `json:"numCommands"`
+	NumErrors   int `json:"numErrors"`
+	// skipped commands per command name, see replaySkippedCommands
+	Skipped map[string]int `json:"skipped,omitempty"`
+	// errors per command name
+	Errors    map[string]int `json:"errors,omitempty"`
+	LastError string         `json:"lastError,omitempty"`
+	// how far behind the original pacing the slowest command was started
+	MaxLagMS   float64 `json:"maxLagMS"`
+	DurationMS float64 `json:"durationMS"`
+}
+
+// replayedCommand is a command captured in events.json.
+type replayedCommand struct {
+	name       string
+	database   string
+	command    bson.D
+	observedAt float64
+}
+
+// Replay runs the commands captured in events, the contents of the events.json of a run with
+// Options.CaptureCommands, against the cluster at uri, in their original order and with their
+// original pacing, so that the traffic that triggered a failure can be reproduced on a new cluster.
+// Commands are run one at a time; a command whose predecessor took longer than the gap between
+// them in the original run starts late, which is reported in ReplayReport.MaxLagMS. Replay stops
+// when ctx is done.
+func Replay(ctx context.Context, uri string, events []byte, opts ReplayOptions) (*ReplayReport, error) {
+	commands, err := parseReplayedCommands(events)
+	if err != nil {
+		return nil, err
+	}
+	speed := opts.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = client.Disconnect(context.Background()) }()
+
+	report := &ReplayReport{Skipped: make(map[string]int), Errors: make(map[string]int)}
+	start := time.Now()
+	for _, cmd := range commands {
+		if replaySkippedCommands[cmd.name] {
+			report.Skipped[cmd.name]++
+			continue
+		}
+		offset := time.Duration((cmd.observedAt - commands[0].observedAt) / speed * float64(time.Second))
+		if wait := time.Until(start.Add(offset)); wait > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(wait):
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if lag := milliseconds(time.Since(start.Add(offset))); lag > report.MaxLagMS {
+			report.MaxLagMS = lag
+		}
+
+		report.NumCommands++
+		// the commands are not run with ctx so that stopping the replay does not count as an error
+		if err := client.Database(cmd.database).RunCommand(context.Background(), cmd.command).Err(); err != nil {
+			report.NumErrors++
+			report.Errors[cmd.name]++
+			report.LastError = err.Error()
+		}
+	}
+	report.DurationMS = milliseconds(time.Since(start))
+	return report, nil
+}
+
+// parseReplayedCommands returns the captured commands of events in the order they were started.
+func parseReplayedCommands(events []byte) ([]*replayedCommand, error) {
+	var records Records
+	if err := json.Unmarshal(events, &records); err != nil {
+		return nil, fmt.Errorf("parsing events failed: %v", err)
+	}
+
+	var commands []*replayedCommand
+	for _, evt := range records.Events {
+		if evt.Name != "CommandStartedEvent" || len(evt.Command) == 0 {
+			continue
+		}
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON(evt.Command, true, &doc); err != nil {
+			return nil, fmt.Errorf("parsing the %s command of request %d failed: %v", evt.CommandName, evt.RequestID, err)
+		}
+		// the driver redacts security-sensitive commands to an empty document
+		if len(doc) == 0 {
+			continue
+		}
+		command := make(bson.D, 0, len(doc))
+		for _, elem := range doc {
+			if !replayStrippedFields[elem.Key] {
+				command = append(command, elem)
+			}
+		}
+		commands = append(commands, &replayedCommand{
+			name:       evt.CommandName,
+			database:   evt.DatabaseName,
+			command:    command,
+			observedAt: evt.ObservedAt,
+		})
+	}
+	// the monitors of several clients may record their events slightly out of order
+	sort.SliceStable(commands, func(i, j int) bool {
+		return commands[i].observedAt < commands[j].observedAt
+	})
+	if len(commands) == 0 {
+		return nil, errors.New("the events have no captured commands; run the workload with -capture-commands")
+	}
+	return commands, nil
+}
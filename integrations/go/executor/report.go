@@ -0,0 +1,226 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// reportTimeout bounds each write of a mongoReportSink, and how long the final results wait for
+// the intervals still queued.
+const reportTimeout = 10 * time.Second
+
+// reportQueueSize is the number of intervals a mongoReportSink queues for its writer. Intervals
+// beyond it are dropped, so that a slow or unreachable reporting deployment never holds up the
+// flushes of the operation loop.
+const reportQueueSize = 64
+
+// IntervalMetrics are the outcome counts of the operations run between two flushes, see
+// IntervalSink.
+type IntervalMetrics struct {
+	Start        time.Time
+	End          time.Time
+	NumSuccesses int
+	NumErrors    int
+	NumFailures  int
+	// name of the phase of the test at the last iteration boundary of the interval, if the executor
+	// was given a phase file
+	Phase string
+}
+
+// intervalCounts are the cumulative counts at the start of the current interval.
+type intervalCounts struct {
+	start        time.Time
+	numSuccesses int
+	numErrors    int
+	numFailures  int
+}
+
+// writeIntervals hands the metrics of the interval since the previous call to every IntervalSink.
+// It is called with flushRecords, possibly from the progress reporter, so the counts are those of
+// the last snapshot published by the loop.
+func (r *workloadRunner) writeIntervals() {
+	progress := r.latestProgress()
+	metrics := IntervalMetrics{
+		Start:        r.interval.start,
+		End:          time.Now(),
+		NumSuccesses: progress.NumSuccesses - r.interval.numSuccesses,
+		NumErrors:    progress.NumErrors - r.interval.numErrors,
+		NumFailures:  progress.NumFailures - r.interval.numFailures,
+		Phase:        progress.Phase,
+	}
+	r.interval = intervalCounts{
+		start:        metrics.End,
+		numSuccesses: progress.NumSuccesses,
+		numErrors:    progress.NumErrors,
+		numFailures:  progress.NumFailures,
+	}
+
+	for _, sink := range r.sinks {
+		if is, ok := sink.(IntervalSink); ok {
+			if err := is.WriteInterval(&metrics); err != nil {
+				fmt.Fprintf(os.Stderr, "writing interval metrics failed: %v\n", err)
+			}
+		}
+	}
+}
+
+// mongoReportSink writes the metrics of a run to a MongoDB deployment other than the cluster under
+// test, so that trends across runs can be queried and charted. Every interval is a document of the
+// "metrics" collection and the final results are a document of the "results" collection, both
+// carrying the ID of the run and the name of the test.
+type mongoReportSink struct {
+	client   *mongo.Client
+	metrics  *mongo.Collection
+	results  *mongo.Collection
+	runID    primitive.ObjectID
+	testName string
+
+	// intervals waiting for runWriter, which closes writerDone once the queue is closed and
+	// drained; stop abandons the intervals still queued
+	intervals  chan IntervalMetrics
+	writerDone chan struct{}
+	stop       context.CancelFunc
+	writerCtx  context.Context
+
+	mu sync.Mutex
+	// set once the queue is closed
+	closed bool
+	// intervals dropped because the queue was full
+	dropped int
+}
+
+// MongoReportSink returns a Sink that writes per-interval metrics and the final results to the
+// metrics and results collections of database on the deployment at uri. testName identifies the
+// scenario across runs. The sink implements IntervalSink, so intervals are written at every flush,
+// see Options.FlushInterval; they are queued for a background writer and dropped if it falls
+// behind. Errors are reported to stderr rather than returned since reporting must not fail the
+// workload.
+func MongoReportSink(uri, database, testName string) (Sink, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the reporting deployment failed: %v", err)
+	}
+	db := client.Database(database)
+	s := &mongoReportSink{
+		client:   client,
+		metrics:  db.Collection("metrics"),
+		results:  db.Collection("results"),
+		runID:    primitive.NewObjectID(),
+		testName: testName,
+
+		intervals:  make(chan IntervalMetrics, reportQueueSize),
+		writerDone: make(chan struct{}),
+	}
+	s.writerCtx, s.stop = context.WithCancel(context.Background())
+	go s.runWriter()
+	return s, nil
+}
+
+// WriteInterval queues metrics for the writer without waiting, or drops them if the queue is full.
+func (s *mongoReportSink) WriteInterval(metrics *IntervalMetrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	select {
+	case s.intervals <- *metrics:
+	default:
+		s.dropped++
+	}
+	return nil
+}
+
+// runWriter writes the queued intervals until the queue is closed and drained.
+func (s *mongoReportSink) runWriter() {
+	defer close(s.writerDone)
+	for metrics := range s.intervals {
+		if s.writerCtx.Err() != nil {
+			continue
+		}
+		if err := s.writeInterval(&metrics); err != nil {
+			fmt.Fprintf(os.Stderr, "writing interval metrics failed: %v\n", err)
+		}
+	}
+}
+
+func (s *mongoReportSink) writeInterval(metrics *IntervalMetrics) error {
+	ctx, cancel := context.WithTimeout(s.writerCtx, reportTimeout)
+	defer cancel()
+
+	_, err := s.metrics.InsertOne(ctx, bson.D{
+		{Key: "runId", Value: s.runID},
+		{Key: "testName", Value: s.testName},
+		{Key: "start", Value: metrics.Start},
+		{Key: "end", Value: metrics.End},
+		{Key: "phase", Value: metrics.Phase},
+		{Key: "numSuccesses", Value: metrics.NumSuccesses},
+		{Key: "numErrors", Value: metrics.NumErrors},
+		{Key: "numFailures", Value: metrics.NumFailures},
+	})
+	return err
+}
+
+// WriteResults waits up to reportTimeout for the queued intervals, writes the results as they
+// appear in version 1 of results.json and disconnects.
+func (s *mongoReportSink) WriteResults(results *Results) error {
+	defer func() {
+		_ = s.client.Disconnect(context.Background())
+	}()
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.intervals)
+	}
+	dropped := s.dropped
+	s.mu.Unlock()
+	select {
+	case <-s.writerDone:
+	case <-time.After(reportTimeout):
+		fmt.Fprintln(os.Stderr, "gave up on the interval metrics still queued for the reporting deployment")
+	}
+	s.stop()
+	if dropped > 0 {
+		fmt.Fprintf(os.Stderr, "dropped %d interval metrics the reporting deployment fell behind on\n", dropped)
+	}
+
+	if err := s.writeResults(results, dropped); err != nil {
+		fmt.Fprintf(os.Stderr, "reporting results failed: %v\n", err)
+	}
+	return nil
+}
+
+func (s *mongoReportSink) writeResults(results *Results, droppedIntervals int) error {
+	data, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	var doc bson.D
+	if err = bson.UnmarshalExtJSON(data, false, &doc); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+	_, err = s.results.InsertOne(ctx, bson.D{
+		{Key: "runId", Value: s.runID},
+		{Key: "testName", Value: s.testName},
+		{Key: "end", Value: time.Now()},
+		{Key: "droppedIntervals", Value: droppedIntervals},
+		{Key: "results", Value: doc},
+	})
+	return err
+}
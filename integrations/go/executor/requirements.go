@@ -0,0 +1,242 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// RunOnRequirement describes a cluster a workload or a test case can run against. Every field is
+// optional.
+type RunOnRequirement struct {
+	MinServerVersion string `bson:"minServerVersion" json:"minServerVersion,omitempty"`
+	MaxServerVersion string `bson:"maxServerVersion" json:"maxServerVersion,omitempty"`
+	// any of "single", "replicaset", "sharded" and "sharded-replicaset"
+	Topologies []string `bson:"topologies" json:"topologies,omitempty"`
+	// "require", "forbid" or "allow", the default
+	Serverless string `bson:"serverless" json:"serverless,omitempty"`
+}
+
+// legacyRunOn is an entry of the runOn block of workloads written for the legacy test format, which
+// lists topologies under "topology".
+type legacyRunOn struct {
+	MinServerVersion string `bson:"minServerVersion"`
+	MaxServerVersion string `bson:"maxServerVersion"`
+	Topology         []string
+}
+
+func (l *legacyRunOn) requirement() *RunOnRequirement {
+	return &RunOnRequirement{
+		MinServerVersion: l.MinServerVersion,
+		MaxServerVersion: l.MaxServerVersion,
+		Topologies:       l.Topology,
+	}
+}
+
+// ServerInfo describes the cluster that requirements were evaluated against.
+type ServerInfo struct {
+	Version    string `json:"version"`
+	Topology   string `json:"topology"`
+	Serverless bool   `json:"serverless"`
+}
+
+// RequirementCheck is the outcome of evaluating a single requirement.
+type RequirementCheck struct {
+	// "workload" or "test"
+	Level string `json:"level"`
+	// description of the test case the requirement belongs to, for test-level requirements
+	Test        string            `json:"test,omitempty"`
+	Requirement *RunOnRequirement `json:"requirement"`
+	Met         bool              `json:"met"`
+	Reason      string            `json:"reason,omitempty"`
+}
+
+// RequirementDiagnostics records every requirement that was evaluated and the server values it was
+// evaluated against, so that a skipped workload or test case can be explained from results.json.
+type RequirementDiagnostics struct {
+	Server    ServerInfo         `json:"server"`
+	Evaluated []RequirementCheck `json:"evaluated"`
+}
+
+// serverInfo returns the version and topology of the cluster. The values are fetched once and
+// cached for workload reloads.
+func (r *workloadRunner) serverInfo(ctx context.Context) (*ServerInfo, error) {
+	if r.server != nil {
+		return r.server, nil
+	}
+
+	var buildInfo struct {
+		Version string
+	}
+	err := r.client.Database("admin").RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}).Decode(&buildInfo)
+	if err != nil {
+		return nil, err
+	}
+	var isMaster struct {
+		SetName string `bson:"setName"`
+		Msg     string
+	}
+	err = r.client.Database("admin").RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&isMaster)
+	if err != nil {
+		return nil, err
+	}
+	topology := "single"
+	switch {
+	case isMaster.Msg == "isdbgrid":
+		topology = "sharded"
+	case isMaster.SetName != "":
+		topology = "replicaset"
+	}
+
+	r.server = &ServerInfo{Version: buildInfo.Version, Topology: topology, Serverless: r.serverless}
+	return r.server, nil
+}
+
+// applyRequirements evaluates the requirements of the workload and of its test cases, records the
+// evaluation in the results and merges the test cases whose requirements are met into the
+// workload. It returns a description of why the workload must be skipped, or an empty string if it
+// can run.
+func (r *workloadRunner) applyRequirements(ctx context.Context, w *driverWorkload) (string, error) {
+	hasRequirements := len(w.RunOnRequirements) > 0
+	for _, test := range w.Tests {
+		hasRequirements = hasRequirements || len(test.RunOnRequirements) > 0
+	}
+	if !hasRequirements {
+		w.mergeTests(w.Tests)
+		return "", nil
+	}
+
+	server, err := r.serverInfo(ctx)
+	if err != nil {
+		return "", err
+	}
+	diag := &RequirementDiagnostics{Server: *server}
+	r.results.Requirements = diag
+
+	if reason := diag.evaluate("workload", "", w.RunOnRequirements); reason != "" {
+		return reason, nil
+	}
+
+	var tests []*testCase
+	var reasons []string
+	for _, test := range w.Tests {
+		reason := diag.evaluate("test", test.Description, test.RunOnRequirements)
+		if reason == "" {
+			tests = append(tests, test)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "skipping test %q: %s\n", test.Description, reason)
+		reasons = append(reasons, fmt.Sprintf("test %q: %s", test.Description, reason))
+	}
+	if len(w.Tests) > 0 && len(tests) == 0 && len(w.Operations) == 0 {
+		return strings.Join(reasons, "; "), nil
+	}
+	w.mergeTests(tests)
+	return "", nil
+}
+
+// evaluate records a check for each of the requirements and returns a description of why none of
+// them is met, or an empty string if one of them is met or there are none.
+func (d *RequirementDiagnostics) evaluate(level, test string, requirements []*RunOnRequirement) string {
+	met := len(requirements) == 0
+	var reasons []string
+	for _, req := range requirements {
+		reason := req.unmetBy(&d.Server)
+		d.Evaluated = append(d.Evaluated, RequirementCheck{
+			Level:       level,
+			Test:        test,
+			Requirement: req,
+			Met:         reason == "",
+			Reason:      reason,
+		})
+		if reason == "" {
+			met = true
+		} else {
+			reasons = append(reasons, reason)
+		}
+	}
+	if met {
+		return ""
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// unmetBy returns why the server does not meet the requirement, or an empty string if it does.
+func (req *RunOnRequirement) unmetBy(server *ServerInfo) string {
+	version, topology := server.Version, server.Topology
+	if req.MinServerVersion != "" && compareVersions(version, req.MinServerVersion) < 0 {
+		return fmt.Sprintf("server version %s is older than minServerVersion %s", version, req.MinServerVersion)
+	}
+	if req.MaxServerVersion != "" && compareVersions(version, req.MaxServerVersion) > 0 {
+		return fmt.Sprintf("server version %s is newer than maxServerVersion %s", version, req.MaxServerVersion)
+	}
+	switch {
+	case req.Serverless == "require" && !server.Serverless:
+		return "the cluster is not serverless but serverless is required"
+	case req.Serverless == "forbid" && server.Serverless:
+		return "the cluster is serverless but serverless is forbidden"
+	}
+	if len(req.Topologies) == 0 {
+		return ""
+	}
+	for _, t := range req.Topologies {
+		// a sharded cluster is reported as "sharded" whether or not its shards are replica sets
+		if t == topology || (topology == "sharded" && t == "sharded-replicaset") {
+			return ""
+		}
+	}
+	return fmt.Sprintf("topology %s is not one of %v", topology, req.Topologies)
+}
+
+// compareVersions compares two dotted version strings numerically, considering only as many
+// components as the shorter of the two has, so that "4.4.1" satisfies a maxServerVersion of "4.4".
+// Components that are not numbers, such as "0-rc1", compare by their leading digits.
+func compareVersions(a, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aNum, bNum := versionComponent(aParts[i]), versionComponent(bParts[i])
+		switch {
+		case aNum < bNum:
+			return -1
+		case aNum > bNum:
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionComponent(part string) int {
+	end := 0
+	for end < len(part) && part[end] >= '0' && part[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(part[:end])
+	return n
+}
+
+// skip marks the workload as skipped. It waits until ctx is done, as a workload would, so that
+// the orchestrator observes the same lifecycle for skipped and executed workloads.
+func (r *workloadRunner) skip(ctx context.Context, reason string) (*Results, error) {
+	fmt.Fprintf(os.Stderr, "skipping workload: %s\n", reason)
+	r.results.Skipped = true
+	r.results.SkipReason = reason
+	r.notifyReady()
+
+	// keep the heartbeat going so that a watchdog does not mistake the wait for a stall
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for ctx.Err() == nil {
+		r.maybeHeartbeat(0)
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+	return &r.results, nil
+}
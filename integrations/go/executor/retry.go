@@ -0,0 +1,58 @@
+package executor
+
+import (
+	"time"
+)
+
+// retryPolicy makes the executor retry an operation that errored, the way an application that
+// wraps driver calls in a retry loop of its own would. These retries are on top of any retryable
+// reads and writes of the driver and are counted separately from them, as app retries.
+type retryPolicy struct {
+	// attempts including the first; defaults to 3
+	MaxAttempts int `bson:"maxAttempts"`
+	// wait before the first retry, doubled before each further retry; defaults to 100
+	BackoffMS int64 `bson:"backoffMS"`
+	// upper bound of the wait between retries; defaults to 5000
+	MaxBackoffMS int64 `bson:"maxBackoffMS"`
+}
+
+// runOperationWithRetries runs op, retrying it according to its retry policy while it errors. Only
+// the outcome of the last attempt is returned; results that did not match are not retried.
+func (r *workloadRunner) runOperationWithRetries(op *operation) (bool, error) {
+	pass, err := r.runOperation(op)
+	policy := op.RetryPolicy
+	if policy == nil {
+		return pass, err
+	}
+
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+	backoff := 100 * time.Millisecond
+	if policy.BackoffMS > 0 {
+		backoff = time.Duration(policy.BackoffMS) * time.Millisecond
+	}
+	maxBackoff := 5 * time.Second
+	if policy.MaxBackoffMS > 0 {
+		maxBackoff = time.Duration(policy.MaxBackoffMS) * time.Millisecond
+	}
+
+	for attempt := 1; attempt < maxAttempts && err != nil; attempt++ {
+		if _, isFailure := err.(*failure); isFailure || errorCategory(err) == ErrorCategoryHarness {
+			break
+		}
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		if !r.pause(backoff) {
+			break
+		}
+		backoff *= 2
+
+		r.results.NumAppRetries++
+		r.opStats[op.Name].NumAppRetries++
+		pass, err = r.runOperation(op)
+	}
+	return pass, err
+}
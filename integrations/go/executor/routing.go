@@ -0,0 +1,131 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/description"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// RoutingStats reports where the driver sent the reads of operations with a tagged read
+// preference, e.g. aggregations routed to analytics nodes with {nodeType: ANALYTICS}. A read sent
+// to a server the read preference does not allow is a violation, which fails the operation.
+type RoutingStats struct {
+	NumChecked    int `json:"numChecked"`
+	NumViolations int `json:"numViolations"`
+	// number of checked reads per server address
+	Servers map[string]int `json:"servers"`
+}
+
+// readPreferenceKey is the context key of the read preference of an operation, see
+// withRoutedReadPreference.
+type readPreferenceKey struct{}
+
+// withRoutedReadPreference returns a context carrying rp, so that the command monitor can check
+// the server the commands of the operation are sent to.
+func withRoutedReadPreference(ctx context.Context, rp *readpref.ReadPref) context.Context {
+	if len(rp.TagSets()) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, readPreferenceKey{}, rp)
+}
+
+// routingTracker checks the servers reads with a tagged read preference are sent to against the
+// tags of the servers, which it learns from SDAM events. Reads sent to mongos, which applies the
+// read preference itself, and to servers whose description is not known yet are not checked.
+type routingTracker struct {
+	mu      sync.Mutex
+	servers map[string]description.Server
+	stats   RoutingStats
+	// violations not yet recorded as failures
+	violations []string
+}
+
+func newRoutingTracker() *routingTracker {
+	return &routingTracker{
+		servers: make(map[string]description.Server),
+		stats:   RoutingStats{Servers: make(map[string]int)},
+	}
+}
+
+func (t *routingTracker) serverChanged(evt *event.ServerDescriptionChangedEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.servers[evt.Address.String()] = evt.NewDescription
+}
+
+func (t *routingTracker) commandStarted(ctx context.Context, evt *event.CommandStartedEvent, address string) {
+	rp, ok := ctx.Value(readPreferenceKey{}).(*readpref.ReadPref)
+	if !ok {
+		return
+	}
+	switch evt.CommandName {
+	case "find", "aggregate", "count", "distinct":
+	default:
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	server, ok := t.servers[address]
+	if !ok || (server.Kind != description.RSPrimary && server.Kind != description.RSSecondary) {
+		return
+	}
+	t.stats.NumChecked++
+	t.stats.Servers[address]++
+	if !eligible(server, rp) {
+		t.stats.NumViolations++
+		t.violations = append(t.violations, fmt.Sprintf(
+			"routing violation: %s was sent to %s with tags %v, which read preference mode %v with tag sets %v does not allow",
+			evt.CommandName, address, server.Tags, rp.Mode(), rp.TagSets()))
+	}
+}
+
+// eligible reports whether rp allows reads from server, a replica set member. Secondaries must
+// match one of the tag sets, while the primary is allowed by the modes that fall back to it or
+// prefer it, and by nearest if it matches a tag set.
+func eligible(server description.Server, rp *readpref.ReadPref) bool {
+	if server.Kind == description.RSPrimary {
+		switch rp.Mode() {
+		case readpref.PrimaryMode, readpref.PrimaryPreferredMode, readpref.SecondaryPreferredMode:
+			return true
+		case readpref.SecondaryMode:
+			return false
+		}
+	}
+	for _, set := range rp.TagSets() {
+		if server.Tags.ContainsAll(set) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordRoutingViolations records the routing violations found since it was last called as
+// failures and reports whether there were any.
+func (r *workloadRunner) recordRoutingViolations() bool {
+	r.routing.mu.Lock()
+	violations := r.routing.violations
+	r.routing.violations = nil
+	r.routing.mu.Unlock()
+
+	for _, msg := range violations {
+		r.recordFailure(msg)
+	}
+	return len(violations) > 0
+}
+
+// summary returns the routing statistics, or nil if no read was checked.
+func (t *routingTracker) summary() *RoutingStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.stats.NumChecked == 0 {
+		return nil
+	}
+	stats := t.stats
+	return &stats
+}
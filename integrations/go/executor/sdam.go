@@ -0,0 +1,217 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/description"
+)
+
+// maxSDAMEvents caps the SDAM and pool events kept in memory. Events beyond it are counted in
+// FailoverStats.DroppedEvents instead.
+const maxSDAMEvents = 100000
+
+// SDAMEvent is a server, topology or connection pool event of a client of the workload. Connection
+// check outs and check ins, which happen for every operation, are not recorded.
+type SDAMEvent struct {
+	// the name of the event, e.g. "ServerDescriptionChangedEvent", or the type of a pool event,
+	// e.g. "ConnectionPoolCleared"
+	Name string `json:"name"`
+	// seconds since the Unix epoch
+	ObservedAt float64 `json:"observedAt"`
+	RecordTime
+	TopologyID string `json:"topologyId,omitempty"`
+	Address    string `json:"address,omitempty"`
+	// the server or topology kind before and after a description changed
+	PreviousType string `json:"previousType,omitempty"`
+	NewType      string `json:"newType,omitempty"`
+	// the connection and reason of a pool event
+	ConnectionID uint64 `json:"connectionId,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// WriteOutage is a window in which a client had no server to send writes to, from the topology
+// change that lost the last writable server until the change that discovered one.
+type WriteOutage struct {
+	TopologyID string `json:"topologyId"`
+	// seconds since the Unix epoch; End is zero if no writable server was discovered before the
+	// workload stopped
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	// the time to recover writes
+	DurationMS float64 `json:"durationMS"`
+}
+
+// FailoverStats reports how long the clients of the workload were without a writable server, e.g.
+// while a new primary was elected. Outages only start once a client has seen a writable server,
+// so that the initial discovery is not counted.
+type FailoverStats struct {
+	Outages []*WriteOutage `json:"outages"`
+	// the longest time to recover writes of the outages
+	MaxTimeToRecoverWritesMS float64 `json:"maxTimeToRecoverWritesMS"`
+	// the time to recover writes of the last outage, which is usually the one caused by the
+	// maintenance
+	LastTimeToRecoverWritesMS float64 `json:"lastTimeToRecoverWritesMS"`
+	NumPoolClears             int     `json:"numPoolClears"`
+	DroppedEvents             int     `json:"droppedEvents,omitempty"`
+}
+
+// recordedPoolEvents are the pool events kept by the sdamTracker.
+var recordedPoolEvents = map[string]bool{
+	event.PoolCreated:       true,
+	event.PoolReady:         true,
+	event.PoolCleared:       true,
+	event.PoolClosedEvent:   true,
+	event.ConnectionCreated: true,
+	event.ConnectionReady:   true,
+	event.ConnectionClosed:  true,
+	event.GetFailed:         true,
+}
+
+// sdamTracker records SDAM and pool events, which arrive concurrently with the operation loop, and
+// derives the write outages of each topology from them.
+type sdamTracker struct {
+	mu      sync.Mutex
+	events  []SDAMEvent
+	dropped int
+
+	// topologies that have had a writable server, keyed by topology ID
+	seenWritable map[string]bool
+	// outages in progress, keyed by topology ID
+	open    map[string]*WriteOutage
+	outages []*WriteOutage
+	clears  int
+}
+
+func newSDAMTracker() *sdamTracker {
+	return &sdamTracker{
+		seenWritable: make(map[string]bool),
+		open:         make(map[string]*WriteOutage),
+	}
+}
+
+// record appends evt, stamped with the current time, unless the cap is reached, and returns the
+// time it was stamped with.
+func (t *sdamTracker) record(evt SDAMEvent) float64 {
+	evt.ObservedAt, evt.RecordTime = stamp()
+	if len(t.events) >= maxSDAMEvents {
+		t.dropped++
+	} else {
+		t.events = append(t.events, evt)
+	}
+	return evt.ObservedAt
+}
+
+func (t *sdamTracker) serverChanged(evt *event.ServerDescriptionChangedEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.record(SDAMEvent{
+		Name:         "ServerDescriptionChangedEvent",
+		TopologyID:   evt.TopologyID.Hex(),
+		Address:      evt.Address.String(),
+		PreviousType: evt.PreviousDescription.Kind.String(),
+		NewType:      evt.NewDescription.Kind.String(),
+	})
+}
+
+func (t *sdamTracker) topologyChanged(evt *event.TopologyDescriptionChangedEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	id := evt.TopologyID.Hex()
+	observedAt := t.record(SDAMEvent{
+		Name:         "TopologyDescriptionChangedEvent",
+		TopologyID:   id,
+		PreviousType: evt.PreviousDescription.Kind.String(),
+		NewType:      evt.NewDescription.Kind.String(),
+	})
+
+	outage, inOutage := t.open[id]
+	switch writable := hasWritableServer(evt.NewDescription); {
+	case writable && inOutage:
+		outage.End = observedAt
+		outage.DurationMS = (outage.End - outage.Start) * 1000
+		delete(t.open, id)
+	case writable:
+		t.seenWritable[id] = true
+	case t.seenWritable[id] && !inOutage:
+		outage = &WriteOutage{TopologyID: id, Start: observedAt}
+		t.open[id] = outage
+		t.outages = append(t.outages, outage)
+	}
+}
+
+func (t *sdamTracker) poolEvent(evt *event.PoolEvent) {
+	if !recordedPoolEvents[evt.Type] {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if evt.Type == event.PoolCleared {
+		t.clears++
+	}
+	t.record(SDAMEvent{
+		Name:         evt.Type,
+		Address:      evt.Address,
+		ConnectionID: evt.ConnectionID,
+		Reason:       evt.Reason,
+	})
+}
+
+// hasWritableServer reports whether the topology has a server that accepts writes.
+func hasWritableServer(topology description.Topology) bool {
+	for _, server := range topology.Servers {
+		switch server.Kind {
+		case description.RSPrimary, description.Standalone, description.Mongos, description.LoadBalancer:
+			return true
+		}
+	}
+	return false
+}
+
+// summary returns the recorded events and the failover statistics. Outages still in progress are
+// reported up to end.
+func (t *sdamTracker) summary(end time.Time) ([]SDAMEvent, *FailoverStats) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := &FailoverStats{
+		Outages:       make([]*WriteOutage, 0, len(t.outages)),
+		NumPoolClears: t.clears,
+		DroppedEvents: t.dropped,
+	}
+	endSecs := float64(end.UnixNano()) / float64(time.Second)
+	for _, outage := range t.outages {
+		copied := *outage
+		if copied.End == 0 {
+			copied.DurationMS = (endSecs - copied.Start) * 1000
+		}
+		stats.Outages = append(stats.Outages, &copied)
+		if copied.DurationMS > stats.MaxTimeToRecoverWritesMS {
+			stats.MaxTimeToRecoverWritesMS = copied.DurationMS
+		}
+		stats.LastTimeToRecoverWritesMS = copied.DurationMS
+	}
+	return append([]SDAMEvent(nil), t.events...), stats
+}
+
+// SDAMEventsFileSink returns a Sink that writes the SDAM and pool events of the clients of the
+// workload as a JSON object with an "events" array to the file at path, which astrolabe keeps as
+// sdam-events.json.
+func SDAMEventsFileSink(path string) Sink {
+	return SinkFunc(func(results *Results) error {
+		if results.SDAMEvents == nil {
+			return nil
+		}
+		data, err := json.Marshal(map[string]interface{}{"events": results.SDAMEvents})
+		if err != nil {
+			return fmt.Errorf("marshal SDAM events failed: %v", err)
+		}
+		return writeFileAtomically(path, data)
+	})
+}
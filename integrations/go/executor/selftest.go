@@ -0,0 +1,127 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// selfTestDatabase is the database the self-test writes to. The collection it creates has a unique
+// name and is dropped when the self-test finishes.
+const selfTestDatabase = "astrolabe_selftest"
+
+// SelfTestStep is the outcome of one step of the self-test.
+type SelfTestStep struct {
+	Name       string  `json:"name"`
+	OK         bool    `json:"ok"`
+	DurationMS float64 `json:"durationMS"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// SelfTestReport describes the cluster the self-test ran against and the outcome of its steps.
+type SelfTestReport struct {
+	OK     bool        `json:"ok"`
+	Server *ServerInfo `json:"server,omitempty"`
+	// capabilities reported by the server the driver selected for the hello command
+	MaxWireVersion    int32           `json:"maxWireVersion,omitempty"`
+	SessionsSupported bool            `json:"sessionsSupported"`
+	Steps             []*SelfTestStep `json:"steps"`
+}
+
+// SelfTest runs a minimal built-in workload against the cluster at uri: it connects, inserts a
+// document, finds it and cleans up. It lets a scenario author check connectivity and the health of
+// the executor before a long run. Only the HostMap and Serverless fields of opts are used. The
+// returned error is the first step that failed, if any; the report is complete either way.
+func SelfTest(ctx context.Context, uri string, opts Options) (*SelfTestReport, error) {
+	report := &SelfTestReport{}
+	var firstErr error
+	step := func(name string, fn func() error) bool {
+		start := time.Now()
+		err := fn()
+		s := &SelfTestStep{Name: name, OK: err == nil, DurationMS: milliseconds(time.Since(start))}
+		if err != nil {
+			s.Error = err.Error()
+			if firstErr == nil {
+				firstErr = fmt.Errorf("self-test step %s failed: %v", name, err)
+			}
+		}
+		report.Steps = append(report.Steps, s)
+		return err == nil
+	}
+
+	runner := &workloadRunner{
+		uri:        uri,
+		clientOpts: options.Client().ApplyURI(uri),
+		serverless: opts.Serverless,
+	}
+	if len(opts.HostMap) > 0 {
+		runner.clientOpts.SetDialer(&hostRewritingDialer{hosts: opts.HostMap})
+	}
+
+	var client *mongo.Client
+	connected := step("connect", func() error {
+		var err error
+		client, err = mongo.Connect(ctx, runner.clientOpts)
+		if err != nil {
+			return err
+		}
+		runner.client = client
+		return client.Ping(ctx, readpref.Primary())
+	})
+	if client != nil {
+		defer func() { _ = client.Disconnect(context.Background()) }()
+	}
+	if !connected {
+		return report, firstErr
+	}
+
+	step("serverInfo", func() error {
+		server, err := runner.serverInfo(ctx)
+		if err != nil {
+			return err
+		}
+		report.Server = server
+
+		var hello struct {
+			MaxWireVersion               int32  `bson:"maxWireVersion"`
+			LogicalSessionTimeoutMinutes *int64 `bson:"logicalSessionTimeoutMinutes"`
+		}
+		err = client.Database("admin").RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&hello)
+		if err != nil {
+			return err
+		}
+		report.MaxWireVersion = hello.MaxWireVersion
+		report.SessionsSupported = hello.LogicalSessionTimeoutMinutes != nil
+		return nil
+	})
+
+	coll := client.Database(selfTestDatabase).Collection("selftest_" + primitive.NewObjectID().Hex())
+	id := primitive.NewObjectID()
+	if step("insert", func() error {
+		_, err := coll.InsertOne(ctx, bson.D{{Key: "_id", Value: id}, {Key: "selftest", Value: true}})
+		return err
+	}) {
+		step("find", func() error {
+			var doc bson.Raw
+			if err := coll.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&doc); err != nil {
+				return err
+			}
+			if selftest, ok := doc.Lookup("selftest").BooleanOK(); !ok || !selftest {
+				return fmt.Errorf("found %s, expected the inserted document", doc)
+			}
+			return nil
+		})
+	}
+	step("cleanup", func() error {
+		return coll.Drop(ctx)
+	})
+
+	report.OK = firstErr == nil
+	return report, firstErr
+}
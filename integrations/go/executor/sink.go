@@ -0,0 +1,191 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// Sink receives the results of a workload when it finishes.
+type Sink interface {
+	WriteResults(results *Results) error
+}
+
+// ReadySink is implemented by sinks that want to know when the workload has reached a steady
+// state, which is after the client connected and the first iteration in which every operation
+// succeeded. Ready is called at most once.
+type ReadySink interface {
+	Sink
+	Ready() error
+}
+
+// FlushSink is implemented by sinks that persist records while the workload is running, see
+// Options.FlushInterval. Flush receives only the records kept since the previous flush.
+type FlushSink interface {
+	Sink
+	Flush(records *Records) error
+}
+
+// IntervalSink is implemented by sinks that record metrics while the workload is running.
+// WriteInterval is called with the metrics of the interval since the previous call whenever records
+// are flushed, see Options.FlushInterval.
+type IntervalSink interface {
+	Sink
+	WriteInterval(metrics *IntervalMetrics) error
+}
+
+// SinkFunc adapts an ordinary function to the Sink interface.
+type SinkFunc func(results *Results) error
+
+// WriteResults calls f(results).
+func (f SinkFunc) WriteResults(results *Results) error {
+	return f(results)
+}
+
+// LatestResultsSchemaVersion is the newest version of the results.json format the executor can
+// write, see VersionedResultsFileSink.
+const LatestResultsSchemaVersion = 2
+
+// legacyResultFields are the fields of results.json that astrolabe reads to decide the outcome of a
+// workload. Version 2 of the format keeps them at the top level, where version 1 has every field.
+var legacyResultFields = map[string]bool{
+	"numErrors":       true,
+	"numFailures":     true,
+	"numSuccesses":    true,
+	"outcomeFailures": true,
+	"numIterations":   true,
+	"skipped":         true,
+	"skipReason":      true,
+	"budgetBreach":    true,
+}
+
+// operationMetrics are the outcome counts of the operations sharing a name in version 2 of the
+// results.json format.
+type operationMetrics struct {
+	NumErrors     int `json:"numErrors"`
+	NumFailures   int `json:"numFailures"`
+	NumSuccesses  int `json:"numSuccesses"`
+	NumAppRetries int `json:"numAppRetries,omitempty"`
+}
+
+// marshalResults returns the results in the given version of the results.json format. Version 1 is
+// the flat object astrolabe has always read. Version 2 adds a schemaVersion field, keeps the legacy
+// counters at the top level so that readers of version 1 keep working, and nests every other field
+// under "metrics", together with the outcome counts of each operation under "operations".
+func marshalResults(results *Results, version int) ([]byte, error) {
+	if version < 2 {
+		return json.Marshal(results)
+	}
+
+	flat, err := json.Marshal(results)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(flat, &fields); err != nil {
+		return nil, err
+	}
+
+	top := map[string]interface{}{"schemaVersion": version}
+	metrics := make(map[string]interface{})
+	for key, value := range fields {
+		if legacyResultFields[key] {
+			top[key] = value
+		} else {
+			metrics[key] = value
+		}
+	}
+	operations := make(map[string]operationMetrics, len(results.Operations))
+	for _, stats := range results.Operations {
+		operations[stats.Name] = operationMetrics{
+			NumErrors:     stats.NumErrors,
+			NumFailures:   stats.NumFailures,
+			NumSuccesses:  stats.NumSuccesses,
+			NumAppRetries: stats.NumAppRetries,
+		}
+	}
+	metrics["operations"] = operations
+	top["metrics"] = metrics
+	return json.Marshal(top)
+}
+
+// ResultsFileSink returns a Sink that writes the results as JSON to the file at path, which is the
+// format astrolabe reads from results.json. The file is replaced atomically.
+func ResultsFileSink(path string) Sink {
+	return VersionedResultsFileSink(path, 1)
+}
+
+// VersionedResultsFileSink is like ResultsFileSink, but writes the given version of the format, see
+// marshalResults. astrolabe requests version 2 from executors that support it.
+func VersionedResultsFileSink(path string, version int) Sink {
+	return SinkFunc(func(results *Results) error {
+		data, err := marshalResults(results, version)
+		if err != nil {
+			return fmt.Errorf("marshal results failed: %v", err)
+		}
+		return writeFileAtomically(path, data)
+	})
+}
+
+// writeFileAtomically writes data to a temporary file next to path and renames it to path, so that
+// a reader, or an executor killed while writing, never leaves a partially written file at path.
+func writeFileAtomically(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write to file failed: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// readyFileSink writes a file once the workload is ready.
+type readyFileSink struct {
+	path string
+}
+
+// ReadyFileSink returns a Sink that writes the time at which the workload became ready to the file
+// at path. The file is written to a temporary file first and renamed, so a reader that sees the
+// file can rely on its contents being complete.
+func ReadyFileSink(path string) Sink {
+	return readyFileSink{path: path}
+}
+
+func (s readyFileSink) WriteResults(*Results) error {
+	return nil
+}
+
+func (s readyFileSink) Ready() error {
+	t, rt := stamp()
+	data, err := json.Marshal(map[string]interface{}{
+		"time":        t,
+		"timestamp":   rt.Timestamp,
+		"timestampMS": rt.TimestampMS,
+	})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(s.path, data)
+}
+
+// writeResults passes the results to every sink and returns the first error.
+func (r *workloadRunner) writeResults() error {
+	var err error
+	for _, sink := range r.sinks {
+		if sinkErr := sink.WriteResults(&r.results); sinkErr != nil && err == nil {
+			err = sinkErr
+		}
+	}
+	return err
+}
+
+// notifyReady tells the sinks that the workload is ready. Errors are reported to stderr rather than
+// returned since readiness is advisory and must not interrupt the workload.
+func (r *workloadRunner) notifyReady() {
+	for _, sink := range r.sinks {
+		if rs, ok := sink.(ReadySink); ok {
+			if err := rs.Ready(); err != nil {
+				fmt.Fprintf(os.Stderr, "reporting readiness failed: %v\n", err)
+			}
+		}
+	}
+}
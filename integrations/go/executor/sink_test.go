@@ -0,0 +1,105 @@
+package executor
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalResults(t *testing.T) {
+	results := &Results{
+		NumErrors:     1,
+		NumFailures:   2,
+		NumSuccesses:  10,
+		NumIterations: 5,
+		NumAppRetries: 3,
+		Seed:          42,
+		ErrorKinds:    map[string]int{"network": 1},
+		Operations: []*OperationStats{
+			{Name: "insertOne", NumSuccesses: 6, NumAppRetries: 3},
+			{Name: "find", NumErrors: 1, NumFailures: 2, NumSuccesses: 4},
+		},
+	}
+	skipped := &Results{Skipped: true, SkipReason: "no sharded cluster", Seed: 7}
+	breached := &Results{
+		NumErrors:    4,
+		BudgetBreach: &BudgetBreach{Threshold: "maxErrors", Limit: 3, Actual: 4},
+		HarnessError: "executor panicked",
+	}
+
+	testCases := []struct {
+		name     string
+		results  *Results
+		version  int
+		expected string
+	}{
+		{
+			name:    "version 1 is flat",
+			results: results,
+			version: 1,
+			expected: `{
+				"numErrors": 1, "numFailures": 2, "numSuccesses": 10, "outcomeFailures": 0,
+				"numIterations": 5, "numAppRetries": 3, "seed": 42, "errorKinds": {"network": 1}
+			}`,
+		},
+		{
+			name:    "version 2 nests the fields that are not legacy counters",
+			results: results,
+			version: 2,
+			expected: `{
+				"schemaVersion": 2,
+				"numErrors": 1, "numFailures": 2, "numSuccesses": 10, "outcomeFailures": 0,
+				"numIterations": 5,
+				"metrics": {
+					"numAppRetries": 3, "seed": 42, "errorKinds": {"network": 1},
+					"operations": {
+						"insertOne": {"numErrors": 0, "numFailures": 0, "numSuccesses": 6, "numAppRetries": 3},
+						"find": {"numErrors": 1, "numFailures": 2, "numSuccesses": 4}
+					}
+				}
+			}`,
+		},
+		{
+			name:    "version 2 keeps the skip at the top level",
+			results: skipped,
+			version: 2,
+			expected: `{
+				"schemaVersion": 2,
+				"numErrors": 0, "numFailures": 0, "numSuccesses": 0, "outcomeFailures": 0,
+				"numIterations": 0, "skipped": true, "skipReason": "no sharded cluster",
+				"metrics": {"seed": 7, "operations": {}}
+			}`,
+		},
+		{
+			name:    "version 2 keeps the budget breach at the top level",
+			results: breached,
+			version: 2,
+			expected: `{
+				"schemaVersion": 2,
+				"numErrors": 4, "numFailures": 0, "numSuccesses": 0, "outcomeFailures": 0,
+				"numIterations": 0,
+				"budgetBreach": {"threshold": "maxErrors", "limit": 3, "actual": 4},
+				"metrics": {"seed": 0, "harnessError": "executor panicked", "operations": {}}
+			}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := marshalResults(tc.results, tc.version)
+			if err != nil {
+				t.Fatalf("marshal results failed: %v", err)
+			}
+			var actual, expected interface{}
+			if err := json.Unmarshal(data, &actual); err != nil {
+				t.Fatalf("unmarshal results failed: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tc.expected), &expected); err != nil {
+				t.Fatalf("unmarshal expected results failed: %v", err)
+			}
+			if !reflect.DeepEqual(actual, expected) {
+				t.Fatalf("expected %s, got %s", tc.expected, data)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// snapshotTooOldCode is the server error code returned for a read at a point in time that is no
+// longer in the snapshot history of the server.
+const snapshotTooOldCode = 239
+
+// SnapshotStats holds the outcome counts for snapshotReads operations.
+type SnapshotStats struct {
+	NumSessions int `json:"numSessions"`
+	NumReads    int `json:"numReads"`
+	// reads that failed because their snapshot had aged out of the server's history
+	NumSnapshotTooOld int `json:"numSnapshotTooOld"`
+	// reads that returned different documents than the first read of the same snapshot
+	NumInconsistent int `json:"numInconsistent"`
+}
+
+// snapshotStats returns the snapshot stats of the workload, creating them on first use so that
+// workloads without snapshotReads operations do not report them.
+func (r *workloadRunner) snapshotStats() *SnapshotStats {
+	if r.results.Snapshot == nil {
+		r.results.Snapshot = &SnapshotStats{}
+	}
+	return r.results.Snapshot
+}
+
+// executeSnapshotReads reads the documents of the workload collection matching the filter argument
+// numReads times, 2 by default, in a snapshot session, which sends them with read concern snapshot
+// at the time of the first read. The reads are not in a transaction. Every read must return the
+// same documents as the first; a read that does not is a failure reporting the first document that
+// differs. delayMS pauses between reads, so that a snapshot can be held across the maintenance of
+// the cluster. A SnapshotTooOld error ends the operation without failing it, since the server is
+// allowed to discard snapshot history older than minSnapshotHistoryWindowInSeconds.
+func (r *workloadRunner) executeSnapshotReads(op *operation) (bool, error) {
+	filter := emptyDoc
+	numReads := 2
+	var delay time.Duration
+
+	elems, _ := op.Arguments.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "filter":
+			filter = val.Document()
+		case "numReads":
+			numReads = int(val.AsInt64())
+		case "delayMS":
+			delay = time.Duration(val.AsInt64()) * time.Millisecond
+		default:
+			return false, harnessError(fmt.Errorf("unrecognized snapshotReads option: %v", key))
+		}
+	}
+
+	sess, err := r.client.StartSession(options.Session().SetSnapshot(true))
+	if err != nil {
+		return false, err
+	}
+	defer sess.EndSession(context.Background())
+	sessCtx := mongo.NewSessionContext(context.Background(), sess)
+
+	stats := r.snapshotStats()
+	stats.NumSessions++
+
+	var first []bson.Raw
+	for i := 0; i < numReads; i++ {
+		if i > 0 && !r.pause(delay) {
+			return true, nil
+		}
+
+		stats.NumReads++
+		docs, err := readSnapshot(sessCtx, r.coll, filter)
+		if isSnapshotTooOld(err) {
+			stats.NumSnapshotTooOld++
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		if i == 0 {
+			first = docs
+			continue
+		}
+		if msg := compareSnapshots(first, docs); msg != "" {
+			stats.NumInconsistent++
+			return false, &failure{msg: fmt.Sprintf("read %d of a snapshot differs from the first: %s", i+1, msg)}
+		}
+	}
+	return true, nil
+}
+
+// readSnapshot returns the documents of coll matching filter, sorted by _id.
+func readSnapshot(ctx context.Context, coll *mongo.Collection, filter interface{}) ([]bson.Raw, error) {
+	cur, err := coll.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = cur.Close(context.Background()) }()
+
+	var docs []bson.Raw
+	for cur.Next(ctx) {
+		docs = append(docs, append(bson.Raw(nil), cur.Current...))
+	}
+	return docs, cur.Err()
+}
+
+// compareSnapshots describes the first difference between the documents of two reads, or returns
+// an empty string if they are the same.
+func compareSnapshots(expected, actual []bson.Raw) string {
+	for i := 0; i < len(expected) && i < len(actual); i++ {
+		if !bytes.Equal(expected[i], actual[i]) {
+			return fmt.Sprintf("expected %s, got %s", expected[i], actual[i])
+		}
+	}
+	if len(expected) != len(actual) {
+		return fmt.Sprintf("expected %d documents, got %d", len(expected), len(actual))
+	}
+	return ""
+}
+
+func isSnapshotTooOld(err error) bool {
+	coded, ok := err.(interface{ HasErrorCode(int) bool })
+	return ok && coded.HasErrorCode(snapshotTooOldCode)
+}
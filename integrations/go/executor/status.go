@@ -0,0 +1,151 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Status is the state of the workload served at /status by a StatusServer.
+type Status struct {
+	// "starting" until the workload client has connected, then "running", "stopping" once the
+	// executor was asked to stop and "finished" once the results were written
+	State string `json:"state"`
+	Progress
+}
+
+// StatusSink is implemented by sinks that serve the status of the workload while it runs. Connected
+// is called once the workload client has connected and the workload is about to start, with a
+// function that returns the latest Progress of the workload and may be called from any goroutine.
+type StatusSink interface {
+	Sink
+	Connected(progress func() Progress)
+}
+
+// StatusServer is a Sink that serves the health of the executor over HTTP, so that it can run as a
+// long-lived pod with liveness and readiness probes:
+//
+//   - /healthz answers 200 as long as the executor is up.
+//   - /readyz answers 200 once the workload client has connected and 503 before that, and again
+//     once the executor was asked to stop.
+//   - /status answers the Status of the workload as JSON, with the counters of the last iteration
+//     boundary while it runs and the final counters once the results were written.
+type StatusServer struct {
+	server   *http.Server
+	listener net.Listener
+
+	mu       sync.Mutex
+	state    string
+	progress func() Progress
+	final    *Progress
+}
+
+// NewStatusServer starts serving the status of the executor on addr, e.g. ":8080". It serves until
+// Close is called.
+func NewStatusServer(addr string) (*StatusServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &StatusServer{listener: listener, state: "starting"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/readyz", s.serveReady)
+	mux.HandleFunc("/status", s.serveStatus)
+	s.server = &http.Server{Handler: mux}
+	go func() { _ = s.server.Serve(listener) }()
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *StatusServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *StatusServer) serveReady(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	state := s.state
+	s.mu.Unlock()
+
+	if state != "running" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_, _ = w.Write([]byte(state))
+}
+
+func (s *StatusServer) serveStatus(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	status := Status{State: s.state}
+	switch {
+	case s.final != nil:
+		status.Progress = *s.final
+	case s.progress != nil:
+		status.Progress = s.progress()
+	}
+	s.mu.Unlock()
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+func (s *StatusServer) Connected(progress func() Progress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.progress = progress
+	if s.state == "starting" {
+		s.state = "running"
+	}
+}
+
+// Stopping marks the executor as no longer ready, e.g. once it received the termination signal,
+// while it finishes the workload and writes its results.
+func (s *StatusServer) Stopping() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state != "finished" {
+		s.state = "stopping"
+	}
+}
+
+func (s *StatusServer) WriteResults(results *Results) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state = "finished"
+	s.final = &Progress{
+		NumErrors:     results.NumErrors,
+		NumFailures:   results.NumFailures,
+		NumSuccesses:  results.NumSuccesses,
+		NumIterations: results.NumIterations,
+		UpdatedAt:     now(),
+	}
+	return nil
+}
+
+// Close stops serving, waiting up to timeout for the requests in progress.
+func (s *StatusServer) Close(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+// notifyConnected hands the progress of the workload to every StatusSink.
+func (r *workloadRunner) notifyConnected() {
+	for _, sink := range r.sinks {
+		if ss, ok := sink.(StatusSink); ok {
+			ss.Connected(r.latestProgress)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// tailerDrainTimeout bounds how long a tailer keeps reading after the workload stopped, so that it
+// can catch up with the documents inserted last.
+const tailerDrainTimeout = 10 * time.Second
+
+// TailStats holds the outcome counts for a collection tailed by a tailCollection operation.
+type TailStats struct {
+	// documents read by the tailable cursor
+	NumDocuments int `json:"numDocuments"`
+	// tailable cursors reopened after the previous one died, e.g. because its server restarted
+	NumResumes int `json:"numResumes"`
+	// documents in the collection that the tailable cursors never returned
+	NumMissed int    `json:"numMissed"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+func init() {
+	registerCollectionOperation("createCappedCollection", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		return true, executeCreateCappedCollection(ctx, coll, op.Arguments)
+	})
+	registerCollectionOperation("tailCollection", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		return true, r.startTailer(coll, op.Arguments)
+	})
+}
+
+// executeCreateCappedCollection creates coll as a capped collection, like createCollection with
+// capped set.
+func executeCreateCappedCollection(ctx context.Context, coll *mongo.Collection, args bson.Raw) error {
+	opts := options.CreateCollection().SetCapped(true)
+
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "size":
+			opts = opts.SetSizeInBytes(val.AsInt64())
+		case "max":
+			opts = opts.SetMaxDocuments(val.AsInt64())
+		default:
+			return harnessError(fmt.Errorf("unrecognized createCappedCollection option: %v", key))
+		}
+	}
+
+	return createCollection(ctx, coll, opts)
+}
+
+// tailer reads the documents inserted into a capped collection with a tailable awaitData cursor
+// until the workload stops. When the cursor dies, e.g. because its server restarted, a new cursor
+// is opened after the last document read. Documents are expected to have increasing _id values,
+// such as ObjectIDs generated by the driver. Once stopped, every document of the collection from
+// the first one read onwards must have been read.
+type tailer struct {
+	coll     *mongo.Collection
+	filter   bson.Raw
+	maxAwait time.Duration
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopping chan struct{}
+	done     chan struct{}
+
+	mu      sync.Mutex
+	stats   TailStats
+	firstID interface{}
+	lastID  interface{}
+	seen    map[string]struct{}
+}
+
+// startTailer starts tailing coll with a client of its own, so that the commands of the tailable
+// cursor do not interfere with the monitoring of the workload client. Tailing a collection that is
+// already being tailed does nothing.
+func (r *workloadRunner) startTailer(coll *mongo.Collection, args bson.Raw) error {
+	name := coll.Database().Name() + "." + coll.Name()
+	if _, ok := r.tailers[name]; ok {
+		return nil
+	}
+
+	t := &tailer{
+		filter:   emptyDoc,
+		maxAwait: time.Second,
+		stopping: make(chan struct{}),
+		done:     make(chan struct{}),
+		seen:     make(map[string]struct{}),
+	}
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "filter":
+			t.filter = val.Document()
+		case "maxAwaitTimeMS":
+			t.maxAwait = time.Duration(val.AsInt64()) * time.Millisecond
+		default:
+			return harnessError(fmt.Errorf("unrecognized tailCollection option: %v", key))
+		}
+	}
+
+	client, err := mongo.Connect(context.Background(), r.unmonitoredClientOptions())
+	if err != nil {
+		return err
+	}
+	t.coll = client.Database(coll.Database().Name()).Collection(coll.Name())
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	r.tailers[name] = t
+
+	go func() {
+		defer close(t.done)
+		defer func() { _ = client.Disconnect(context.Background()) }()
+		t.run()
+	}()
+	return nil
+}
+
+// run opens tailable cursors until the tailer is stopped.
+func (t *tailer) run() {
+	for opened := 0; ; opened++ {
+		if opened > 0 {
+			select {
+			case <-t.stopping:
+				return
+			default:
+			}
+			t.mu.Lock()
+			if t.lastID != nil {
+				t.stats.NumResumes++
+			}
+			t.mu.Unlock()
+			// a tailable cursor on an empty collection dies immediately
+			if !sleepUntil(t.ctx, time.Now(), 0.1) {
+				return
+			}
+		}
+		if exhausted := t.tail(); exhausted {
+			return
+		}
+	}
+}
+
+// tail reads documents from one tailable cursor and reports whether the tailer has read every
+// document after being stopped.
+func (t *tailer) tail() bool {
+	filter := interface{}(t.filter)
+	t.mu.Lock()
+	if t.lastID != nil {
+		filter = bson.D{
+			{Key: "$and", Value: bson.A{t.filter, bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: t.lastID}}}}}},
+		}
+	}
+	t.mu.Unlock()
+
+	opts := options.Find().SetCursorType(options.TailableAwait).SetMaxAwaitTime(t.maxAwait)
+	cur, err := t.coll.Find(t.ctx, filter, opts)
+	if err != nil {
+		t.setError(err)
+		return t.ctx.Err() != nil
+	}
+	defer func() { _ = cur.Close(context.Background()) }()
+
+	for {
+		if cur.TryNext(t.ctx) {
+			t.record(cur.Current.Lookup("_id"))
+			continue
+		}
+		if err := cur.Err(); err != nil {
+			t.setError(err)
+			return t.ctx.Err() != nil
+		}
+		if cur.ID() == 0 {
+			return false
+		}
+		select {
+		case <-t.stopping:
+			// the last getMore returned no documents, so the cursor has caught up
+			return true
+		default:
+		}
+	}
+}
+
+func (t *tailer) record(id bson.RawValue) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.firstID == nil {
+		t.firstID = id
+	}
+	t.lastID = id
+	t.seen[id.String()] = struct{}{}
+	t.stats.NumDocuments++
+}
+
+func (t *tailer) setError(err error) {
+	if t.ctx.Err() != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.LastError = err.Error()
+}
+
+// stop lets the tailer catch up with the documents inserted last and waits for it to finish.
+func (t *tailer) stop() {
+	close(t.stopping)
+	timer := time.NewTimer(tailerDrainTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-t.done:
+	case <-timer.C:
+		t.cancel()
+		<-t.done
+	}
+	t.cancel()
+}
+
+// missed returns the number of documents of coll from the first one the tailer read onwards that
+// it did not read.
+func (t *tailer) missed(coll *mongo.Collection) (int, error) {
+	if t.firstID == nil {
+		return 0, nil
+	}
+	filter := bson.D{
+		{Key: "$and", Value: bson.A{t.filter, bson.D{{Key: "_id", Value: bson.D{{Key: "$gte", Value: t.firstID}}}}}},
+	}
+	opts := options.Find().SetProjection(bson.D{{Key: "_id", Value: 1}})
+	cur, err := coll.Find(context.Background(), filter, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = cur.Close(context.Background()) }()
+
+	missed := 0
+	for cur.Next(context.Background()) {
+		if _, ok := t.seen[cur.Current.Lookup("_id").String()]; !ok {
+			missed++
+		}
+	}
+	return missed, cur.Err()
+}
+
+// stopTailers stops every tailer and records the documents they missed as failures.
+func (r *workloadRunner) stopTailers() {
+	if len(r.tailers) == 0 {
+		return
+	}
+	r.results.Tailing = make(map[string]*TailStats, len(r.tailers))
+	for name, t := range r.tailers {
+		t.stop()
+		stats := t.stats
+		missed, err := t.missed(r.client.Database(t.coll.Database().Name()).Collection(t.coll.Name()))
+		if err != nil {
+			r.recordError(err)
+		}
+		if missed > 0 {
+			stats.NumMissed = missed
+			r.recordFailure(fmt.Sprintf("tailable cursors on %s missed %d documents", name, missed))
+		}
+		r.results.Tailing[name] = &stats
+	}
+	r.tailers = nil
+}
@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"fmt"
+	"io"
+)
+
+// tapPoint is a single Test Anything Protocol test point.
+type tapPoint struct {
+	ok          bool
+	description string
+	diagnostics []string
+}
+
+// TAPSink returns a Sink that writes the results to w in the Test Anything Protocol format.
+func TAPSink(w io.Writer) Sink {
+	return SinkFunc(func(results *Results) error {
+		writeTAP(w, results)
+		return nil
+	})
+}
+
+// writeTAP writes a TAP version 13 report to w. Each distinct operation name in
+// the workload is reported as one test point, followed by one test point for each
+// of the assertions astrolabe makes about the overall results.
+func writeTAP(w io.Writer, results *Results) {
+	var points []tapPoint
+
+	for _, stats := range results.Operations {
+		diagnostics := []string{
+			fmt.Sprintf("numErrors: %d", stats.NumErrors),
+			fmt.Sprintf("numFailures: %d", stats.NumFailures),
+			fmt.Sprintf("numSuccesses: %d", stats.NumSuccesses),
+		}
+		if stats.NumAppRetries > 0 {
+			diagnostics = append(diagnostics, fmt.Sprintf("numAppRetries: %d", stats.NumAppRetries))
+		}
+		points = append(points, tapPoint{
+			ok:          stats.NumErrors == 0 && stats.NumFailures == 0 && stats.NumSuccesses > 0,
+			description: "operation " + stats.Name,
+			diagnostics: diagnostics,
+		})
+	}
+
+	points = append(points,
+		tapPoint{
+			ok:          results.NumErrors == 0,
+			description: "numErrors is zero",
+			diagnostics: []string{fmt.Sprintf("numErrors: %d", results.NumErrors)},
+		},
+		tapPoint{
+			ok:          results.NumFailures == 0,
+			description: "numFailures is zero",
+			diagnostics: []string{fmt.Sprintf("numFailures: %d", results.NumFailures)},
+		},
+		tapPoint{
+			ok:          results.NumSuccesses > 0,
+			description: "numSuccesses is positive",
+			diagnostics: []string{fmt.Sprintf("numSuccesses: %d", results.NumSuccesses)},
+		},
+		tapPoint{
+			ok:          results.OutcomeFailures == 0,
+			description: "outcomeFailures is zero",
+			diagnostics: []string{fmt.Sprintf("outcomeFailures: %d", results.OutcomeFailures)},
+		},
+	)
+
+	fmt.Fprintln(w, "TAP version 13")
+	fmt.Fprintf(w, "1..%d\n", len(points))
+	for i, point := range points {
+		status := "ok"
+		if !point.ok {
+			status = "not ok"
+		}
+		fmt.Fprintf(w, "%s %d - %s\n", status, i+1, point.description)
+		fmt.Fprintln(w, "  ---")
+		for _, line := range point.diagnostics {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+		fmt.Fprintln(w, "  ...")
+	}
+}
@@ -0,0 +1,102 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// syntaxContext is how many bytes of the spec are shown on either side of a syntax error.
+const syntaxContext = 40
+
+// testCase is one of several named sets of operations in a workload. The test cases of a workload
+// share its client, collections, initial data and hooks.
+type testCase struct {
+	Description string
+	// the test case is skipped unless one of the requirements is met
+	RunOnRequirements []*RunOnRequirement `bson:"runOnRequirements"`
+	Operations        []*operation
+	Outcome           []*collectionOutcome
+}
+
+// parseWorkload parses spec. If testName is not empty, only the test case with that description is
+// kept.
+func parseWorkload(spec []byte, testName string) (*driverWorkload, error) {
+	if err := locateSyntaxError(spec); err != nil {
+		return nil, fmt.Errorf("parsing workload failed: %v", err)
+	}
+	var workload driverWorkload
+	err := bson.UnmarshalExtJSONWithRegistry(specTestRegistry, spec, false, &workload)
+	if err != nil {
+		return nil, fmt.Errorf("parsing workload failed: %v", err)
+	}
+
+	for _, legacy := range workload.RunOn {
+		workload.RunOnRequirements = append(workload.RunOnRequirements, legacy.requirement())
+	}
+
+	if testName != "" {
+		var selected []*testCase
+		for _, test := range workload.Tests {
+			if test.Description == testName {
+				selected = append(selected, test)
+			}
+		}
+		if len(selected) == 0 {
+			return nil, fmt.Errorf("workload has no test named %q", testName)
+		}
+		workload.Tests = selected
+	}
+	return &workload, nil
+}
+
+// locateSyntaxError returns the JSON syntax error of spec, if it has one, with its line and column
+// and the text around it, since the errors of the extended JSON parser do not say where they are.
+// Workloads passed on the command line are a single line, so only the part of the line near the
+// error is shown.
+func locateSyntaxError(spec []byte) error {
+	var raw json.RawMessage
+	syntaxErr, ok := json.Unmarshal(spec, &raw).(*json.SyntaxError)
+	if !ok {
+		return nil
+	}
+	// the offset is just past the byte the error was found at
+	offset := int(syntaxErr.Offset)
+	if offset > len(spec) {
+		offset = len(spec)
+	}
+	line := bytes.Count(spec[:offset], []byte("\n")) + 1
+	start := bytes.LastIndexByte(spec[:offset], '\n') + 1
+	end := len(spec)
+	if i := bytes.IndexByte(spec[start:], '\n'); i >= 0 {
+		end = start + i
+	}
+	column := offset - start
+
+	from, to := start, end
+	if offset-syntaxContext > from {
+		from = offset - syntaxContext
+	}
+	if offset+syntaxContext < to {
+		to = offset + syntaxContext
+	}
+	caret := offset - from - 1
+	if caret < 0 {
+		caret = 0
+	}
+	return fmt.Errorf("line %d, column %d: %v\n  %s\n  %s^",
+		line, column, syntaxErr, spec[from:to], strings.Repeat(" ", caret))
+}
+
+// mergeTests merges test cases into the workload. The operations of the test cases run one after
+// another in each iteration, after the top-level operations of the workload, and their outcomes are
+// all verified when the workload finishes.
+func (w *driverWorkload) mergeTests(tests []*testCase) {
+	for _, test := range tests {
+		w.Operations = append(w.Operations, test.Operations...)
+		w.Outcome = append(w.Outcome, test.Outcome...)
+	}
+}
@@ -0,0 +1,265 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Text for text index and Atlas Search workloads is generated from small built-in corpora, one per
+// language, so that searches during maintenance run over realistic, mostly non-ASCII content
+// rather than over the padding of padDocument. Terms are drawn with a Zipf distribution over the
+// words of a corpus, which are listed from the most to the least frequent, so that some terms
+// match many documents and most match few, as in natural text.
+
+// textLanguageField is the field generated documents name their language in. It is the default
+// language_override of text indexes, so that each document is stemmed by the rules of its language.
+const textLanguageField = "language"
+
+// textCorpus is the vocabulary of a language.
+type textCorpus struct {
+	// the text search language of the server; "none" for languages it has no stemmer for, whose
+	// terms are matched as they are
+	serverLanguage string
+	words          []string
+}
+
+// textCorpora are the corpora keyed by language code.
+var textCorpora = map[string]*textCorpus{
+	"en": {serverLanguage: "english", words: strings.Fields(
+		"the cluster data node primary secondary election failover driver query index replica " +
+			"shard balancer backup snapshot region latency throughput connection pool cursor " +
+			"transaction document collection maintenance upgrade restart")},
+	"de": {serverLanguage: "german", words: strings.Fields(
+		"der die und daten knoten wartung über größe schlüssel verbindung anfrage ausfall " +
+			"sicherung zuverlässigkeit durchsatz verzögerung dokument sammlung straße grüße " +
+			"prüfung lösung schnittstelle rückgabe")},
+	"fr": {serverLanguage: "french", words: strings.Fields(
+		"le la les données nœud requête élection réplique sécurité fiabilité débit délai " +
+			"connexion curseur opération mise à jour maintenance sauvegarde région première " +
+			"deuxième réseau où été")},
+	"es": {serverLanguage: "spanish", words: strings.Fields(
+		"el la los datos nodo consulta elección réplica índice conexión operación " +
+			"mantenimiento región latencia rendimiento copia seguridad año niño señal " +
+			"búsqueda información aplicación también")},
+	"ru": {serverLanguage: "russian", words: strings.Fields(
+		"и в данные узел кластер запрос индекс реплика выборы соединение курсор " +
+			"транзакция документ коллекция обслуживание резервная копия регион задержка " +
+			"пропускная способность сервер драйвер поиск")},
+	"tr": {serverLanguage: "turkish", words: strings.Fields(
+		"ve bir veri düğüm küme sorgu dizin kopya seçim bağlantı işlem belge koleksiyon " +
+			"bakım yedekleme bölge gecikme sunucu sürücü arama güvenlik ığdır şöyle çalışma")},
+	"ja": {serverLanguage: "none", words: strings.Fields(
+		"データ ノード クラスタ クエリ インデックス レプリカ 選挙 接続 カーソル トランザクション " +
+			"ドキュメント コレクション 保守 バックアップ 地域 遅延 スループット サーバー ドライバー 検索")},
+	"zh": {serverLanguage: "none", words: strings.Fields(
+		"数据 节点 集群 查询 索引 副本 选举 连接 游标 事务 文档 集合 维护 备份 区域 延迟 " +
+			"吞吐量 服务器 驱动 搜索")},
+	"ar": {serverLanguage: "none", words: strings.Fields(
+		"البيانات عقدة مجموعة استعلام فهرس نسخة انتخاب اتصال مؤشر معاملة مستند " +
+			"صيانة نسخ احتياطي منطقة تأخير خادم برنامج بحث أمان")},
+}
+
+// textLanguages returns the language codes of the corpora in alphabetical order.
+func textLanguages() []string {
+	languages := make([]string, 0, len(textCorpora))
+	for language := range textCorpora {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// textSpec is the text argument of insertOne and insertMany, which adds generated text to the
+// inserted documents:
+//
+//	text: {field: body, language: de, numWords: 40}
+type textSpec struct {
+	// defaults to "text"
+	field string
+	// a key of textCorpora; defaults to "en"
+	language string
+	// defaults to 20
+	numWords int
+}
+
+func parseTextSpec(doc bson.Raw) (*textSpec, error) {
+	spec := &textSpec{field: "text", language: "en", numWords: 20}
+	elems, _ := doc.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "field":
+			spec.field = val.StringValue()
+		case "language":
+			spec.language = val.StringValue()
+		case "numWords":
+			spec.numWords = int(val.AsInt64())
+		default:
+			return nil, harnessError(fmt.Errorf("unrecognized text option: %v", key))
+		}
+	}
+	if _, ok := textCorpora[spec.language]; !ok {
+		return nil, harnessError(fmt.Errorf("unknown text language %q, expected one of %v", spec.language, textLanguages()))
+	}
+	return spec, nil
+}
+
+// textGenerator draws terms from the corpora. It is shared by the operations of the workload and
+// those run alongside it, so its source is guarded by a mutex.
+type textGenerator struct {
+	mu    sync.Mutex
+	rng   *rand.Rand
+	zipfs map[string]*rand.Zipf
+}
+
+// newTextGenerator returns a generator whose choices are derived from seed.
+func newTextGenerator(seed int64) *textGenerator {
+	return &textGenerator{rng: rand.New(rand.NewSource(seed)), zipfs: make(map[string]*rand.Zipf)}
+}
+
+// terms returns n terms of the corpus of language.
+func (g *textGenerator) terms(language string, n int) []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	corpus := textCorpora[language]
+	zipf, ok := g.zipfs[language]
+	if !ok {
+		zipf = rand.NewZipf(g.rng, 1.2, 1, uint64(len(corpus.words)-1))
+		g.zipfs[language] = zipf
+	}
+	terms := make([]string, n)
+	for i := range terms {
+		terms[i] = corpus.words[zipf.Uint64()]
+	}
+	return terms
+}
+
+// addText returns doc with the generated text of spec and its language appended.
+func (g *textGenerator) addText(doc bson.Raw, spec *textSpec) (bson.Raw, error) {
+	var d bson.D
+	if err := bson.Unmarshal(doc, &d); err != nil {
+		return nil, err
+	}
+	d = append(d,
+		bson.E{Key: spec.field, Value: strings.Join(g.terms(spec.language, spec.numWords), " ")},
+		bson.E{Key: textLanguageField, Value: textCorpora[spec.language].serverLanguage})
+	return bson.Marshal(d)
+}
+
+func init() {
+	registerCollectionOperation("textSearch", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		cursor, err := r.executeTextSearch(ctx, coll, op.Arguments)
+		if err != nil {
+			return false, err
+		}
+		return verifyCursorResult(cursor, op.Result, r.verifier)
+	})
+	registerCollectionOperation("atlasSearch", func(ctx context.Context, r *workloadRunner, coll *mongo.Collection, op *operation) (bool, error) {
+		cursor, err := r.executeAtlasSearch(ctx, coll, op.Arguments)
+		if err != nil {
+			return false, err
+		}
+		return verifyCursorResult(cursor, op.Result, r.verifier)
+	})
+}
+
+// searchArguments are the arguments shared by textSearch and atlasSearch. The searched terms are
+// given by search, or drawn from the corpus of language if search is not set.
+type searchArguments struct {
+	search   string
+	language string
+	limit    int64
+}
+
+func (r *workloadRunner) parseSearchArguments(name string, args bson.Raw, extra func(key string, val bson.RawValue) bool) (searchArguments, error) {
+	search := searchArguments{language: "en", limit: 10}
+	elems, _ := args.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch {
+		case key == "search":
+			search.search = val.StringValue()
+		case key == "language":
+			search.language = val.StringValue()
+		case key == "limit":
+			search.limit = val.AsInt64()
+		case extra != nil && extra(key, val):
+		default:
+			return search, harnessError(fmt.Errorf("unrecognized %s option: %v", name, key))
+		}
+	}
+	if _, ok := textCorpora[search.language]; !ok {
+		return search, harnessError(fmt.Errorf("unknown %s language %q, expected one of %v", name, search.language, textLanguages()))
+	}
+	if search.search == "" {
+		search.search = r.text.terms(search.language, 1)[0]
+	}
+	return search, nil
+}
+
+// executeTextSearch finds the documents matching the search with $text, which requires a text
+// index on the collection, stemming the terms by the rules of their language.
+func (r *workloadRunner) executeTextSearch(ctx context.Context, coll *mongo.Collection, args bson.Raw) (*mongo.Cursor, error) {
+	search, err := r.parseSearchArguments("textSearch", args, nil)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.D{{Key: "$text", Value: bson.D{
+		{Key: "$search", Value: search.search},
+		{Key: "$language", Value: textCorpora[search.language].serverLanguage},
+	}}}
+	opts := options.Find().SetLimit(search.limit)
+	if label := operationLabel(ctx); label != "" {
+		opts = opts.SetComment(label)
+	}
+	return coll.Find(ctx, filter, opts)
+}
+
+// executeAtlasSearch runs the search with a $search stage against the Atlas Search index named by
+// the index argument, "default" if it is not set, over the field named by the path argument, "text"
+// if it is not set.
+func (r *workloadRunner) executeAtlasSearch(ctx context.Context, coll *mongo.Collection, args bson.Raw) (*mongo.Cursor, error) {
+	index, path := "default", "text"
+	search, err := r.parseSearchArguments("atlasSearch", args, func(key string, val bson.RawValue) bool {
+		switch key {
+		case "index":
+			index = val.StringValue()
+		case "path":
+			path = val.StringValue()
+		default:
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	pipeline := []bson.D{
+		{{Key: "$search", Value: bson.D{
+			{Key: "index", Value: index},
+			{Key: "text", Value: bson.D{
+				{Key: "query", Value: search.search},
+				{Key: "path", Value: path},
+			}},
+		}}},
+		{{Key: "$limit", Value: search.limit}},
+	}
+	opts := options.Aggregate()
+	if label := operationLabel(ctx); label != "" {
+		opts = opts.SetComment(label)
+	}
+	return coll.Aggregate(ctx, pipeline, opts)
+}
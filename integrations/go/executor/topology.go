@@ -0,0 +1,113 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// TopologyTimeline holds the state transitions of every server the workload client monitored, in
+// the order the servers were first seen. It is the format astrolabe reads from
+// topology-timeline.json to draw the topology in the HTML report.
+type TopologyTimeline struct {
+	Servers []*ServerTimeline `json:"servers"`
+}
+
+// ServerTimeline is the sequence of states a server went through as seen by the driver.
+type ServerTimeline struct {
+	Address     string            `json:"address"`
+	Transitions []StateTransition `json:"transitions"`
+}
+
+// StateTransition is a change of the state of a server.
+type StateTransition struct {
+	// seconds since the Unix epoch
+	Time float64 `json:"time"`
+	RecordTime
+	// the server kind, e.g. "RSPrimary" or "Unknown", or "Removed" once the server is no longer
+	// part of the topology
+	State string `json:"state"`
+}
+
+// removedState is the state of a server after the driver stopped monitoring it.
+const removedState = "Removed"
+
+// topologyTracker builds a TopologyTimeline from SDAM events, which arrive concurrently with the
+// operation loop.
+type topologyTracker struct {
+	mu      sync.Mutex
+	servers map[string]*ServerTimeline
+	order   []string
+}
+
+func newTopologyTracker() *topologyTracker {
+	return &topologyTracker{servers: make(map[string]*ServerTimeline)}
+}
+
+// transition records that the server at addr entered state, unless it already was in that state.
+func (t *topologyTracker) transition(addr, state string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	server, ok := t.servers[addr]
+	if !ok {
+		server = &ServerTimeline{Address: addr}
+		t.servers[addr] = server
+		t.order = append(t.order, addr)
+	}
+	if n := len(server.Transitions); n > 0 && server.Transitions[n-1].State == state {
+		return
+	}
+	secs, rt := stamp()
+	server.Transitions = append(server.Transitions, StateTransition{Time: secs, RecordTime: rt, State: state})
+}
+
+// summary returns a copy of the timeline recorded so far.
+func (t *topologyTracker) summary() *TopologyTimeline {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timeline := &TopologyTimeline{Servers: make([]*ServerTimeline, 0, len(t.order))}
+	for _, addr := range t.order {
+		server := t.servers[addr]
+		timeline.Servers = append(timeline.Servers, &ServerTimeline{
+			Address:     addr,
+			Transitions: append([]StateTransition(nil), server.Transitions...),
+		})
+	}
+	return timeline
+}
+
+func (t *topologyTracker) serverOpening(evt *event.ServerOpeningEvent) {
+	t.transition(evt.Address.String(), "Unknown")
+}
+
+func (t *topologyTracker) serverChanged(evt *event.ServerDescriptionChangedEvent) {
+	t.transition(evt.Address.String(), evt.NewDescription.Kind.String())
+}
+
+func (t *topologyTracker) serverClosed(evt *event.ServerClosedEvent) {
+	t.transition(evt.Address.String(), removedState)
+}
+
+// TopologyTimelineFileSink returns a Sink that writes the topology timeline as JSON to the file at
+// path, which is the format astrolabe reads from topology-timeline.json.
+func TopologyTimelineFileSink(path string) Sink {
+	return SinkFunc(func(results *Results) error {
+		if results.Topology == nil {
+			return nil
+		}
+		data, err := json.Marshal(results.Topology)
+		if err != nil {
+			return fmt.Errorf("marshal topology timeline failed: %v", err)
+		}
+		err = ioutil.WriteFile(path, data, 0644)
+		if err != nil {
+			return fmt.Errorf("write to file failed: %v", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,286 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fault is an entry of the faults schedule of a workload. Faults are injected by a toxiproxy
+// server that the workload client's connections are routed through, so no access to the cluster
+// is needed.
+type fault struct {
+	// seconds after the operation loop starts at which the fault is added
+	At float64
+	// seconds after which the fault is removed; the fault lasts until the workload finishes if
+	// omitted
+	Duration float64
+	// a toxiproxy toxic type such as "latency", "bandwidth", "reset_peer" or "timeout"
+	Type string
+	// "downstream" (the default) or "upstream"
+	Stream string
+	// fraction of connections affected, between 0 and 1; defaults to 1
+	Toxicity *float64
+	// toxic attributes, e.g. {latency: 500, jitter: 100} for "latency" or {rate: 64} for
+	// "bandwidth"
+	Attributes map[string]interface{}
+	// server addresses the fault applies to; defaults to every server
+	Hosts []string
+}
+
+// toxic is a fault as sent to toxiproxy.
+type toxic struct {
+	Name       string                 `json:"name"`
+	Type       string                 `json:"type"`
+	Stream     string                 `json:"stream"`
+	Toxicity   float64                `json:"toxicity"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// toxiproxyDialer routes every connection of the workload client through a toxiproxy proxy for the
+// server being dialed. Proxies are created on first use, so servers discovered while the workload
+// runs are proxied too, and active faults are applied to them when they are created.
+type toxiproxyDialer struct {
+	url string
+	// resolves the address toxiproxy connects to, see Options.HostMap
+	upstream func(address string) string
+	dialer   net.Dialer
+	client   http.Client
+
+	mu sync.Mutex
+	// listen address of the proxy for each server address
+	proxies map[string]string
+	// active faults, keyed by toxic name, and the hosts they apply to
+	active map[string]activeFault
+}
+
+type activeFault struct {
+	toxic toxic
+	hosts []string
+}
+
+func newToxiproxyDialer(url string, upstream func(string) string) *toxiproxyDialer {
+	return &toxiproxyDialer{
+		url:      strings.TrimRight(url, "/"),
+		upstream: upstream,
+		client:   http.Client{Timeout: 10 * time.Second},
+		proxies:  make(map[string]string),
+		active:   make(map[string]activeFault),
+	}
+}
+
+func (d *toxiproxyDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	listen, err := d.proxy(address)
+	if err != nil {
+		return nil, fmt.Errorf("creating toxiproxy proxy for %s failed: %v", address, err)
+	}
+	return d.dialer.DialContext(ctx, network, listen)
+}
+
+// proxy returns the listen address of the proxy for address, creating the proxy if necessary.
+func (d *toxiproxyDialer) proxy(address string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if listen, ok := d.proxies[address]; ok {
+		return listen, nil
+	}
+
+	name := proxyName(address)
+	// remove a proxy left behind by an earlier run
+	_ = d.do(http.MethodDelete, "/proxies/"+name, nil, nil)
+
+	var created struct {
+		Listen string `json:"listen"`
+	}
+	err := d.do(http.MethodPost, "/proxies", map[string]interface{}{
+		"name":     name,
+		"listen":   "127.0.0.1:0",
+		"upstream": d.upstream(address),
+		"enabled":  true,
+	}, &created)
+	if err != nil {
+		return "", err
+	}
+	d.proxies[address] = created.Listen
+
+	for _, af := range d.active {
+		if appliesTo(af.hosts, address) {
+			if err := d.do(http.MethodPost, "/proxies/"+name+"/toxics", af.toxic, nil); err != nil {
+				fmt.Fprintf(os.Stderr, "adding fault %q to %s failed: %v\n", af.toxic.Name, address, err)
+			}
+		}
+	}
+	return created.Listen, nil
+}
+
+// addFault adds t to the proxies of the given hosts, or of every server if hosts is empty.
+func (d *toxiproxyDialer) addFault(t toxic, hosts []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.active[t.Name] = activeFault{toxic: t, hosts: hosts}
+	for address := range d.proxies {
+		if !appliesTo(hosts, address) {
+			continue
+		}
+		if err := d.do(http.MethodPost, "/proxies/"+proxyName(address)+"/toxics", t, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "adding fault %q to %s failed: %v\n", t.Name, address, err)
+		}
+	}
+}
+
+// removeFault removes the toxic with the given name from every proxy it was added to.
+func (d *toxiproxyDialer) removeFault(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	af, ok := d.active[name]
+	if !ok {
+		return
+	}
+	delete(d.active, name)
+	for address := range d.proxies {
+		if !appliesTo(af.hosts, address) {
+			continue
+		}
+		if err := d.do(http.MethodDelete, "/proxies/"+proxyName(address)+"/toxics/"+name, nil, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "removing fault %q from %s failed: %v\n", name, address, err)
+		}
+	}
+}
+
+// runFaults adds and removes the faults on schedule, relative to now, until ctx is done.
+func (d *toxiproxyDialer) runFaults(ctx context.Context, faults []*fault) {
+	start := time.Now()
+	for i, f := range faults {
+		t := toxic{
+			Name:       fmt.Sprintf("fault%d-%s", i, f.Type),
+			Type:       f.Type,
+			Stream:     f.Stream,
+			Toxicity:   1,
+			Attributes: f.Attributes,
+		}
+		if t.Stream == "" {
+			t.Stream = "downstream"
+		}
+		if f.Toxicity != nil {
+			t.Toxicity = *f.Toxicity
+		}
+		f, hosts := f, f.Hosts
+
+		go func() {
+			if !sleepUntil(ctx, start, f.At) {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "adding fault %q\n", t.Name)
+			d.addFault(t, hosts)
+			if f.Duration <= 0 || !sleepUntil(ctx, start, f.At+f.Duration) {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "removing fault %q\n", t.Name)
+			d.removeFault(t.Name)
+		}()
+	}
+}
+
+// clearFaults removes every active fault.
+func (d *toxiproxyDialer) clearFaults() {
+	d.mu.Lock()
+	names := make([]string, 0, len(d.active))
+	for name := range d.active {
+		names = append(names, name)
+	}
+	d.mu.Unlock()
+
+	for _, name := range names {
+		d.removeFault(name)
+	}
+}
+
+// close deletes every proxy created by the dialer.
+func (d *toxiproxyDialer) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for address := range d.proxies {
+		if err := d.do(http.MethodDelete, "/proxies/"+proxyName(address), nil, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "deleting toxiproxy proxy for %s failed: %v\n", address, err)
+		}
+	}
+	d.proxies = make(map[string]string)
+}
+
+// do sends a request with an optional JSON body to the toxiproxy API and decodes the response into
+// out, if not nil.
+func (d *toxiproxyDialer) do(method, path string, body, out interface{}) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, d.url+path, &reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if out != nil {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// sleepUntil waits until the given number of seconds after start and reports whether ctx was not
+// done by then.
+func sleepUntil(ctx context.Context, start time.Time, seconds float64) bool {
+	deadline := start.Add(time.Duration(seconds * float64(time.Second)))
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func appliesTo(hosts []string, address string) bool {
+	if len(hosts) == 0 {
+		return true
+	}
+	for _, host := range hosts {
+		if host == address {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyName returns a toxiproxy proxy name for a server address.
+func proxyName(address string) string {
+	return "executor-" + strings.NewReplacer(":", "-", ".", "_").Replace(address)
+}
@@ -0,0 +1,289 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Reasons a transaction attempt is aborted or its commit retried, as counted in
+// TransactionStats.AbortsByReason.
+const (
+	transientTransactionError      = "TransientTransactionError"
+	unknownTransactionCommitResult = "UnknownTransactionCommitResult"
+	otherAbortReason               = "other"
+)
+
+// transactionRetryTimeLimit bounds how long a transaction is retried, matching the time limit of
+// the drivers' convenient transaction API.
+const transactionRetryTimeLimit = 120 * time.Second
+
+// TransactionStats holds the outcome counts for the transactions run by withTransaction
+// operations.
+type TransactionStats struct {
+	NumTransactions int `json:"numTransactions"`
+	NumCommits      int `json:"numCommits"`
+	// transactions that were given up on, after any retries
+	NumAborts int `json:"numAborts"`
+	// aborted attempts and retried commits, keyed by the error label that caused them
+	AbortsByReason map[string]int `json:"abortsByReason"`
+	NumRetries     int            `json:"numRetries"`
+	AvgRetries     float64        `json:"avgRetries"`
+}
+
+func init() {
+	registerObjectType("session", func(r *workloadRunner, op *operation) (bool, error) {
+		switch op.Name {
+		case "withTransaction":
+			return r.executeWithTransaction(op)
+		case "startTransaction":
+			return true, r.executeStartTransaction(op)
+		case "commitTransaction":
+			return r.executeCommitTransaction()
+		case "abortTransaction":
+			return true, r.executeAbortTransaction()
+		case "checkCausalConsistency":
+			return r.executeCheckCausalConsistency(op)
+		case "snapshotReads":
+			return r.executeSnapshotReads(op)
+		default:
+			return false, harnessError(errors.New("unrecognized session operation: " + op.Name))
+		}
+	})
+}
+
+// explicitTransaction is the transaction started by a startTransaction operation. The collection
+// operations of the workload on collections of the same client run in it until a commitTransaction
+// or abortTransaction operation ends it.
+type explicitTransaction struct {
+	client *mongo.Client
+	sess   mongo.Session
+}
+
+// transactionStats returns the transaction stats of the workload, creating them on first use so
+// that workloads without transactions do not report them.
+func (r *workloadRunner) transactionStats() *TransactionStats {
+	if r.results.Transactions == nil {
+		r.results.Transactions = &TransactionStats{AbortsByReason: make(map[string]int)}
+	}
+	return r.results.Transactions
+}
+
+// executeWithTransaction runs the operations in the callback argument of op in a transaction.
+// Like the drivers' convenient transaction API, the whole transaction is retried on errors
+// labelled TransientTransactionError and the commit is retried on errors labelled
+// UnknownTransactionCommitResult. The optional client argument names the client entity the
+// session is started from; callback operations must use collections of the same client.
+func (r *workloadRunner) executeWithTransaction(op *operation) (bool, error) {
+	client := r.client
+	var callback []*operation
+	elems, _ := op.Arguments.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "callback":
+			vals, _ := val.Array().Values()
+			for _, v := range vals {
+				var cbOp operation
+				if err := bson.UnmarshalWithRegistry(specTestRegistry, v.Document(), &cbOp); err != nil {
+					return false, err
+				}
+				callback = append(callback, &cbOp)
+			}
+		case "client":
+			c, ok := r.clients[val.StringValue()]
+			if !ok {
+				return false, harnessError(fmt.Errorf("unknown client entity: %v", val.StringValue()))
+			}
+			client = c
+		default:
+			return false, harnessError(fmt.Errorf("unrecognized withTransaction option: %v", key))
+		}
+	}
+
+	sess, err := client.StartSession()
+	if err != nil {
+		return false, err
+	}
+	defer sess.EndSession(context.Background())
+
+	stats := r.transactionStats()
+	stats.NumTransactions++
+	defer func() {
+		stats.AvgRetries = float64(stats.NumRetries) / float64(stats.NumTransactions)
+	}()
+
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			stats.NumRetries++
+		}
+		if err := sess.StartTransaction(); err != nil {
+			stats.NumAborts++
+			return false, err
+		}
+		sessCtx := mongo.NewSessionContext(context.Background(), sess)
+
+		pass, err := r.runCallback(sessCtx, callback)
+		if err != nil || !pass {
+			_ = sess.AbortTransaction(context.Background())
+			reason := abortReason(err)
+			stats.AbortsByReason[reason]++
+			if reason == transientTransactionError && time.Since(start) < transactionRetryTimeLimit {
+				continue
+			}
+			stats.NumAborts++
+			return pass, err
+		}
+
+		for {
+			err = sess.CommitTransaction(sessCtx)
+			if err == nil {
+				stats.NumCommits++
+				return true, nil
+			}
+			reason := abortReason(err)
+			stats.AbortsByReason[reason]++
+			if time.Since(start) >= transactionRetryTimeLimit || reason == otherAbortReason {
+				stats.NumAborts++
+				return false, err
+			}
+			if reason == transientTransactionError {
+				break
+			}
+			stats.NumRetries++
+		}
+	}
+}
+
+// runCallback executes the callback operations of a withTransaction operation with ctx, stopping
+// at the first operation that errors or whose result does not match.
+func (r *workloadRunner) runCallback(ctx context.Context, callback []*operation) (bool, error) {
+	for _, op := range callback {
+		coll := r.coll
+		if op.Object != "collection" {
+			c, ok := r.collections[op.Object]
+			if !ok {
+				return false, harnessError(errors.New("unrecognized object in withTransaction callback: " + op.Object))
+			}
+			coll = c
+		}
+		pass, err := r.executeCollectionOperation(ctx, coll, op)
+		if err != nil || !pass {
+			return pass, err
+		}
+	}
+	return true, nil
+}
+
+// abortReason returns the error label of err that decides whether the transaction is retried.
+// A callback whose result did not match aborts the transaction without an error.
+func abortReason(err error) string {
+	labeled, ok := err.(interface{ HasErrorLabel(string) bool })
+	switch {
+	case !ok:
+		return otherAbortReason
+	case labeled.HasErrorLabel(transientTransactionError):
+		return transientTransactionError
+	case labeled.HasErrorLabel(unknownTransactionCommitResult):
+		return unknownTransactionCommitResult
+	default:
+		return otherAbortReason
+	}
+}
+
+// executeStartTransaction starts a session and a transaction in it. The optional client argument
+// names the client entity the session is started from. A transaction left in progress by an
+// earlier iteration, e.g. because one of its operations errored, is aborted first.
+func (r *workloadRunner) executeStartTransaction(op *operation) error {
+	client := r.client
+	elems, _ := op.Arguments.Elements()
+	for _, elem := range elems {
+		key := elem.Key()
+		val := elem.Value()
+
+		switch key {
+		case "client":
+			c, ok := r.clients[val.StringValue()]
+			if !ok {
+				return harnessError(fmt.Errorf("unknown client entity: %v", val.StringValue()))
+			}
+			client = c
+		default:
+			return harnessError(fmt.Errorf("unrecognized startTransaction option: %v", key))
+		}
+	}
+	r.endTransaction()
+
+	sess, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	if err := sess.StartTransaction(); err != nil {
+		sess.EndSession(context.Background())
+		return err
+	}
+	r.txn = &explicitTransaction{client: client, sess: sess}
+	stats := r.transactionStats()
+	stats.NumTransactions++
+	stats.AvgRetries = float64(stats.NumRetries) / float64(stats.NumTransactions)
+	return nil
+}
+
+// executeCommitTransaction commits the transaction of the preceding startTransaction operation.
+// The commit is not retried beyond the retry of the driver.
+func (r *workloadRunner) executeCommitTransaction() (bool, error) {
+	if r.txn == nil {
+		return false, errors.New("commitTransaction without a transaction in progress")
+	}
+	txn := r.txn
+	r.txn = nil
+	defer txn.sess.EndSession(context.Background())
+
+	stats := r.transactionStats()
+	err := txn.sess.CommitTransaction(mongo.NewSessionContext(context.Background(), txn.sess))
+	if err != nil {
+		stats.AbortsByReason[abortReason(err)]++
+		stats.NumAborts++
+		return false, err
+	}
+	stats.NumCommits++
+	return true, nil
+}
+
+// executeAbortTransaction aborts the transaction of the preceding startTransaction operation.
+func (r *workloadRunner) executeAbortTransaction() error {
+	if r.txn == nil {
+		return errors.New("abortTransaction without a transaction in progress")
+	}
+	txn := r.txn
+	r.txn = nil
+	defer txn.sess.EndSession(context.Background())
+
+	r.transactionStats().NumAborts++
+	return txn.sess.AbortTransaction(context.Background())
+}
+
+// endTransaction aborts the transaction of a startTransaction operation that is still in progress,
+// if any, and ends its session.
+func (r *workloadRunner) endTransaction() {
+	if r.txn == nil {
+		return
+	}
+	_ = r.executeAbortTransaction()
+}
+
+// transactionContext returns the context an operation on coll runs with: that of the transaction
+// of a startTransaction operation if one is in progress on the client of coll.
+func (r *workloadRunner) transactionContext(coll *mongo.Collection) context.Context {
+	if r.txn == nil || coll.Database().Client() != r.txn.client {
+		return context.Background()
+	}
+	return mongo.NewSessionContext(context.Background(), r.txn.sess)
+}
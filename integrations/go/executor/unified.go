@@ -0,0 +1,196 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Workloads in the unified test format are run by the unified test runner of the driver rather
+// than by the operation loop of the executor, see runUnifiedTests. The runner keeps the outcome of
+// a loop operation in entities the test case names by convention, which RunUnified turns into the
+// same Results and records as those of a legacy workload, so that both entrypoints write the same
+// results.json and events.json.
+const (
+	unifiedErrorsEntity     = "errors"
+	unifiedFailuresEntity   = "failures"
+	unifiedSuccessesEntity  = "successes"
+	unifiedIterationsEntity = "iterations"
+	unifiedEventsEntity     = "events"
+)
+
+// unifiedOutcome holds the entities a test case of a unified workload stored its outcome in.
+type unifiedOutcome struct {
+	// documents with an error string field and a time field in seconds since the Unix epoch
+	errors   []bson.Raw
+	failures []bson.Raw
+	// documents with a name string field and an observedAt field in seconds since the Unix epoch
+	events []bson.Raw
+	// -1 if the test case did not store the entity
+	successes  int
+	iterations int
+}
+
+// unifiedRecord is an error or a failure stored by the loop operation of the unified test runner.
+type unifiedRecord struct {
+	Error string  `bson:"error"`
+	Time  float64 `bson:"time"`
+}
+
+// unifiedEvent is an event stored by a client entity of the unified test runner.
+type unifiedEvent struct {
+	Name         string  `bson:"name"`
+	CommandName  string  `bson:"commandName"`
+	RequestID    int64   `bson:"requestId"`
+	Address      string  `bson:"address"`
+	ConnectionID string  `bson:"connectionId"`
+	ObservedAt   float64 `bson:"observedAt"`
+	DatabaseName string  `bson:"databaseName"`
+}
+
+// RunUnified connects to the cluster at uri and runs the test cases of spec, an extended JSON
+// document in the unified test format, with the unified test runner of the driver. A loop operation
+// runs until ctx is done. Like RunWithOptions, it passes the results to each of the sinks however
+// the run ends. Of opts, only TestName, Serverless, the limits on the records and HeartbeatFile
+// apply; the other options configure the operation loop of legacy workloads.
+//
+// The unified test runner is only importable from the 1.x drivers, so RunUnified returns a harness
+// error unless the executor is built with the unified build tag.
+func RunUnified(ctx context.Context, uri string, spec []byte, opts Options, sinks ...Sink) (results *Results, err error) {
+	runner := &workloadRunner{
+		uri:        uri,
+		opStats:    make(map[string]*OperationStats),
+		sinks:      sinks,
+		testName:   opts.TestName,
+		limits:     opts.limits(),
+		serverless: opts.Serverless,
+
+		heartbeatPath: opts.HeartbeatFile,
+	}
+	// the unified test runner reports no count it did not store as an entity
+	runner.results.NumSuccesses = -1
+	runner.results.NumIterations = -1
+	defer func() {
+		results, err = runner.finish(recover(), err)
+	}()
+
+	// the unified test runner has no iteration boundaries to write the heartbeat at, so it only
+	// tells a watchdog that the executor is alive; skip writes it once the tests have returned
+	testsDone := make(chan struct{})
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			runner.maybeHeartbeat(0)
+			select {
+			case <-testsDone:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	reason, err := func() (string, error) {
+		defer func() {
+			close(testsDone)
+			<-heartbeatDone
+		}()
+		return runner.runUnifiedTests(ctx, spec)
+	}()
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		return runner.skip(ctx, reason)
+	}
+	return &runner.results, nil
+}
+
+// recordUnifiedOutcome adds the outcome of a test case to the results.
+func (r *workloadRunner) recordUnifiedOutcome(outcome *unifiedOutcome) error {
+	if outcome.successes >= 0 {
+		r.results.NumSuccesses = addReported(r.results.NumSuccesses, outcome.successes)
+	}
+	if outcome.iterations >= 0 {
+		r.results.NumIterations = addReported(r.results.NumIterations, outcome.iterations)
+	}
+
+	for _, doc := range outcome.errors {
+		var record unifiedRecord
+		if err := bson.Unmarshal(doc, &record); err != nil {
+			return fmt.Errorf("malformed %s entity: %v", unifiedErrorsEntity, err)
+		}
+		r.recordErrorAt(errors.New(record.Error), "", secondsTime(record.Time))
+	}
+	for _, doc := range outcome.failures {
+		var record unifiedRecord
+		if err := bson.Unmarshal(doc, &record); err != nil {
+			return fmt.Errorf("malformed %s entity: %v", unifiedFailuresEntity, err)
+		}
+		r.recordFailureAt(record.Error, "", secondsTime(record.Time))
+	}
+	for _, doc := range outcome.events {
+		var evt unifiedEvent
+		if err := bson.Unmarshal(doc, &evt); err != nil {
+			return fmt.Errorf("malformed %s entity: %v", unifiedEventsEntity, err)
+		}
+		// pool events have an address, command events only the connection
+		address := evt.Address
+		if address == "" {
+			address = addressFromConnectionID(evt.ConnectionID)
+		}
+		r.recordEventAt(Event{
+			Name:         evt.Name,
+			CommandName:  evt.CommandName,
+			RequestID:    evt.RequestID,
+			Address:      address,
+			DatabaseName: evt.DatabaseName,
+		}, secondsTime(evt.ObservedAt))
+	}
+	return nil
+}
+
+// addReported adds n to a count that is -1 until one is reported.
+func addReported(count, n int) int {
+	if count < 0 {
+		return n
+	}
+	return count + n
+}
+
+// secondsTime returns the time of s seconds since the Unix epoch.
+func secondsTime(s float64) time.Time {
+	// the fraction alone keeps its precision in nanoseconds
+	sec, frac := math.Modf(s)
+	return time.Unix(int64(sec), int64(math.Round(frac*float64(time.Second))))
+}
+
+// unifiedSkip is the reason the unified test runner skipped a test case.
+type unifiedSkip string
+
+// unifiedLogger passes the log of the unified test runner to stderr. The runner expects Skip not to
+// return, like that of a testing.T, so unifiedLogger panics with a unifiedSkip, which
+// runUnifiedTests recovers.
+type unifiedLogger struct{}
+
+func (unifiedLogger) Log(args ...interface{}) {
+	fmt.Fprintln(os.Stderr, args...)
+}
+
+func (unifiedLogger) Logf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
+
+func (unifiedLogger) Skip(args ...interface{}) {
+	panic(unifiedSkip(fmt.Sprint(args...)))
+}
+
+func (unifiedLogger) Skipf(format string, args ...interface{}) {
+	panic(unifiedSkip(fmt.Sprintf(format, args...)))
+}
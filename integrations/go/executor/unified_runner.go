@@ -0,0 +1,158 @@
+//go:build unified
+// +build unified
+
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/integration/unified"
+)
+
+// unifiedRunnerAvailable is whether RunUnified can run unified workloads.
+const unifiedRunnerAvailable = true
+
+// runUnifiedTests runs the test cases of spec, or the one selected by the test name, one after the
+// other with the unified test runner of the driver, and adds their outcome to the results. A loop
+// operation is told to stop once ctx is done, so a looping test case should be the last. It returns
+// why the workload must be skipped, or an empty string if it ran.
+func (r *workloadRunner) runUnifiedTests(ctx context.Context, spec []byte) (string, error) {
+	if err := mtest.Setup(mtest.NewSetupOptions().SetURI(r.uri)); err != nil {
+		return "", setupError(fmt.Errorf("setting up the unified test runner failed: %v", err))
+	}
+	defer func() { _ = mtest.Teardown() }()
+	r.notifyConnected()
+
+	// the unified test runner reports malformed test files to t rather than returning an error
+	t := &testing.T{}
+	runOn, testCases := unified.ParseTestFile(t, spec, false)
+	if t.Failed() {
+		return "", harnessError(errors.New("parsing workload failed: not a valid unified test file"))
+	}
+	if r.testName != "" {
+		var selected []*unified.TestCase
+		for _, tc := range testCases {
+			if tc.Description == r.testName {
+				selected = append(selected, tc)
+			}
+		}
+		if len(selected) == 0 {
+			return "", harnessError(fmt.Errorf("workload has no test named %q", r.testName))
+		}
+		testCases = selected
+	}
+
+	diag := &RequirementDiagnostics{Server: ServerInfo{
+		Version:    mtest.ServerVersion(),
+		Topology:   string(mtest.ClusterTopologyKind()),
+		Serverless: r.serverless,
+	}}
+	r.results.Requirements = diag
+	if reason := diag.evaluate("workload", "", unifiedRequirements(runOn)); reason != "" {
+		return reason, nil
+	}
+
+	var skips []string
+	for _, tc := range testCases {
+		reason := diag.evaluate("test", tc.Description, unifiedRequirements(tc.RunOnRequirements))
+		if reason == "" {
+			reason = r.runUnifiedTestCase(ctx, tc)
+		}
+		if reason != "" {
+			fmt.Fprintf(os.Stderr, "skipping test %q: %s\n", tc.Description, reason)
+			skips = append(skips, fmt.Sprintf("test %q: %s", tc.Description, reason))
+		}
+	}
+	if len(skips) == len(testCases) {
+		return strings.Join(skips, "; "), nil
+	}
+	return "", nil
+}
+
+// runUnifiedTestCase runs tc until it finishes, or until its loop stops after ctx is done, and adds
+// its outcome to the results. An error that ends the test case is recorded as an error of the
+// workload. It returns why the unified test runner skipped the test case, if it did.
+func (r *workloadRunner) runUnifiedTestCase(ctx context.Context, tc *unified.TestCase) (skipReason string) {
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// EndLoop panics if the test case has already finished
+			defer func() { _ = recover() }()
+			tc.EndLoop()
+		case <-finished:
+		}
+	}()
+
+	r.notifyReady()
+	err := func() (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				reason, ok := p.(unifiedSkip)
+				if !ok {
+					panic(p)
+				}
+				skipReason = string(reason)
+			}
+		}()
+		return tc.Run(unifiedLogger{})
+	}()
+	if skipReason != "" {
+		return skipReason
+	}
+	if err != nil {
+		r.recordError(fmt.Errorf("test %q: %v", tc.Description, err))
+	}
+
+	if err = r.recordUnifiedOutcome(unifiedOutcomeOf(tc.GetEntities())); err != nil {
+		r.recordError(harnessError(err))
+	}
+	return ""
+}
+
+// unifiedOutcomeOf returns the entities of em the test case stored its outcome in.
+func unifiedOutcomeOf(em *unified.EntityMap) *unifiedOutcome {
+	outcome := &unifiedOutcome{successes: -1, iterations: -1}
+	if docs, err := em.BSONArray(unifiedErrorsEntity); err == nil {
+		outcome.errors = docs
+	}
+	if docs, err := em.BSONArray(unifiedFailuresEntity); err == nil {
+		outcome.failures = docs
+	}
+	if docs, err := em.EventList(unifiedEventsEntity); err == nil {
+		outcome.events = docs
+	}
+	if n, err := em.Successes(unifiedSuccessesEntity); err == nil {
+		outcome.successes = int(n)
+	}
+	if n, err := em.Iterations(unifiedIterationsEntity); err == nil {
+		outcome.iterations = int(n)
+	}
+	return outcome
+}
+
+// unifiedRequirements returns the runOnRequirements of the unified test runner as those of the
+// executor. Requirements on server parameters, authentication and CSFLE are not evaluated.
+func unifiedRequirements(blocks []mtest.RunOnBlock) []*RunOnRequirement {
+	var requirements []*RunOnRequirement
+	for _, block := range blocks {
+		topologies := make([]string, 0, len(block.Topology))
+		for _, topology := range block.Topology {
+			topologies = append(topologies, string(topology))
+		}
+		requirements = append(requirements, &RunOnRequirement{
+			MinServerVersion: block.MinServerVersion,
+			MaxServerVersion: block.MaxServerVersion,
+			Topologies:       topologies,
+			Serverless:       block.Serverless,
+		})
+	}
+	return requirements
+}
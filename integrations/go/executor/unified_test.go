@@ -0,0 +1,84 @@
+package executor
+
+import (
+	"math"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func mustRaw(t *testing.T, extJSON string) bson.Raw {
+	t.Helper()
+	var raw bson.Raw
+	if err := bson.UnmarshalExtJSON([]byte(extJSON), false, &raw); err != nil {
+		t.Fatalf("unmarshal %s failed: %v", extJSON, err)
+	}
+	return raw
+}
+
+// closeTo reports whether the times a and b in seconds are within a microsecond, since they are
+// converted through nanoseconds.
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestRecordUnifiedOutcome(t *testing.T) {
+	runner := &workloadRunner{limits: Options{}.limits()}
+	runner.results.NumSuccesses = -1
+	runner.results.NumIterations = -1
+
+	outcomes := []*unifiedOutcome{
+		{
+			errors: []bson.Raw{mustRaw(t, `{"error": "connection reset", "time": 1600000000.5}`)},
+			events: []bson.Raw{
+				mustRaw(t, `{
+					"name": "CommandStartedEvent", "observedAt": 1600000000.25, "databaseName": "db",
+					"commandName": "find", "requestId": {"$numberLong": "7"}, "connectionId": "localhost:27017[-3]"
+				}`),
+				mustRaw(t, `{"name": "PoolClearedEvent", "observedAt": 1600000001.0, "address": "localhost:27018"}`),
+			},
+			successes:  3,
+			iterations: -1,
+		},
+		{
+			failures:   []bson.Raw{mustRaw(t, `{"error": "expected 1, got 2", "time": 1600000002.0}`)},
+			successes:  2,
+			iterations: 4,
+		},
+	}
+	for _, outcome := range outcomes {
+		if err := runner.recordUnifiedOutcome(outcome); err != nil {
+			t.Fatalf("record outcome failed: %v", err)
+		}
+	}
+
+	results := runner.results
+	if results.NumSuccesses != 5 || results.NumIterations != 4 {
+		t.Fatalf("expected 5 successes and 4 iterations, got %d and %d", results.NumSuccesses, results.NumIterations)
+	}
+	if results.NumErrors != 1 || results.Errors[0].Error != "connection reset" || !closeTo(results.Errors[0].Time, 1600000000.5) {
+		t.Fatalf("expected the error of the first test case, got %+v", results.Errors)
+	}
+	if results.NumFailures != 1 || results.Failures[0].Error != "expected 1, got 2" || !closeTo(results.Failures[0].Time, 1600000002) {
+		t.Fatalf("expected the failure of the second test case, got %+v", results.Failures)
+	}
+	expected := []Event{
+		{Name: "CommandStartedEvent", CommandName: "find", RequestID: 7, Address: "localhost:27017", ObservedAt: 1600000000.25, DatabaseName: "db"},
+		{Name: "PoolClearedEvent", Address: "localhost:27018", ObservedAt: 1600000001},
+	}
+	if len(results.Events) != len(expected) {
+		t.Fatalf("expected %d events, got %+v", len(expected), results.Events)
+	}
+	for i, evt := range results.Events {
+		if evt.Name != expected[i].Name || evt.CommandName != expected[i].CommandName ||
+			evt.RequestID != expected[i].RequestID || evt.Address != expected[i].Address ||
+			!closeTo(evt.ObservedAt, expected[i].ObservedAt) || evt.DatabaseName != expected[i].DatabaseName {
+			t.Fatalf("event %d: expected %+v, got %+v", i, expected[i], evt)
+		}
+	}
+
+	malformed := &unifiedOutcome{errors: []bson.Raw{mustRaw(t, `{"error": 1}`)}}
+	if err := runner.recordUnifiedOutcome(malformed); err == nil {
+		t.Fatalf("expected a malformed errors entity to fail")
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !unified
+// +build !unified
+
+package executor
+
+import (
+	"context"
+	"errors"
+)
+
+// unifiedRunnerAvailable is whether RunUnified can run unified workloads.
+const unifiedRunnerAvailable = false
+
+// runUnifiedTests returns a harness error, since the executor was built without the unified test
+// runner of the driver.
+func (r *workloadRunner) runUnifiedTests(context.Context, []byte) (string, error) {
+	return "", harnessError(errors.New("this executor was built without the unified test runner; " +
+		"rebuild it with GO_BUILD_TAGS=unified against a 1.x driver to run unified workloads"))
+}
@@ -0,0 +1,266 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// argumentSpec lists the arguments an operation accepts.
+type argumentSpec struct {
+	required []string
+	optional []string
+}
+
+// operationArguments holds the arguments of the operations of each object type, keyed by object
+// type and then by operation name. Operations on object types without an entry, such as those added
+// by build-tagged extensions, are not checked beyond their object. Collection entities use the
+// entry of "collection" and client encryption entities that of "clientEncryption".
+var operationArguments = map[string]map[string]argumentSpec{
+	"collection": {
+		"insertOne":        {optional: []string{"document", "documentSize", "text"}},
+		"insertMany":       {required: []string{"documents"}, optional: []string{"repeat", "documentSize", "ordered", "text"}},
+		"find":             {optional: []string{"filter", "sort", "collation", "readPreference"}},
+		"updateOne":        {required: []string{"update"}, optional: []string{"filter", "upsert", "collation", "paddingSize"}},
+		"updateMany":       {required: []string{"update"}, optional: []string{"filter", "upsert", "collation", "paddingSize"}},
+		"replaceOne":       {required: []string{"replacement"}, optional: []string{"filter", "upsert", "collation"}},
+		"deleteOne":        {optional: []string{"filter", "collation"}},
+		"deleteMany":       {optional: []string{"filter", "collation"}},
+		"bulkWrite":        {required: []string{"requests"}, optional: []string{"ordered"}},
+		"countDocuments":   {optional: []string{"filter", "skip", "limit", "collation"}},
+		"distinct":         {required: []string{"fieldName"}, optional: []string{"filter", "collation"}},
+		"findOneAndDelete": {optional: []string{"filter", "sort", "projection", "collation"}},
+		"findOneAndReplace": {
+			required: []string{"replacement"},
+			optional: []string{"filter", "sort", "projection", "upsert", "returnDocument", "collation"},
+		},
+		"findOneAndUpdate": {
+			required: []string{"update"},
+			optional: []string{"filter", "sort", "projection", "upsert", "returnDocument", "collation"},
+		},
+		"aggregate": {
+			required: []string{"pipeline"},
+			optional: []string{"batchSize", "allowDiskUse", "collation", "readPreference"},
+		},
+		"createCollection":       {optional: []string{"capped", "size", "max", "timeseries", "expireAfterSeconds"}},
+		"createIndex":            {required: []string{"keys"}, optional: []string{"name", "unique"}},
+		"textSearch":             {optional: []string{"search", "language", "limit"}},
+		"atlasSearch":            {optional: []string{"search", "language", "limit", "index", "path"}},
+		"createCappedCollection": {optional: []string{"size", "max"}},
+		"tailCollection":         {optional: []string{"filter", "maxAwaitTimeMS"}},
+		"iterateCursor":          {optional: []string{"filter", "batchSize", "delayMS", "resumeOnCursorNotFound"}},
+	},
+	"testRunner": {
+		"failPoint":              {required: []string{"failPoint"}, optional: []string{"client"}},
+		"targetedFailPoint":      {required: []string{"failPoint"}, optional: []string{"host", "session"}},
+		"assertCollectionExists": {optional: []string{"databaseName", "collectionName"}},
+		"assertIndexExists":      {required: []string{"indexName"}, optional: []string{"databaseName", "collectionName"}},
+		"assertDocumentCount": {
+			required: []string{"count"},
+			optional: []string{"databaseName", "collectionName", "filter"},
+		},
+	},
+	"session": {
+		"withTransaction":        {required: []string{"callback"}, optional: []string{"client"}},
+		"startTransaction":       {optional: []string{"client"}},
+		"commitTransaction":      {},
+		"abortTransaction":       {},
+		"checkCausalConsistency": {optional: []string{"documentId", "readPreference"}},
+		"snapshotReads":          {optional: []string{"filter", "numReads", "delayMS"}},
+	},
+	"clientEncryption": {
+		"createDataKey": {required: []string{"kmsProvider"}, optional: []string{"masterKey", "keyAltNames"}},
+		"encrypt":       {required: []string{"value"}, optional: []string{"algorithm", "keyAltName"}},
+		"decrypt":       {},
+	},
+}
+
+// ValidateWorkload parses spec and checks it as RunWithOptions does before connecting to the
+// cluster, without running it.
+func ValidateWorkload(spec []byte, testName string) error {
+	workload, err := parseWorkload(spec, testName)
+	if err != nil {
+		return err
+	}
+	return validateWorkload(workload)
+}
+
+// validateWorkload checks every operation of the workload, including those of its test cases,
+// hooks, transaction callbacks and write concern comparison, before the executor connects to the
+// cluster. Operations are otherwise only checked when they first run, where a bad one stops the run
+// with a harness error. All the problems found are returned in a single error.
+func validateWorkload(w *driverWorkload) error {
+	v := &workloadValidator{
+		collections: make(map[string]bool),
+		clients:     make(map[string]bool),
+
+		clientEncryptions: make(map[string]bool),
+	}
+	for _, entity := range w.Clients {
+		v.clients[entity.ID] = true
+	}
+	for _, entity := range w.Collections {
+		v.collections[entity.ID] = true
+	}
+	for _, entity := range w.ClientEncryptions {
+		v.clientEncryptions[entity.ID] = true
+	}
+
+	v.checkOperations("operations", w.Operations)
+	for i, test := range w.Tests {
+		v.checkOperations(fmt.Sprintf("tests[%d] (%s)", i, test.Description), test.Operations)
+	}
+	for i, h := range w.Hooks.BeforeLoop {
+		v.checkOperations(fmt.Sprintf("hooks.beforeLoop[%d]", i), h.Operations)
+	}
+	for i, h := range w.Hooks.AfterLoop {
+		v.checkOperations(fmt.Sprintf("hooks.afterLoop[%d]", i), h.Operations)
+	}
+	if w.WriteConcernComparison != nil {
+		v.checkOperations("writeConcernComparison.operations", w.WriteConcernComparison.Operations)
+	}
+
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid workload:\n  %s", strings.Join(v.problems, "\n  "))
+}
+
+type workloadValidator struct {
+	// IDs of the collection, client and client encryption entities declared by the workload
+	collections       map[string]bool
+	clients           map[string]bool
+	clientEncryptions map[string]bool
+	problems          []string
+}
+
+func (v *workloadValidator) problem(path string, format string, args ...interface{}) {
+	v.problems = append(v.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *workloadValidator) checkOperations(path string, operations []*operation) {
+	for i, op := range operations {
+		v.checkOperation(fmt.Sprintf("%s[%d]", path, i), op)
+	}
+}
+
+func (v *workloadValidator) checkOperation(path string, op *operation) {
+	if op.Name == "" {
+		v.problem(path, "operation has no name")
+		return
+	}
+	path += " " + op.Name
+	if op.Weight != nil && *op.Weight < 0 {
+		v.problem(path, "weight must not be negative")
+	}
+
+	objectType := op.Object
+	switch {
+	case v.collections[op.Object]:
+		objectType = "collection"
+	case v.clientEncryptions[op.Object]:
+		objectType = "clientEncryption"
+	case objectTypes[op.Object] == nil:
+		v.problem(path, "unrecognized object %q", op.Object)
+		return
+	}
+
+	if objectType == "collection" && collectionOperations[op.Name] == nil {
+		v.problem(path, "unrecognized collection operation")
+		return
+	}
+	operations, ok := operationArguments[objectType]
+	if !ok {
+		return
+	}
+	spec, ok := operations[op.Name]
+	if !ok {
+		// collection operations registered by files added to the package, see registry.go, have
+		// no argument spec and check their arguments when they run
+		if objectType != "collection" {
+			v.problem(path, "unrecognized %s operation", objectType)
+		}
+		return
+	}
+	v.checkArguments(path, op, spec)
+}
+
+func (v *workloadValidator) checkArguments(path string, op *operation, spec argumentSpec) {
+	if op.Arguments != nil {
+		if err := op.Arguments.Validate(); err != nil {
+			v.problem(path, "malformed arguments: %v", err)
+			return
+		}
+	}
+
+	allowed := make(map[string]bool)
+	for _, name := range spec.required {
+		allowed[name] = true
+		if _, err := op.Arguments.LookupErr(name); err != nil {
+			v.problem(path, "missing required argument %q", name)
+		}
+	}
+	for _, name := range spec.optional {
+		allowed[name] = true
+	}
+
+	elems, _ := op.Arguments.Elements()
+	var unknown []string
+	for _, elem := range elems {
+		key := elem.Key()
+		if !allowed[key] {
+			unknown = append(unknown, key)
+			continue
+		}
+		v.checkArgument(path, op.Name, key, elem.Value())
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		v.problem(path, "unrecognized argument %q", key)
+	}
+}
+
+// checkArgument checks the arguments that name other parts of the workload or nest operations.
+func (v *workloadValidator) checkArgument(path string, opName string, key string, val bson.RawValue) {
+	switch key {
+	case "client":
+		// the client argument of failPoint is accepted for compatibility and ignored
+		if opName != "withTransaction" {
+			return
+		}
+		if name, ok := val.StringValueOK(); !ok || !v.clients[name] {
+			v.problem(path, "unknown client entity %v", val)
+		}
+	case "readPreference":
+		if _, err := createReadPreference(val); err != nil {
+			v.problem(path, "invalid readPreference: %v", err)
+		}
+	case "callback":
+		arr, ok := val.ArrayOK()
+		if !ok {
+			v.problem(path, "callback must be an array of operations")
+			return
+		}
+		vals, _ := arr.Values()
+		for i, cbVal := range vals {
+			cbPath := fmt.Sprintf("%s callback[%d]", path, i)
+			var cbOp operation
+			doc, ok := cbVal.DocumentOK()
+			if !ok {
+				v.problem(cbPath, "callback operations must be documents")
+				continue
+			}
+			if err := bson.UnmarshalWithRegistry(specTestRegistry, doc, &cbOp); err != nil {
+				v.problem(cbPath, "malformed operation: %v", err)
+				continue
+			}
+			if cbOp.Object != "collection" && !v.collections[cbOp.Object] {
+				v.problem(cbPath, "callback operations must use a collection, not %q", cbOp.Object)
+				continue
+			}
+			v.checkOperation(cbPath, &cbOp)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func init() {
+	RegisterCollectionOperation("registeredWithoutSpec", func(context.Context, *mongo.Collection, *Operation) (bool, error) {
+		return true, nil
+	})
+}
+
+func TestValidateWorkload(t *testing.T) {
+	testCases := []struct {
+		name     string
+		workload string
+		// problems reported, in order; none if the workload is valid
+		problems []string
+	}{
+		{
+			name: "valid",
+			workload: `{"operations": [
+				{"object": "collection", "name": "insertOne", "arguments": {"document": {"x": 1}}},
+				{"object": "collection", "name": "find", "arguments": {"filter": {}, "readPreference": "secondary"}},
+				{"object": "testRunner", "name": "assertDocumentCount", "arguments": {"count": 1}}
+			]}`,
+		},
+		{
+			name:     "operation without a name",
+			workload: `{"operations": [{"object": "collection"}]}`,
+			problems: []string{"operations[0]: operation has no name"},
+		},
+		{
+			name:     "negative weight",
+			workload: `{"operations": [{"object": "collection", "name": "find", "weight": -1}]}`,
+			problems: []string{"operations[0] find: weight must not be negative"},
+		},
+		{
+			name:     "unrecognized object",
+			workload: `{"operations": [{"object": "database", "name": "runCommand"}]}`,
+			problems: []string{`operations[0] runCommand: unrecognized object "database"`},
+		},
+		{
+			name:     "unrecognized collection operation",
+			workload: `{"operations": [{"object": "collection", "name": "mapReduce"}]}`,
+			problems: []string{"operations[0] mapReduce: unrecognized collection operation"},
+		},
+		{
+			name:     "unrecognized operation of another object",
+			workload: `{"operations": [{"object": "session", "name": "startSession"}]}`,
+			problems: []string{"operations[0] startSession: unrecognized session operation"},
+		},
+		{
+			name:     "registered collection operation without an argument spec",
+			workload: `{"operations": [{"object": "collection", "name": "registeredWithoutSpec", "arguments": {"any": 1}}]}`,
+		},
+		{
+			name: "missing and unrecognized arguments",
+			workload: `{"operations": [
+				{"object": "collection", "name": "updateOne", "arguments": {"zeta": 1, "filter": {}, "alpha": 1}}
+			]}`,
+			problems: []string{
+				`operations[0] updateOne: missing required argument "update"`,
+				`operations[0] updateOne: unrecognized argument "alpha"`,
+				`operations[0] updateOne: unrecognized argument "zeta"`,
+			},
+		},
+		{
+			name: "collection entity",
+			workload: `{
+				"collections": [{"id": "other", "collectionName": "other"}],
+				"operations": [{"object": "other", "name": "find", "arguments": {"sort": {"x": 1}}}]
+			}`,
+		},
+		{
+			name: "client encryption entity",
+			workload: `{
+				"clientEncryptions": [{"id": "ce"}],
+				"operations": [
+					{"object": "ce", "name": "createDataKey", "arguments": {"kmsProvider": "local"}},
+					{"object": "ce", "name": "encrypt", "arguments": {"algorithm": "Indexed"}}
+				]
+			}`,
+			problems: []string{`operations[1] encrypt: missing required argument "value"`},
+		},
+		{
+			name: "transaction with an unknown client and a callback that does not use a collection",
+			workload: `{"operations": [{"object": "session", "name": "withTransaction", "arguments": {
+				"client": "nope",
+				"callback": [
+					{"object": "collection", "name": "insertOne", "arguments": {"document": {}}},
+					{"object": "testRunner", "name": "failPoint", "arguments": {"failPoint": {}}},
+					{"object": "collection", "name": "insertOne", "arguments": {"documentSize": 10, "size": 10}}
+				]
+			}}]}`,
+			problems: []string{
+				`operations[0] withTransaction: unknown client entity "nope"`,
+				`operations[0] withTransaction callback[1]: callback operations must use a collection, not "testRunner"`,
+				`operations[0] withTransaction callback[2] insertOne: unrecognized argument "size"`,
+			},
+		},
+		{
+			name: "transaction with a client entity",
+			workload: `{
+				"clients": [{"id": "other"}],
+				"operations": [{"object": "session", "name": "withTransaction", "arguments": {
+					"client": "other", "callback": []
+				}}]
+			}`,
+		},
+		{
+			name: "test cases and hooks",
+			workload: `{
+				"tests": [{"description": "first", "operations": [{"object": "collection", "name": "nope"}]}],
+				"hooks": {
+					"beforeLoop": [{"operations": [{"object": "collection"}]}],
+					"afterLoop": [{"command": "true"}, {"operations": [{"object": "testRunner", "name": "nope"}]}]
+				}
+			}`,
+			problems: []string{
+				"tests[0] (first)[0] nope: unrecognized collection operation",
+				"hooks.beforeLoop[0][0]: operation has no name",
+				"hooks.afterLoop[1][0] nope: unrecognized testRunner operation",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateWorkload([]byte(tc.workload), "")
+			if len(tc.problems) == 0 {
+				if err != nil {
+					t.Fatalf("expected the workload to be valid, got %v", err)
+				}
+				return
+			}
+			expected := "invalid workload:\n  " + strings.Join(tc.problems, "\n  ")
+			if err == nil || err.Error() != expected {
+				t.Fatalf("expected %q, got %v", expected, err)
+			}
+		})
+	}
+}
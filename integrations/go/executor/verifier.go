@@ -0,0 +1,174 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// verifier compares the results returned by the server against the results a workload expects.
+// The verifier is selected by the verifier field of the workload, e.g.
+//
+//	verifier: {name: tolerance, countTolerance: 2}
+//
+// and defaults to "default" if the field is omitted.
+type verifier interface {
+	// documentsMatch reports whether actual satisfies the expected document.
+	documentsMatch(expected, actual bson.Raw) bool
+	// countsMatch reports whether actual satisfies the expected count.
+	countsMatch(expected, actual int64) bool
+}
+
+// verifierFactory creates a verifier from the verifier document of a workload.
+type verifierFactory func(config bson.Raw) (verifier, error)
+
+var verifiers = make(map[string]verifierFactory)
+
+// registerVerifier makes a verifier available to workloads under the given name. It panics if a
+// verifier with the same name has already been registered.
+func registerVerifier(name string, factory verifierFactory) {
+	if _, ok := verifiers[name]; ok {
+		str := fmt.Sprintf("verifier %q registered twice", name)
+		panic(str)
+	}
+	verifiers[name] = factory
+}
+
+func init() {
+	registerVerifier("default", func(bson.Raw) (verifier, error) {
+		return defaultVerifier{}, nil
+	})
+	registerVerifier("exact", func(bson.Raw) (verifier, error) {
+		return exactVerifier{}, nil
+	})
+	registerVerifier("tolerance", newToleranceVerifier)
+}
+
+func newVerifier(config bson.Raw) (verifier, error) {
+	name := "default"
+	if config != nil {
+		if val, err := config.LookupErr("name"); err == nil {
+			name = val.StringValue()
+		}
+	}
+
+	factory, ok := verifiers[name]
+	if !ok {
+		return nil, fmt.Errorf("unrecognized verifier: %v", name)
+	}
+	return factory(config)
+}
+
+// defaultVerifier compares documents by value: both documents must have the same fields and numeric
+// values are equal if they represent the same number, regardless of their BSON type. Counts must
+// match exactly.
+type defaultVerifier struct{}
+
+func (defaultVerifier) documentsMatch(expected, actual bson.Raw) bool {
+	expectedElems, err := expected.Elements()
+	if err != nil {
+		return false
+	}
+	actualElems, err := actual.Elements()
+	if err != nil || len(expectedElems) != len(actualElems) {
+		return false
+	}
+
+	for _, elem := range expectedElems {
+		actualVal, err := actual.LookupErr(elem.Key())
+		if err != nil || !valuesMatch(elem.Value(), actualVal) {
+			return false
+		}
+	}
+	return true
+}
+
+func (defaultVerifier) countsMatch(expected, actual int64) bool {
+	return expected == actual
+}
+
+func valuesMatch(expected, actual bson.RawValue) bool {
+	if expectedNum, ok := numericValue(expected); ok {
+		actualNum, ok := numericValue(actual)
+		return ok && expectedNum == actualNum
+	}
+	if expected.Type != actual.Type {
+		return false
+	}
+
+	switch expected.Type {
+	case bson.TypeEmbeddedDocument:
+		return defaultVerifier{}.documentsMatch(expected.Document(), actual.Document())
+	case bson.TypeArray:
+		expectedVals, err := expected.Array().Values()
+		if err != nil {
+			return false
+		}
+		actualVals, err := actual.Array().Values()
+		if err != nil || len(expectedVals) != len(actualVals) {
+			return false
+		}
+		for i := range expectedVals {
+			if !valuesMatch(expectedVals[i], actualVals[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return bytes.Equal(expected.Value, actual.Value)
+}
+
+func numericValue(val bson.RawValue) (float64, bool) {
+	switch val.Type {
+	case bson.TypeInt32:
+		return float64(val.Int32()), true
+	case bson.TypeInt64:
+		return float64(val.Int64()), true
+	case bson.TypeDouble:
+		return val.Double(), true
+	}
+	return 0, false
+}
+
+// exactVerifier requires documents to be byte-for-byte identical, including field order and BSON
+// types. Counts must match exactly.
+type exactVerifier struct{}
+
+func (exactVerifier) documentsMatch(expected, actual bson.Raw) bool {
+	return bytes.Equal(expected, actual)
+}
+
+func (exactVerifier) countsMatch(expected, actual int64) bool {
+	return expected == actual
+}
+
+// toleranceVerifier compares documents like defaultVerifier but accepts counts that differ from the
+// expected count by at most countTolerance, which is useful for workloads whose counts depend on
+// how many operations were retried.
+type toleranceVerifier struct {
+	defaultVerifier
+	countTolerance int64
+}
+
+func newToleranceVerifier(config bson.Raw) (verifier, error) {
+	v := toleranceVerifier{}
+	val, err := config.LookupErr("countTolerance")
+	if err != nil {
+		return nil, fmt.Errorf("tolerance verifier requires a countTolerance option")
+	}
+	tolerance, ok := numericValue(val)
+	if !ok || tolerance < 0 {
+		return nil, fmt.Errorf("countTolerance must be a non-negative number, got %v", val)
+	}
+	v.countTolerance = int64(tolerance)
+	return v, nil
+}
+
+func (v toleranceVerifier) countsMatch(expected, actual int64) bool {
+	diff := expected - actual
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= v.countTolerance
+}
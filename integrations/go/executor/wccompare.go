@@ -0,0 +1,177 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// writeConcernComparison runs the same write operations alongside the workload once per write
+// concern, e.g. {w: "majority"} and {w: 1}, so that the cost of a stronger write concern during
+// maintenance can be read off the results. The operations run against the workload collection,
+// with a client of their own, until the workload stops.
+type writeConcernComparison struct {
+	// exactly two write concerns; the deltas in the results are those of the first relative to
+	// the second
+	WriteConcerns []*writeConcernSpec `bson:"writeConcerns"`
+	Operations    []*operation
+}
+
+// WriteConcernStats holds the outcome counts and latencies of the comparison operations run with
+// one write concern.
+type WriteConcernStats struct {
+	WriteConcern string         `json:"writeConcern"`
+	NumErrors    int            `json:"numErrors"`
+	NumFailures  int            `json:"numFailures"`
+	NumSuccesses int            `json:"numSuccesses"`
+	LatencyMS    LatencySummary `json:"latencyMS"`
+	LastError    string         `json:"lastError,omitempty"`
+
+	latencies []float64
+}
+
+// WriteConcernComparison reports the two variants of a write concern comparison and how the first
+// differed from the second.
+type WriteConcernComparison struct {
+	Variants          []*WriteConcernStats `json:"variants"`
+	ErrorDelta        int                  `json:"errorDelta"`
+	P99LatencyDeltaMS float64              `json:"p99LatencyDeltaMS"`
+}
+
+// comparisonOperations are the operations a write concern comparison may run.
+var comparisonOperations = map[string]bool{
+	"insertOne":         true,
+	"insertMany":        true,
+	"updateOne":         true,
+	"updateMany":        true,
+	"replaceOne":        true,
+	"deleteOne":         true,
+	"deleteMany":        true,
+	"bulkWrite":         true,
+	"findOneAndDelete":  true,
+	"findOneAndReplace": true,
+	"findOneAndUpdate":  true,
+}
+
+// writeConcernComparer runs a write concern comparison.
+type writeConcernComparer struct {
+	config *writeConcernComparison
+	colls  []*mongo.Collection
+
+	mu    sync.Mutex
+	stats []*WriteConcernStats
+	wg    sync.WaitGroup
+}
+
+// newWriteConcernComparer validates config and clones coll, which must belong to a client that is
+// not monitored, once per write concern.
+func newWriteConcernComparer(config *writeConcernComparison, coll *mongo.Collection) (*writeConcernComparer, error) {
+	if len(config.WriteConcerns) != 2 {
+		return nil, errors.New("writeConcernComparison requires exactly two write concerns")
+	}
+	for _, op := range config.Operations {
+		if !comparisonOperations[op.Name] {
+			return nil, fmt.Errorf("writeConcernComparison does not support %v operations", op.Name)
+		}
+	}
+
+	c := &writeConcernComparer{config: config}
+	for _, spec := range config.WriteConcerns {
+		wc, err := spec.writeConcern()
+		if err != nil {
+			return nil, err
+		}
+		clone, err := coll.Clone(options.Collection().SetWriteConcern(wc))
+		if err != nil {
+			return nil, err
+		}
+		c.colls = append(c.colls, clone)
+		c.stats = append(c.stats, &WriteConcernStats{WriteConcern: spec.String()})
+	}
+	return c, nil
+}
+
+// run runs the operations with every write concern concurrently until ctx is done.
+func (c *writeConcernComparer) run(ctx context.Context, r *workloadRunner) {
+	for i := range c.colls {
+		coll, stats := c.colls[i], c.stats[i]
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.runVariant(ctx, r, coll, stats)
+		}()
+	}
+	c.wg.Wait()
+}
+
+func (c *writeConcernComparer) runVariant(ctx context.Context, r *workloadRunner, coll *mongo.Collection, stats *WriteConcernStats) {
+	for {
+		for _, op := range c.config.Operations {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			start := time.Now()
+			// the operations are not run with ctx so that stopping them does not count as errors
+			pass, err := collectionOperations[op.Name](context.Background(), r, coll, op)
+			latency := milliseconds(time.Since(start))
+
+			c.mu.Lock()
+			switch {
+			case err != nil:
+				stats.NumErrors++
+				stats.LastError = err.Error()
+			case pass:
+				stats.NumSuccesses++
+				stats.latencies = append(stats.latencies, latency)
+			default:
+				stats.NumFailures++
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+// summary returns the statistics of the comparison so far.
+func (c *writeConcernComparer) summary() *WriteConcernComparison {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	comparison := &WriteConcernComparison{}
+	for _, stats := range c.stats {
+		copied := *stats
+		copied.LatencyMS = summarizeLatencies(stats.latencies)
+		copied.latencies = nil
+		comparison.Variants = append(comparison.Variants, &copied)
+	}
+	first, second := comparison.Variants[0], comparison.Variants[1]
+	comparison.ErrorDelta = first.NumErrors - second.NumErrors
+	comparison.P99LatencyDeltaMS = first.LatencyMS.P99 - second.LatencyMS.P99
+	return comparison
+}
+
+// String describes the write concern, e.g. "w:majority,j:true".
+func (spec *writeConcernSpec) String() string {
+	var parts []string
+	if spec.W != nil {
+		parts = append(parts, fmt.Sprintf("w:%v", spec.W))
+	}
+	if spec.J != nil {
+		parts = append(parts, fmt.Sprintf("j:%v", *spec.J))
+	}
+	if spec.WTimeoutMS > 0 {
+		parts = append(parts, fmt.Sprintf("wtimeoutMS:%v", spec.WTimeoutMS))
+	}
+	if len(parts) == 0 {
+		return "default"
+	}
+	return strings.Join(parts, ",")
+}
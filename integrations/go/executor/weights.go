@@ -0,0 +1,51 @@
+package executor
+
+// weightedSelection makes each iteration of the workload run operations picked at random in
+// proportion to their weight, e.g. 80% find, 15% updateOne and 5% insertOne, instead of every
+// operation in order. This approximates the mix of operations of production traffic more closely.
+type weightedSelection struct {
+	// seed of the random number generator used when the executor is not given one with
+	// Options.Seed; zero seeds it from the clock
+	Seed int64
+	// number of operations picked per iteration; defaults to the number of operations
+	OperationsPerIteration int `bson:"operationsPerIteration"`
+}
+
+// selectOperations returns the operations to run in the next iteration: all of them in order, or
+// a weighted random selection if the workload configures one. Operations without a weight have a
+// weight of 1.
+func (r *workloadRunner) selectOperations(workload *driverWorkload) []*operation {
+	config := workload.WeightedSelection
+	if config == nil || len(workload.Operations) == 0 {
+		return workload.Operations
+	}
+	weights := make([]float64, len(workload.Operations))
+	var total float64
+	for i, op := range workload.Operations {
+		weights[i] = 1
+		if op.Weight != nil {
+			weights[i] = *op.Weight
+		}
+		total += weights[i]
+	}
+	n := config.OperationsPerIteration
+	if n <= 0 {
+		n = len(workload.Operations)
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	selected := make([]*operation, 0, n)
+	for len(selected) < n {
+		x := r.rng.Float64() * total
+		for i, w := range weights {
+			x -= w
+			if x < 0 || i == len(weights)-1 {
+				selected = append(selected, workload.Operations[i])
+				break
+			}
+		}
+	}
+	return selected
+}
@@ -0,0 +1,234 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// A workload with a concurrency above 1, or run with Options.Concurrency, runs its operation loop
+// in that many workers at once, to resemble the load of an application rather than that of a single
+// thread. The runner of the workload is the first worker. Each other worker is a runner of its own
+// with a client of its own, its own client and collection entities and a copy of the workload, and
+// its outcomes are merged into the results of the first worker when the loop ends, see mergeWorker.
+//
+// The workers share the end-to-end metrics, the pacing, the routing checks, the SDAM events and the
+// error count the error budget applies to of the first worker, and the first worker passes the
+// workloads it reloads on to them. The checkpoints of the first worker add up the counters the
+// workers publish at their iteration boundaries. Command events, errors and failures of the other workers are kept in memory
+// until the loop ends rather than flushed while it runs, and the statistics of the other features
+// of the workload, e.g. transactions or tailing, only cover the first worker.
+
+// checkConcurrency returns an error if the workload uses a feature that only the first worker
+// would apply.
+func checkConcurrency(workload *driverWorkload, opts Options) error {
+	switch {
+	case workload.EndpointFailover != nil:
+		return errors.New("the workload has an endpoint failover, which cannot be combined with a concurrency above 1")
+	case opts.ControlURI != "":
+		return errors.New("writes cannot be mirrored to a control cluster with a concurrency above 1")
+	}
+	return nil
+}
+
+// newWorker connects the client and creates the client and collection entities of worker id, which
+// runs a copy of workload. Its randomized behavior is derived from the seed of r. The worker must be
+// closed when the loop ends.
+func (r *workloadRunner) newWorker(ctx context.Context, id int, workload *driverWorkload) (*workloadRunner, error) {
+	w := &workloadRunner{
+		uri:         r.uri,
+		tailers:     make(map[string]*tailer),
+		hostClients: make(map[string]*mongo.Client),
+		clients:     make(map[string]*mongo.Client),
+		collections: make(map[string]*mongo.Collection),
+
+		clientEncryptions: make(map[string]*clientEncryption),
+		collectionClients: make(map[string]string),
+		outputCollections: make(map[string]*mongo.Collection),
+		declaredIndexes:   make(map[string]*declaredIndex),
+		mongos:            newMongosTracker(),
+		pinning:           newPinningTracker(),
+		routing:           r.routing,
+		latency:           newLatencyTracker(),
+		topology:          newTopologyTracker(),
+		sdam:              r.sdam,
+		opStats:           make(map[string]*OperationStats),
+		runErrors:         r.runErrors,
+		reloaded:          make(chan *driverWorkload, 1),
+		verifier:          r.verifier,
+		testName:          r.testName,
+		limits:            r.limits,
+
+		rng:  rand.New(rand.NewSource(r.results.Seed + int64(id))),
+		text: newTextGenerator(r.results.Seed + int64(id)),
+
+		server:     r.server,
+		serverless: r.serverless,
+		kms:        r.kms,
+
+		onlineArchiveURI: r.onlineArchiveURI,
+		captureCommands:  r.captureCommands,
+	}
+	w.results.Seed = r.results.Seed
+	w.clientOpts = options.Client().ApplyURI(r.uri).
+		SetMonitor(w.commandMonitor()).
+		SetServerMonitor(w.serverMonitor()).
+		SetPoolMonitor(w.poolMonitor())
+	if r.clientOpts.Dialer != nil {
+		w.clientOpts.SetDialer(r.clientOpts.Dialer)
+	}
+	if r.clientOpts.DisableOCSPEndpointCheck != nil {
+		w.clientOpts.SetDisableOCSPEndpointCheck(*r.clientOpts.DisableOCSPEndpointCheck)
+	}
+
+	client, err := mongo.Connect(ctx, w.clientOpts)
+	if err != nil {
+		return nil, err
+	}
+	w.client = client
+	w.coll = client.Database(workload.Database).Collection(workload.Collection)
+	w.results.Clients = make(map[string]*ClientStats)
+	if err = w.createClientEntities(ctx, r.uri, workload.Clients); err != nil {
+		w.close()
+		return nil, err
+	}
+	if err = w.createCollectionEntities(workload.Collections); err != nil {
+		w.close()
+		return nil, err
+	}
+	if err = w.createClientEncryptionEntities(workload.ClientEncryptions); err != nil {
+		w.close()
+		return nil, err
+	}
+	w.trackOperations(workload.Operations)
+	return w, nil
+}
+
+// close drops the output collections of the worker, disables its fail points, closes its client
+// encryption entities and disconnects its clients.
+func (r *workloadRunner) close() {
+	r.dropOutputCollections()
+	r.disableFailPoints()
+	r.closeClientEncryptions()
+	r.disconnectClients()
+	_ = r.client.Disconnect(context.Background())
+}
+
+// runWorkers runs the operation loop of each of the workers with a copy of workload until done is
+// closed, and calls stop as soon as any of them returns, so that an error budget breached by one
+// worker stops them all. The returned channel is closed once every worker has returned and ended
+// its tailers and transaction.
+func (r *workloadRunner) runWorkers(workers []*workloadRunner, done <-chan struct{}, stop func(), workload *driverWorkload) <-chan struct{} {
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		w.metrics = r.metrics
+		w.pacer = r.pacer
+		copied := *workload
+		wg.Add(1)
+		go func(w *workloadRunner) {
+			defer wg.Done()
+			defer func() {
+				if p := recover(); p != nil {
+					w.recordPanic(p)
+					stop()
+				}
+			}()
+			w.runLoop(done, nil, &copied)
+			stop()
+			w.stopTailers()
+			w.endTransaction()
+		}(w)
+	}
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	return finished
+}
+
+// mergeWorker adds the outcomes of worker w, whose loop has ended, to the results of r. Records of
+// w beyond the limits of r are counted as dropped.
+func (r *workloadRunner) mergeWorker(w *workloadRunner) {
+	r.results.NumErrors += w.results.NumErrors
+	r.results.NumFailures += w.results.NumFailures
+	r.results.NumSuccesses += w.results.NumSuccesses
+	r.results.NumIterations += w.results.NumIterations
+	r.results.NumAppRetries += w.results.NumAppRetries
+	r.results.PinningViolations += w.results.PinningViolations
+	for kind, n := range w.results.ErrorKinds {
+		if r.results.ErrorKinds == nil {
+			r.results.ErrorKinds = make(map[string]int)
+		}
+		r.results.ErrorKinds[kind] += n
+	}
+	if r.results.BudgetBreach == nil {
+		r.results.BudgetBreach = w.results.BudgetBreach
+	}
+	if r.results.HarnessError == "" {
+		r.results.HarnessError = w.results.HarnessError
+	}
+
+	for _, stats := range w.results.Operations {
+		merged, ok := r.opStats[stats.Name]
+		if !ok {
+			merged = &OperationStats{Name: stats.Name}
+			r.opStats[stats.Name] = merged
+			r.results.Operations = append(r.results.Operations, merged)
+		}
+		merged.NumErrors += stats.NumErrors
+		merged.NumFailures += stats.NumFailures
+		merged.NumSuccesses += stats.NumSuccesses
+		merged.NumAppRetries += stats.NumAppRetries
+	}
+	for id, stats := range w.results.Clients {
+		if merged, ok := r.results.Clients[id]; ok {
+			merged.NumErrors += stats.NumErrors
+			merged.NumFailures += stats.NumFailures
+			merged.NumSuccesses += stats.NumSuccesses
+		}
+	}
+	r.latency.merge(w.latency)
+	for name, index := range w.declaredIndexes {
+		r.declaredIndexes[name] = index
+	}
+
+	r.results.DroppedErrors += w.results.DroppedErrors
+	for _, record := range w.results.Errors {
+		if !withinLimit(r.numErrors, r.limits.maxErrors) {
+			r.results.DroppedErrors++
+			continue
+		}
+		r.numErrors++
+		r.results.Errors = append(r.results.Errors, record)
+	}
+	r.results.DroppedFailures += w.results.DroppedFailures
+	for _, record := range w.results.Failures {
+		if !withinLimit(r.numFailures, r.limits.maxFailures) {
+			r.results.DroppedFailures++
+			continue
+		}
+		r.numFailures++
+		r.results.Failures = append(r.results.Failures, record)
+	}
+
+	w.eventsMu.Lock()
+	events := w.results.Events
+	dropped := w.results.DroppedEvents
+	w.eventsMu.Unlock()
+	r.eventsMu.Lock()
+	defer r.eventsMu.Unlock()
+	r.results.DroppedEvents += dropped
+	for _, evt := range events {
+		if !withinLimit(r.numEvents, r.limits.maxEvents) {
+			r.results.DroppedEvents++
+			continue
+		}
+		r.numEvents++
+		r.results.Events = append(r.results.Events, evt)
+	}
+}
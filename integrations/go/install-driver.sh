@@ -7,5 +7,20 @@ cd integrations/$DRIVER_DIRNAME
 
 export PATH=$GOROOT/bin:$PATH
 
-go get go.mongodb.org/mongo-driver@master
-go build -o executor workload-executor.go
+# GO_DRIVER_VERSION selects the driver release the executor is built against, e.g.
+# "v1.11.0", or a branch such as "master" for the latest unstable driver.
+# GO_DRIVER_REVISION pins the driver to an arbitrary commit instead, e.g. the head of
+# a pull request. A commit in a fork is fetched from GO_DRIVER_REPOSITORY, the module
+# path of the fork, e.g. "github.com/someone/mongo-go-driver".
+if [ -n "$GO_DRIVER_REVISION" ] && [ -n "$GO_DRIVER_REPOSITORY" ]; then
+  go mod edit -replace go.mongodb.org/mongo-driver=$GO_DRIVER_REPOSITORY@$GO_DRIVER_REVISION
+  go mod tidy
+elif [ -n "$GO_DRIVER_REVISION" ]; then
+  go get go.mongodb.org/mongo-driver@$GO_DRIVER_REVISION
+else
+  go get go.mongodb.org/mongo-driver@${GO_DRIVER_VERSION:-master}
+fi
+go list -m go.mongodb.org/mongo-driver
+mkdir -p bin
+go build -tags "$GO_BUILD_TAGS" -o bin/executor .
+go build -o bin/watchdog ./watchdog
@@ -0,0 +1,247 @@
+// The watchdog launches the workload executor, forwards termination signals to it and watches the
+// heartbeat file the executor rewrites at iteration boundaries. If the heartbeat stops advancing
+// for longer than -stall-timeout, the watchdog sends the executor SIGQUIT, which makes the Go
+// runtime print the stacks of every goroutine and exit, and saves the dump. However the executor
+// dies, the watchdog makes sure results.json and events.json exist when it exits, so that astrolabe
+// reports the failure instead of a missing file, and describes what happened in watchdog.json.
+//
+// Usage: watchdog [flags] executor [executor arguments]
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"go-executor/executor"
+)
+
+var stallTimeout = flag.Duration("stall-timeout", 5*time.Minute, "time without a heartbeat after which the executor is considered stuck")
+var quitGrace = flag.Duration("quit-grace", 10*time.Second, "time the executor is given to write its goroutine dump before it is killed")
+
+// Exit statuses of an executor that died, see workload-executor.go. The other statuses, e.g. those of
+// a breached error budget or of operations that errored or failed, are regular outcomes of the
+// workload.
+const (
+	setupErrorExitCode   = 4
+	harnessErrorExitCode = 5
+)
+
+// Diagnostics is written to watchdog.json when the executor exits.
+type Diagnostics struct {
+	ExitStatus int  `json:"exitStatus"`
+	Stalled    bool `json:"stalled"`
+	// seconds since the Unix epoch of the last heartbeat seen, zero if there was none
+	LastHeartbeat float64 `json:"lastHeartbeat,omitempty"`
+	// path of the goroutine dump, if the executor stalled
+	GoroutineDump string `json:"goroutineDump,omitempty"`
+	// files the watchdog wrote because the executor did not
+	SubstitutedFiles []string `json:"substitutedFiles,omitempty"`
+}
+
+// teeWriter copies the stderr of the executor to the stderr of the watchdog and, once capture is
+// called, to the goroutine dump.
+type teeWriter struct {
+	mu   sync.Mutex
+	dump io.Writer
+}
+
+func (w *teeWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.dump != nil {
+		_, _ = w.dump.Write(p)
+	}
+	return os.Stderr.Write(p)
+}
+
+func (w *teeWriter) capture(dump io.Writer) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.dump = dump
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] executor [executor arguments]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir, _ := os.Getwd()
+	heartbeatPath := filepath.Join(dir, "heartbeat.json")
+	dumpPath := filepath.Join(dir, "goroutines.txt")
+	_ = os.Remove(heartbeatPath)
+
+	stderr := &teeWriter{}
+	cmd := exec.Command(flag.Arg(0), flag.Args()[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = stderr
+	cmd.Env = append(os.Environ(), "ASTROLABE_HEARTBEAT_FILE="+heartbeatPath)
+	if err := cmd.Start(); err != nil {
+		str := fmt.Sprintf("starting the executor failed: %v", err)
+		panic(str)
+	}
+
+	// astrolabe signals the whole process group, but the executor may run in its own
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			_ = cmd.Process.Signal(sig)
+		}
+	}()
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	diagnostics := &Diagnostics{}
+	started := time.Now()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	var err error
+wait:
+	for {
+		select {
+		case err = <-exited:
+			break wait
+		case <-ticker.C:
+		}
+
+		last := started
+		if info, statErr := os.Stat(heartbeatPath); statErr == nil {
+			last = info.ModTime()
+			diagnostics.LastHeartbeat = float64(last.UnixNano()) / float64(time.Second)
+		}
+		if time.Since(last) < *stallTimeout {
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "watchdog: no heartbeat from the executor for %v, collecting a goroutine dump\n",
+			time.Since(last).Round(time.Second))
+		diagnostics.Stalled = true
+		err = quit(cmd, stderr, dumpPath, exited)
+		diagnostics.GoroutineDump = dumpPath
+		break wait
+	}
+
+	diagnostics.ExitStatus = exitStatus(err)
+	died := diagnostics.Stalled || signaled(err) ||
+		diagnostics.ExitStatus == setupErrorExitCode || diagnostics.ExitStatus == harnessErrorExitCode
+	if died {
+		reason := fmt.Sprintf("the workload executor died: %v", err)
+		if diagnostics.Stalled {
+			reason = fmt.Sprintf("the workload executor stalled for more than %v", *stallTimeout)
+		}
+		diagnostics.SubstitutedFiles = substituteFiles(dir, reason)
+	}
+
+	data, jsonErr := json.MarshalIndent(diagnostics, "", "  ")
+	if jsonErr == nil {
+		jsonErr = ioutil.WriteFile(filepath.Join(dir, "watchdog.json"), data, 0644)
+	}
+	if jsonErr != nil {
+		fmt.Fprintf(os.Stderr, "watchdog: writing watchdog.json failed: %v\n", jsonErr)
+	}
+	os.Exit(diagnostics.ExitStatus)
+}
+
+// quit asks the stalled executor for a goroutine dump, which is written to dumpPath, and kills it
+// if it has not exited after -quit-grace. It returns the error of the executor's exit.
+func quit(cmd *exec.Cmd, stderr *teeWriter, dumpPath string, exited <-chan error) error {
+	dump, err := os.Create(dumpPath)
+	if err == nil {
+		defer dump.Close()
+		stderr.capture(dump)
+		defer stderr.capture(nil)
+	} else {
+		fmt.Fprintf(os.Stderr, "watchdog: creating %s failed: %v\n", dumpPath, err)
+	}
+
+	// SIGQUIT is not supported on Windows, where the executor is killed right away
+	if err := cmd.Process.Signal(syscall.SIGQUIT); err != nil {
+		_ = cmd.Process.Kill()
+	}
+	select {
+	case err = <-exited:
+		return err
+	case <-time.After(*quitGrace):
+		_ = cmd.Process.Kill()
+		return <-exited
+	}
+}
+
+// exitStatus returns the exit status of a process that exited with err, or 1 if it did not exit
+// normally.
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() > 0 {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+// signaled reports whether a process that exited with err was terminated by a signal.
+func signaled(err error) bool {
+	exitErr, ok := err.(*exec.ExitError)
+	return ok && exitErr.ExitCode() == -1
+}
+
+// substituteFiles writes results.json and events.json in dir if the executor did not, recording
+// reason as a failure, and returns the names of the files it wrote. Records the executor flushed
+// to events.json.partial before it died are kept.
+func substituteFiles(dir string, reason string) []string {
+	now := time.Now()
+	results := &executor.Results{NumFailures: 1}
+	results.Failures = []executor.ErrorRecord{{
+		Error: reason,
+		Time:  float64(now.UnixNano()) / float64(time.Second),
+		RecordTime: executor.RecordTime{
+			Timestamp:   now.UTC().Format(time.RFC3339Nano),
+			TimestampMS: now.UnixNano() / int64(time.Millisecond),
+		},
+	}}
+
+	var written []string
+	resultsPath := filepath.Join(dir, "results.json")
+	if _, err := os.Stat(resultsPath); os.IsNotExist(err) {
+		if err = executor.ResultsFileSink(resultsPath).WriteResults(results); err != nil {
+			fmt.Fprintf(os.Stderr, "watchdog: writing results.json failed: %v\n", err)
+		} else {
+			written = append(written, "results.json")
+		}
+	}
+
+	eventsPath := filepath.Join(dir, "events.json")
+	if _, err := os.Stat(eventsPath); os.IsNotExist(err) {
+		sink := executor.EventsFileSink(eventsPath)
+		if rs, ok := sink.(executor.ResumeSink); ok {
+			_ = rs.Resume()
+		}
+		if err = sink.WriteResults(results); err != nil {
+			fmt.Fprintf(os.Stderr, "watchdog: writing events.json failed: %v\n", err)
+		} else {
+			written = append(written, "events.json")
+		}
+	}
+	return written
+}
@@ -1,265 +1,394 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
-	"math"
 	"os"
 	"os/signal"
-	"reflect"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"go-executor/executor"
 )
 
-var emptyDoc = []byte{5, 0, 0, 0, 0}
+var tapOutput = flag.Bool("tap", false, "write Test Anything Protocol output to stdout on exit")
+var serverless = flag.Bool("serverless", false, "evaluate runOnRequirements as if the cluster is a serverless instance")
+var testName = flag.String("test-name", "", "run only the test case with the given description")
+var hostMap = flag.String("host-map", "", "comma-separated `from=to` address rewrites applied when connecting to servers")
+var maxEvents = flag.Int("max-events", 0, "maximum number of command events written to events.json (0 for the default, -1 for no limit)")
+var maxErrors = flag.Int("max-errors", 0, "maximum number of errors written to events.json (0 for the default, -1 for no limit)")
+var maxFailures = flag.Int("max-failures", 0, "maximum number of failures written to events.json (0 for the default, -1 for no limit)")
+var flushInterval = flag.Duration("flush-interval", 30*time.Second, "how often recorded events are appended to events.json.partial while the workload runs (0 to disable)")
+var toxiproxy = flag.String("toxiproxy", "", "route connections through the toxiproxy server whose API is at `url` and apply the faults of the workload")
+var metricsBucket = flag.Duration("metrics-bucket", 10*time.Second, "width of the time buckets the throughput in metrics.json is counted in")
+var clockSkewInterval = flag.Duration("clock-skew-interval", time.Minute, "how often the clock of the executor is compared with that of the primary, recorded in results.json (0 to disable)")
+var maxHeapGrowth = flag.Float64("max-heap-growth", 0, "fail the run and write heap.pprof if the heap of the executor grows faster than this many MB per hour (0 to disable)")
+var heapSampleInterval = flag.Duration("heap-sample-interval", 10*time.Second, "how often the heap is sampled when -max-heap-growth is set")
+var disableOCSPEndpointCheck = flag.Bool("disable-ocsp-endpoint-check", false, "do not contact OCSP responders when the server does not staple an OCSP response")
+var seed = flag.Int64("seed", 0, "seed of all randomized behavior of the executor, recorded in results.json to replay a run (0 to pick one)")
+var resultsSchemaVersion = flag.Int("results-schema-version", 0, "version of the results.json format to write, up to the latest supported (0 for the version requested in ASTROLABE_RESULTS_SCHEMA_VERSION, or 1)")
+var capabilities = flag.Bool("capabilities", false, "print the supported workload and results formats and control channels as JSON and exit")
+var selfTest = flag.Bool("selftest", false, "connect to connection-string, insert, find and clean up a document, print a JSON report of the server capabilities and exit")
+var reportDatabase = flag.String("report-database", "astrolabe", "database of the deployment at ASTROLABE_REPORT_URI that metrics and results are written to")
+var captureCommands = flag.Bool("capture-commands", false, "record the command documents in events.json, so that the run can be replayed with the replay subcommand")
+var replaySpeed = flag.Float64("replay-speed", 1, "how many times faster than the original run the replay subcommand runs the commands")
+var concurrency = flag.Int("concurrency", 0, "number of workers that run the operation loop at once, each with its own client (0 for the concurrency of the workload, or 1)")
+var opsPerSecond = flag.Float64("ops-per-second", 0, "pace the operation loop to this many operations per second across its workers, overriding the pacing of the workload (0 for no limit)")
+var operationDelay = flag.Duration("operation-delay", 0, "wait this long before each operation of each worker, overriding the pacing of the workload")
+var rampUp = flag.Duration("ramp-up", 0, "ramp the rate of -ops-per-second up from zero over this long")
+var statusAddr = flag.String("status-addr", "", "serve /healthz, /readyz and /status over HTTP on `address`, e.g. :8080, for running the executor as a pod (defaults to ASTROLABE_STATUS_ADDR)")
+var workloadFile = flag.String("workload-file", "", "read the workload spec from `path` instead of the command line and reload it on SIGHUP, or from stdin if path is -")
+
+// stringList is a flag that may be given several times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, " ")
+}
 
-type driverWorkload struct {
-	Collection string
-	Database   string
-	Operations []*operation
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
 
-type operation struct {
-	Object    string
-	Name      string
-	Arguments bson.Raw
-	Result    interface{}
+var failoverURIs stringList
+
+func init() {
+	flag.Var(&failoverURIs, "failover-uri", "a connection string the endpointFailover of the workload may switch to; may be given several times")
 }
 
-var specTestRegistry = bson.NewRegistryBuilder().
-	RegisterTypeMapEntry(bson.TypeEmbeddedDocument, reflect.TypeOf(bson.Raw{})).Build()
+// The executor has a subcommand per entrypoint, which share the flags, the output files and the
+// shutdown on SIGINT or SIGTERM. Without a subcommand it runs a legacy workload, which is how
+// astrolabe invokes it.
+const (
+	runLegacyCommand  = "run-legacy"
+	runUnifiedCommand = "run-unified"
+	validateCommand   = "validate"
+	replayCommand     = "replay"
+)
+
+// Exit statuses of the executor. Invalid command lines exit with status 2. astrolabe recognizes
+// budgetBreachedExitCode and stops the maintenance of the cluster early, and does not relaunch an
+// executor that exits with harnessErrorExitCode, which would fail the same way again.
+const (
+	// the workload stopped early because its error budget was breached
+	budgetBreachedExitCode = 3
+	// connecting to the cluster or preparing it for the workload failed, see
+	// executor.ErrorCategorySetup
+	setupErrorExitCode = 4
+	// the workload is invalid or the executor failed, see executor.ErrorCategoryHarness
+	harnessErrorExitCode = 5
+	// the workload ran, but some of its operations returned errors
+	operationErrorExitCode = 6
+	// the workload ran, but some of its operations or checks failed
+	failureExitCode = 7
+)
 
-func executeInsertOne(coll *mongo.Collection, args bson.Raw) (*mongo.InsertOneResult, error) {
-	doc := emptyDoc
-	opts := options.InsertOne()
+// runExitCode returns the exit status of a run of the workload that returned results and err.
+// Failures take precedence over errors, since they point at the behavior of the driver rather than
+// at the availability of the cluster.
+func runExitCode(results *executor.Results, err error) int {
+	switch {
+	case err != nil && executor.ErrorCategory(err) == executor.ErrorCategorySetup:
+		return setupErrorExitCode
+	case err != nil:
+		return harnessErrorExitCode
+	case results.BudgetBreach != nil:
+		return budgetBreachedExitCode
+	case results.NumFailures > 0 || results.OutcomeFailures > 0:
+		return failureExitCode
+	case results.NumErrors > 0:
+		return operationErrorExitCode
+	}
+	return 0
+}
 
-	elems, _ := args.Elements()
-	for _, elem := range elems {
-		key := elem.Key()
-		val := elem.Value()
+// runSelfTest runs the self-test against connstring, writes its report to stdout and exits.
+func runSelfTest(connstring string, opts executor.Options) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
 
-		switch key {
-		case "document":
-			doc = val.Document()
-		default:
-			str := fmt.Sprintf("unrecognized insertOne option: %v", key)
-			panic(str)
-		}
+	report, err := executor.SelfTest(ctx, connstring, opts)
+	out, jsonErr := json.MarshalIndent(report, "", "  ")
+	if jsonErr != nil {
+		panic(jsonErr)
+	}
+	fmt.Println(string(out))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		cancel()
+		os.Exit(1)
 	}
+}
 
-	return coll.InsertOne(context.Background(), doc, opts)
+// resultsVersion returns the version of the results.json format to write: the one given by
+// -results-schema-version or requested by astrolabe, capped at the latest the executor supports.
+func resultsVersion() int {
+	version := *resultsSchemaVersion
+	if version == 0 {
+		version, _ = strconv.Atoi(os.Getenv("ASTROLABE_RESULTS_SCHEMA_VERSION"))
+	}
+	switch {
+	case version < 1:
+		return 1
+	case version > executor.LatestResultsSchemaVersion:
+		return executor.LatestResultsSchemaVersion
+	default:
+		return version
+	}
 }
 
-func executeFind(coll *mongo.Collection, args bson.Raw) (*mongo.Cursor, error) {
-	filter := emptyDoc
-	opts := options.Find()
-
-	elems, _ := args.Elements()
-	for _, elem := range elems {
-		key := elem.Key()
-		val := elem.Value()
-
-		switch key {
-		case "filter":
-			filter = val.Document()
-		case "sort":
-			opts = opts.SetSort(val.Document())
-		default:
-			str := fmt.Sprintf("unrecognized find option: %v", key)
-			panic(str)
-		}
+// runReplay replays the commands captured in an events.json against a cluster, writes the report
+// to stdout and exits. SIGINT or SIGTERM stops the replay early.
+func runReplay() {
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	events, err := ioutil.ReadFile(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reading events failed: %v\n", err)
+		os.Exit(2)
 	}
 
-	return coll.Find(context.Background(), filter, opts)
-}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
-// create an update document or pipeline from a bson.RawValue
-func createUpdate(updateVal bson.RawValue) (interface{}, error) {
-	switch updateVal.Type {
-	case bson.TypeEmbeddedDocument:
-		return updateVal.Document(), nil
-	case bson.TypeArray:
-		var updateDocs []bson.Raw
-		docs, _ := updateVal.Array().Values()
-		for _, doc := range docs {
-			updateDocs = append(updateDocs, doc.Document())
-		}
+		<-c
+		cancel()
+	}()
 
-		return updateDocs, nil
-	default:
-		str := fmt.Sprintf("unrecognized update type: %v", updateVal.Type)
-		panic(str)
+	report, err := executor.Replay(ctx, flag.Arg(0), events, executor.ReplayOptions{Speed: *replaySpeed})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		panic(err)
 	}
+	fmt.Println(string(out))
+}
 
-	return nil, nil
+// stdinSpec is given in place of a workload spec or the -workload-file to read the spec from
+// stdin, for workloads too large for the command line.
+const stdinSpec = "-"
+
+// readWorkloadFile returns the contents of the -workload-file, or of stdin if it is stdinSpec. It
+// exits if the file cannot be read.
+func readWorkloadFile() []byte {
+	return readSpec(*workloadFile)
 }
 
-func executeUpdateOne(coll *mongo.Collection, args bson.Raw) (*mongo.UpdateResult, error) {
-	filter := emptyDoc
-	var update interface{} = emptyDoc
+// readSpec returns the contents of the file at path, or of stdin if path is stdinSpec. It exits if
+// the file cannot be read.
+func readSpec(path string) []byte {
+	var spec []byte
 	var err error
-	opts := options.Update()
-
-	elems, _ := args.Elements()
-	for _, elem := range elems {
-		key := elem.Key()
-		val := elem.Value()
-
-		switch key {
-		case "filter":
-			filter = val.Document()
-		case "update":
-			update, err = createUpdate(val)
-			if err != nil {
-				return nil, err
-			}
-		default:
-			str := fmt.Sprintf("unrecognized updateOne option: %v", key)
-			panic(str)
-		}
+	if path == stdinSpec {
+		spec, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		spec, err = ioutil.ReadFile(path)
 	}
-	if opts.Upsert == nil {
-		opts = opts.SetUpsert(false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reading workload file failed: %v\n", err)
+		os.Exit(2)
 	}
-
-	return coll.UpdateOne(context.Background(), filter, update, opts)
+	return spec
 }
 
-func verifyInsertOneResult(actualResult *mongo.InsertOneResult, expectedResult interface{}) bool {
-	if expectedResult == nil {
-		return true
+// workloadArg returns the workload spec given on the command line, reading it from stdin if the
+// argument is stdinSpec.
+func workloadArg(arg string) []byte {
+	if arg == stdinSpec {
+		return readSpec(stdinSpec)
 	}
+	return []byte(arg)
+}
 
-	var expected mongo.InsertOneResult
-	if bson.Unmarshal(expectedResult.(bson.Raw), &expected) != nil {
-		return false
+// runValidate checks the workload given on the command line or by -workload-file without
+// connecting to a cluster and exits with status 1 if it is invalid.
+func runValidate() {
+	var spec []byte
+	switch {
+	case *workloadFile != "" && flag.NArg() == 0:
+		spec = readWorkloadFile()
+	case *workloadFile == "" && flag.NArg() == 1:
+		spec = workloadArg(flag.Arg(0))
+	default:
+		flag.Usage()
+		os.Exit(2)
 	}
-
-	expectedID := expected.InsertedID
-	if f, ok := expectedID.(float64); ok && f == math.Floor(f) {
-		expectedID = int32(f)
+	if err := executor.ValidateWorkload(spec, *testName); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-
-	return expectedID == nil || (actualResult != nil && expectedID == actualResult.InsertedID)
+	fmt.Println("workload is valid")
 }
 
-func verifyCursorResult(cur *mongo.Cursor, result interface{}) bool {
-	if result == nil {
-		return true
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [run-legacy] [flags] connection-string workload-spec|-\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s [run-legacy] [flags] -workload-file path connection-string\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s run-unified [flags] connection-string workload-spec|-\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s validate [flags] workload-spec|-\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s validate [flags] -workload-file path\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s [flags] -selftest connection-string\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s replay [flags] connection-string events.json\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s -capabilities\n", os.Args[0])
+		flag.PrintDefaults()
 	}
 
-	if cur == nil {
-		return false
+	command := runLegacyCommand
+	args := os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case runLegacyCommand, runUnifiedCommand, validateCommand, replayCommand:
+			command = args[0]
+			args = args[1:]
+		}
+	}
+	// the command line flag set exits on errors
+	_ = flag.CommandLine.Parse(args)
+
+	switch command {
+	case validateCommand:
+		runValidate()
+		return
+	case replayCommand:
+		runReplay()
+		return
 	}
 
-	defer func() {
-		err := cur.Close(context.Background())
+	if *capabilities {
+		out, err := json.MarshalIndent(executor.ExecutorCapabilities(), "", "  ")
 		if err != nil {
 			panic(err)
 		}
-	}()
-
-	for _, expected := range result.(bson.A) {
-		if !cur.Next(context.Background()) {
-			return false
-		}
-		if !bytes.Equal(expected.(bson.Raw), cur.Current) {
-			return false
-		}
+		fmt.Println(string(out))
+		return
 	}
 
-	if cur.Next(context.Background()) {
-		return false
+	nargs := 2
+	if *workloadFile != "" || *selfTest {
+		nargs = 1
 	}
-	return cur.Err() == nil
-}
-
-func verifyUpdateResult(res *mongo.UpdateResult, result interface{}) bool {
-	if result == nil {
-		return true
+	if flag.NArg() != nargs {
+		flag.Usage()
+		os.Exit(2)
 	}
+	connstring := flag.Arg(0)
 
-	var expected struct {
-		MatchedCount  int64 `bson:"matchedCount"`
-		ModifiedCount int64 `bson:"modifiedCount"`
-		UpsertedCount int64 `bson:"upsertedCount"`
-	}
-	err := bson.Unmarshal(result.(bson.Raw), &expected)
+	var workloadSpec []byte
+	var reloads chan []byte
+	hosts, err := executor.ParseHostMap(*hostMap)
 	if err != nil {
-		return false
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(2)
 	}
 
-	if expected.MatchedCount != res.MatchedCount {
-		return false
+	opts := executor.Options{
+		HostMap:      hosts,
+		ToxiproxyURL: *toxiproxy,
+		TestName:     *testName,
+		Serverless:   *serverless,
+		MaxEvents:    *maxEvents,
+		MaxErrors:    *maxErrors,
+		MaxFailures:  *maxFailures,
+
+		FlushInterval: *flushInterval,
+		FailoverURIs:  failoverURIs,
+		Seed:          *seed,
+		Concurrency:   *concurrency,
+
+		DisableOCSPEndpointCheck: *disableOCSPEndpointCheck,
+
+		ClockSkewInterval: *clockSkewInterval,
+		MetricsBucketSize: *metricsBucket,
+		// astrolabe names the phase of the test in this file, see Results.Phases
+		PhaseFile: os.Getenv("ASTROLABE_PHASE_FILE"),
+		// astrolabe relaunches the executor with the same file if it crashes
+		CheckpointFile: os.Getenv("ASTROLABE_CHECKPOINT_FILE"),
+		// set by the watchdog, see watchdog/watchdog.go
+		HeartbeatFile: os.Getenv("ASTROLABE_HEARTBEAT_FILE"),
+		// set by astrolabe for scenarios that configure an online archive
+		OnlineArchiveURI: os.Getenv("ASTROLABE_ONLINE_ARCHIVE_URI"),
+		// mirrors the writes of the workload to a cluster without maintenance, see
+		// executor.DualClusterComparison
+		ControlURI: os.Getenv("ASTROLABE_CONTROL_URI"),
+
+		CaptureCommands: *captureCommands,
+
+		MaxHeapGrowthMBPerHour: *maxHeapGrowth,
+		HeapSampleInterval:     *heapSampleInterval,
 	}
-	if expected.ModifiedCount != res.ModifiedCount {
-		return false
+	if *opsPerSecond > 0 || *operationDelay > 0 {
+		opts.Pacing = &executor.Pacing{
+			OpsPerSecond: *opsPerSecond,
+			DelayMS:      operationDelay.Milliseconds(),
+			RampSeconds:  rampUp.Seconds(),
+		}
 	}
-
-	actualUpsertedCount := int64(0)
-	if res.UpsertedID != nil {
-		actualUpsertedCount = 1
+	// credentials of the KMS providers are secrets, so they are only taken from the environment
+	if opts.KMS, err = executor.KMSConfigFromEnv(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
-	return expected.UpsertedCount == actualUpsertedCount
-}
-
-func executeCollectionOperation(coll *mongo.Collection, op *operation) (bool, error) {
-	switch op.Name {
-	case "insertOne":
-		res, err := executeInsertOne(coll, op.Arguments)
-		return verifyInsertOneResult(res, op.Result), err
-	case "find":
-		cursor, err := executeFind(coll, op.Arguments)
-		return verifyCursorResult(cursor, op.Result), err
-	case "updateOne":
-		res, err := executeUpdateOne(coll, op.Arguments)
-		return verifyUpdateResult(res, op.Result), err
-	}
-	return false, errors.New("unrecognized collection operation: " + op.Name)
-}
-
-func runOperation(coll *mongo.Collection, op *operation) (bool, error) {
-	// execute the command on the given object
-	if op.Object == "collection" {
-		return executeCollectionOperation(coll, op)
+	// astrolabe sets this for scenarios that need the cluster under load, see executor.SyntheticLoad
+	if load := os.Getenv("ASTROLABE_SYNTHETIC_LOAD"); load != "" {
+		opts.SyntheticLoad = &executor.SyntheticLoad{}
+		if err := json.Unmarshal([]byte(load), opts.SyntheticLoad); err != nil {
+			fmt.Fprintf(os.Stderr, "malformed ASTROLABE_SYNTHETIC_LOAD: %v\n", err)
+			os.Exit(2)
+		}
 	}
-	str := "unrecognized object: " + op.Name
-	panic(str)
-}
-
-func main() {
-	connstring := os.Args[1]
-	workloadSpec := os.Args[2]
-
-	var workload driverWorkload
-	err := bson.UnmarshalExtJSONWithRegistry(specTestRegistry, []byte(workloadSpec), false, &workload)
-	if err != nil {
-		panic(err)
+	if names := opts.KMS.ProviderNames(); len(names) > 0 {
+		fmt.Fprintf(os.Stderr, "KMS providers configured: %v\n", names)
 	}
-
-	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(connstring))
-	if err != nil {
-		panic(err)
+	if *selfTest {
+		runSelfTest(connstring, opts)
+		return
+	}
+	switch {
+	case *workloadFile == stdinSpec || (*workloadFile != "" && command == runUnifiedCommand):
+		// stdin cannot be read again, and a unified workload cannot change while its test cases
+		// run, so the workload is not reloaded
+		workloadSpec = readWorkloadFile()
+	case *workloadFile != "":
+		workloadSpec = readWorkloadFile()
+		reloads = make(chan []byte)
+		opts.Reloads = reloads
+	default:
+		workloadSpec = workloadArg(flag.Arg(1))
 	}
-	defer func() { _ = client.Disconnect(context.Background()) }()
-
-	db := client.Database(workload.Database)
-	coll := db.Collection(workload.Collection)
 
-	results := struct {
-		NumErrors    int `json:"numErrors"`
-		NumFailures  int `json:"numFailures"`
-		NumSuccesses int `json:"numSuccesses"`
-	}{}
+	// a pod is not restarted by a probe while it finishes the workload after SIGTERM, since the
+	// server keeps answering /healthz until the results are written
+	var status *executor.StatusServer
+	if *statusAddr == "" {
+		*statusAddr = os.Getenv("ASTROLABE_STATUS_ADDR")
+	}
+	if *statusAddr != "" {
+		status, err = executor.NewStatusServer(*statusAddr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "serving the status failed: %v\n", err)
+			os.Exit(2)
+		}
+		defer func() { _ = status.Close(5 * time.Second) }()
+	}
 
-	done := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	// Waits for the termination signal from astrolabe and terminates the operation loop
 	go func() {
@@ -267,44 +396,92 @@ func main() {
 		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
 		<-c
-		close(done)
-	}()
-
-	defer func() {
-		data, err := json.Marshal(results)
-		if err != nil {
-			str := fmt.Sprintf("marshal results failed: %v", err)
-			panic(str)
-		}
-		path, _ := os.Getwd()
-		err = ioutil.WriteFile(path+"/results.json", data, 0644)
-		if err != nil {
-			str := fmt.Sprintf("write to file failed: %v", err)
-			panic(str)
+		if status != nil {
+			status.Stopping()
 		}
+		cancel()
 	}()
 
-	for {
-		select {
-		case <-done:
-			return
-		default:
-		}
-		for _, operation := range workload.Operations {
-			select {
-			case <-done:
-				return
-			default:
-				pass, err := runOperation(coll, operation)
-				switch {
-				case err != nil:
-					results.NumErrors++
-				case pass:
-					results.NumSuccesses++
-				default:
-					results.NumFailures++
+	// Reloads the workload file when the orchestrator rewrites it and sends SIGHUP. The new
+	// workload takes effect at the next iteration boundary.
+	if reloads != nil {
+		go func() {
+			c := make(chan os.Signal, 1)
+			signal.Notify(c, syscall.SIGHUP)
+
+			for {
+				select {
+				case <-c:
+				case <-ctx.Done():
+					return
+				}
+				spec, err := ioutil.ReadFile(*workloadFile)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "reading workload file failed: %v\n", err)
+					continue
+				}
+				select {
+				case reloads <- spec:
+				case <-ctx.Done():
+					return
 				}
 			}
+		}()
+	}
+
+	path, _ := os.Getwd()
+	opts.HeapProfileFile = filepath.Join(path, "heap.pprof")
+	sinks := []executor.Sink{
+		executor.VersionedResultsFileSink(filepath.Join(path, "results.json"), resultsVersion()),
+		executor.EventsFileSink(filepath.Join(path, "events.json")),
+		executor.TopologyTimelineFileSink(filepath.Join(path, "topology-timeline.json")),
+		executor.MetricsFileSink(filepath.Join(path, "metrics.json")),
+		executor.SDAMEventsFileSink(filepath.Join(path, "sdam-events.json")),
+	}
+	if status != nil {
+		sinks = append(sinks, status)
+	}
+	if *tapOutput {
+		sinks = append(sinks, executor.TAPSink(os.Stdout))
+	}
+	// astrolabe waits for this file before starting maintenance, see DriverWorkloadSubprocessRunner
+	if readyFile := os.Getenv("ASTROLABE_READY_FILE"); readyFile != "" {
+		sinks = append(sinks, executor.ReadyFileSink(readyFile))
+	}
+	// astrolabe follows the counters through this file and falls back to it if no results.json
+	// is written, e.g. because the executor was killed
+	if progressFile := os.Getenv("ASTROLABE_PROGRESS_FILE"); progressFile != "" {
+		sinks = append(sinks, executor.ProgressFileSink(progressFile))
+	}
+	// the reporting deployment keeps the metrics of every run for charting trends across runs;
+	// its connection string holds credentials, so it is only taken from the environment
+	if reportURI := os.Getenv("ASTROLABE_REPORT_URI"); reportURI != "" {
+		sink, err := executor.MongoReportSink(reportURI, *reportDatabase, os.Getenv("ASTROLABE_TEST_NAME"))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			sinks = append(sinks, sink)
+		}
+	}
+
+	// both entrypoints write the same output files and share the shutdown and the exit statuses
+	run := executor.RunWithOptions
+	if command == runUnifiedCommand {
+		run = executor.RunUnified
+	}
+	results, err := run(ctx, connstring, workloadSpec, opts, sinks...)
+	switch {
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "%s error: %v\n", executor.ErrorCategory(err), err)
+	case results.BudgetBreach != nil:
+		breach := results.BudgetBreach
+		fmt.Fprintf(os.Stderr, "stopped early: %s of %v exceeded the error budget of %v\n",
+			breach.Threshold, breach.Actual, breach.Limit)
+	}
+	if code := runExitCode(results, err); code != 0 {
+		if status != nil {
+			_ = status.Close(5 * time.Second)
 		}
+		os.Exit(code)
 	}
 }